@@ -1,11 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
 	helm "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
@@ -226,6 +229,32 @@ func main() {
 			return err
 		}
 
+		// Let peers reach the ethereum clients on the p2p ports bound on the
+		// nodes, the managed node group runs with the cluster security group.
+		// The ports come from the node stacks so every client's ports, like
+		// erigon's torrent port, are opened.
+		var nodeStacks []string
+		if err := cfg.GetObject("nodeStacks", &nodeStacks); err != nil {
+			return err
+		}
+		p2pPorts, err := nodeP2pPorts(ctx, nodeStacks)
+		if err != nil {
+			return err
+		}
+		for _, p := range p2pPorts {
+			_, err = ec2.NewSecurityGroupRule(ctx, fmt.Sprintf("p2p-%d-%s", p.port, p.protocol), &ec2.SecurityGroupRuleArgs{
+				Type:            pulumi.String("ingress"),
+				SecurityGroupId: cluster.VpcConfig.ClusterSecurityGroupId().Elem(),
+				FromPort:        pulumi.Int(p.port),
+				ToPort:          pulumi.Int(p.port),
+				Protocol:        pulumi.String(p.protocol),
+				CidrBlocks:      pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
 		_, err = eks.NewAddon(ctx, "coredns", &eks.AddonArgs{
 			AddonName:                pulumi.String("coredns"),
 			ClusterName:              cluster.Name,
@@ -236,7 +265,7 @@ func main() {
 			return err
 		}
 
-		_, err = eks.NewAddon(ctx, "ebs-csi-driver", &eks.AddonArgs{
+		ebsCsiDriver, err := eks.NewAddon(ctx, "ebs-csi-driver", &eks.AddonArgs{
 			AddonName:                pulumi.String("aws-ebs-csi-driver"),
 			ClusterName:              cluster.Name,
 			ServiceAccountRoleArn:    serviceAccountRole.Arn,
@@ -247,6 +276,35 @@ func main() {
 			return err
 		}
 
+		// Install the snapshot controller along with the VolumeSnapshot CRDs.
+		snapshotController, err := eks.NewAddon(ctx, "snapshot-controller", &eks.AddonArgs{
+			AddonName:                pulumi.String("snapshot-controller"),
+			ClusterName:              cluster.Name,
+			ResolveConflictsOnUpdate: pulumi.String("PRESERVE"),
+			ResolveConflictsOnCreate: pulumi.String("NONE"),
+		}, pulumi.DependsOn([]pulumi.Resource{ebsCsiDriver}))
+		if err != nil {
+			return err
+		}
+
+		// Create the VolumeSnapshotClass node volumes are snapshotted with. The
+		// ebs snapshot is deleted along with its VolumeSnapshot, so pruning old
+		// VolumeSnapshots is what frees the storage.
+		_, err = apiextensions.NewCustomResource(ctx, "ebs-snapshot-class", &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("snapshot.storage.k8s.io/v1"),
+			Kind:       pulumi.String("VolumeSnapshotClass"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("aws-ebs-snapshot"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"driver":         "ebs.csi.aws.com",
+				"deletionPolicy": "Delete",
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{snapshotController}))
+		if err != nil {
+			return err
+		}
+
 		// Create IAM policy from json file config/aws-lb-controller/iam-policy.json
 		iamPolicyFile, err := os.ReadFile("config/aws-lb-controller/iam_policy.json")
 		if err != nil {
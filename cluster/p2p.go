@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// p2pPort is a port the ethereum clients of a node stack bind on the nodes
+type p2pPort struct {
+	port     int
+	protocol string
+}
+
+// nodeP2pPorts reads the p2pPorts output of every node stack, as
+// port/protocol, and returns the distinct ports in a stable order. A stack
+// only exports the output once it's deployed with p2p set, so a new node
+// stack is added to nodeStacks after its first update. Stacks without ports
+// are warned about rather than failed on, the cluster still has to come up
+// before any node stack exists, but peers can't reach their clients.
+func nodeP2pPorts(ctx *pulumi.Context, stacks []string) ([]p2pPort, error) {
+	if len(stacks) == 0 {
+		ctx.Log.Warn("nodeStacks is empty, no p2p ports are opened and peers can't reach the ethereum clients", nil)
+	}
+	seen := map[p2pPort]bool{}
+	var ports []p2pPort
+	for _, stack := range stacks {
+		ref, err := pulumi.NewStackReference(ctx, stack, nil)
+		if err != nil {
+			return nil, err
+		}
+		details, err := ref.GetOutputDetails("p2pPorts")
+		if err != nil {
+			return nil, err
+		}
+		if details == nil || details.Value == nil {
+			ctx.Log.Warn(fmt.Sprintf("stack %s exports no p2pPorts, deploy it with p2p set before listing it in nodeStacks", stack), nil)
+			continue
+		}
+		values, ok := details.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("stack %s: p2pPorts is not a list", stack)
+		}
+		if len(values) == 0 {
+			ctx.Log.Warn(fmt.Sprintf("stack %s exports an empty p2pPorts", stack), nil)
+		}
+		for _, value := range values {
+			entry, _ := value.(string)
+			port, err := parseP2pPort(entry)
+			if err != nil {
+				return nil, fmt.Errorf("stack %s: %w", stack, err)
+			}
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].port != ports[j].port {
+			return ports[i].port < ports[j].port
+		}
+		return ports[i].protocol < ports[j].protocol
+	})
+	return ports, nil
+}
+
+// parseP2pPort parses a port/protocol entry like 30303/TCP
+func parseP2pPort(entry string) (p2pPort, error) {
+	number, protocol, ok := strings.Cut(entry, "/")
+	port, err := strconv.Atoi(number)
+	if !ok || err != nil || port <= 0 || port > 65535 {
+		return p2pPort{}, fmt.Errorf("invalid p2p port %q", entry)
+	}
+	protocol = strings.ToLower(protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		return p2pPort{}, fmt.Errorf("invalid p2p port protocol %q", entry)
+	}
+	return p2pPort{port: port, protocol: protocol}, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	backupName = "lighthouse-validator-backup"
+	backupDir  = "/backup"
+	scratchDir = "/scratch"
+)
+
+// snapshotDatabaseScript takes a consistent snapshot of the slashing
+// protection database of the running validator client. Lighthouse keeps the
+// database under an exclusive lock while it runs, so it is not opened in
+// place: its files are copied until a copy matches the live files, then
+// sqlite recovers the copy, backs it up into the snapshot and checks the
+// snapshot's integrity. The job fails when no copy checks out.
+const snapshotDatabaseScript = `set -eu
+live="$DATADIR/validators"
+if [ ! -f "$live/slashing_protection.sqlite" ]; then
+  echo "no slashing protection database yet"
+  exit 0
+fi
+copy="$SCRATCH/copy"
+snapshot="$SCRATCH/validators/slashing_protection.sqlite"
+mkdir -p "$copy" "$SCRATCH/validators"
+for attempt in 1 2 3 4 5; do
+  rm -f "$copy"/* "$snapshot"
+  for file in slashing_protection.sqlite slashing_protection.sqlite-journal slashing_protection.sqlite-wal; do
+    if [ -f "$live/$file" ]; then
+      cp "$live/$file" "$copy/"
+    fi
+  done
+  unchanged=true
+  for file in "$copy"/*; do
+    cmp -s "$file" "$live/$(basename "$file")" || unchanged=false
+  done
+  if [ "$unchanged" = true ] &&
+    sqlite3 "$copy/slashing_protection.sqlite" ".backup '$snapshot'" &&
+    [ "$(sqlite3 "$snapshot" "PRAGMA integrity_check;")" = ok ]; then
+    echo "snapshot of the slashing protection database taken"
+    exit 0
+  fi
+  echo "slashing protection database changed while copying or failed its integrity check, attempt $attempt"
+  sleep 2
+done
+echo "no consistent snapshot of the slashing protection database"
+exit 1
+`
+
+// collectBackupScript copies the keystores, which stay encrypted with their
+// passwords, and the slashing protection database snapshot into the backup
+// volume, with an interchange export of the snapshot.
+const collectBackupScript = `set -eu
+if [ -n "$KEYSTORES" ]; then
+  mkdir -p "$BACKUP/keystores"
+  cp "$KEYSTORES"/*.json "$BACKUP/keystores/"
+fi
+snapshot="$SCRATCH/validators/slashing_protection.sqlite"
+if [ ! -f "$snapshot" ]; then
+  exit 0
+fi
+mkdir -p "$BACKUP/slashing-protection"
+cp "$snapshot" "$BACKUP/slashing-protection/"
+lighthouse account validator slashing-protection export --network "$NETWORK" --datadir "$SCRATCH" "$BACKUP/slashing-protection/interchange.json"
+`
+
+// dumpSignerDatabaseScript dumps the slashing protection database of web3signer
+const dumpSignerDatabaseScript = `set -eu
+mkdir -p "$BACKUP/web3signer"
+PGPASSWORD="$DB_PASSWORD" pg_dump -h "$DB_HOST" -p "$DB_PORT" -U "$DB_USER" -d "$DB_NAME" -Fc -f "$BACKUP/web3signer/slashing-protection.dump"
+`
+
+// uploadBackupScript uploads the backup under a timestamped prefix,
+// encrypted with the bucket key. Object lock needs a checksum on every upload.
+const uploadBackupScript = `set -eu
+prefix="$PREFIX$(date -u +%Y-%m-%dT%H-%M-%SZ)/"
+aws s3 cp --recursive "$BACKUP" "s3://$BUCKET/$prefix" --sse aws:kms --sse-kms-key-id "$KMS_KEY" --checksum-algorithm SHA256
+echo "backed up to s3://$BUCKET/$prefix"
+`
+
+// newValidatorBackup creates the cron job backing up the keystores and the
+// slashing protection databases into the vault. It runs with the validator's
+// service account next to the validator pod, the data volume can only be
+// attached to one node.
+func newValidatorBackup(ctx *pulumi.Context, args *validatorArgs, serviceAccountName pulumi.StringInput) error {
+	namespace := pulumi.String(args.Namespace)
+
+	backupMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("backup"),
+		MountPath: pulumi.String(backupDir),
+	}
+	validatorDataMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("validator-data"),
+		MountPath: pulumi.String(validatorDataDir),
+		ReadOnly:  pulumi.Bool(true),
+	}
+	scratchMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("scratch"),
+		MountPath: pulumi.String(scratchDir),
+	}
+	collectMounts := corev1.VolumeMountArray{backupMount, scratchMount}
+	volumes := corev1.VolumeArray{
+		corev1.VolumeArgs{
+			Name:     pulumi.String("backup"),
+			EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+		},
+		corev1.VolumeArgs{
+			Name:     pulumi.String("scratch"),
+			EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+		},
+		// the claim of the statefulset's only replica
+		corev1.VolumeArgs{
+			Name: pulumi.String("validator-data"),
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSourceArgs{
+				ClaimName: pulumi.Sprintf("validator-data-%s-0", validatorName),
+				ReadOnly:  pulumi.Bool(true),
+			},
+		},
+	}
+
+	// the keystores are in their secret unless web3signer loads them from secrets manager
+	keystores := ""
+	if args.Web3Signer == nil || args.Web3Signer.KeySource == KeysFromSecret {
+		keystores = keystoresDir
+		collectMounts = append(collectMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String("keystores"),
+			MountPath: pulumi.String(keystoresDir),
+			ReadOnly:  pulumi.Bool(true),
+		})
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String("keystores"),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName: pulumi.String(args.KeystoresSecret),
+			},
+		})
+	}
+
+	initContainers := corev1.ContainerArray{
+		corev1.ContainerArgs{
+			Name:    pulumi.String("snapshot-slashing-protection"),
+			Image:   pulumi.String(args.Vault.SnapshotImage),
+			Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(snapshotDatabaseScript)},
+			Env: corev1.EnvVarArray{
+				corev1.EnvVarArgs{Name: pulumi.String("DATADIR"), Value: pulumi.String(validatorDataDir)},
+				corev1.EnvVarArgs{Name: pulumi.String("SCRATCH"), Value: pulumi.String(scratchDir)},
+			},
+			// the sqlite image runs as a user that cannot read the database
+			SecurityContext: &corev1.SecurityContextArgs{
+				RunAsUser: pulumi.Int(0),
+			},
+			VolumeMounts: corev1.VolumeMountArray{validatorDataMount, scratchMount},
+		},
+		corev1.ContainerArgs{
+			Name:    pulumi.String("collect-backup"),
+			Image:   pulumi.String(args.Image),
+			Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(collectBackupScript)},
+			Env: corev1.EnvVarArray{
+				corev1.EnvVarArgs{Name: pulumi.String("NETWORK"), Value: args.Network},
+				corev1.EnvVarArgs{Name: pulumi.String("KEYSTORES"), Value: pulumi.String(keystores)},
+				corev1.EnvVarArgs{Name: pulumi.String("SCRATCH"), Value: pulumi.String(scratchDir)},
+				corev1.EnvVarArgs{Name: pulumi.String("BACKUP"), Value: pulumi.String(backupDir)},
+			},
+			VolumeMounts: collectMounts,
+		},
+	}
+	if db := args.SignerDatabase; db != nil {
+		initContainers = append(initContainers, corev1.ContainerArgs{
+			Name:    pulumi.String("dump-web3signer-database"),
+			Image:   pulumi.String(args.Web3Signer.Database.Image),
+			Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(dumpSignerDatabaseScript)},
+			Env: corev1.EnvVarArray{
+				corev1.EnvVarArgs{Name: pulumi.String("DB_HOST"), Value: pulumi.String(db.Host)},
+				corev1.EnvVarArgs{Name: pulumi.String("DB_PORT"), Value: pulumi.Sprintf("%d", db.Port)},
+				corev1.EnvVarArgs{Name: pulumi.String("DB_NAME"), Value: pulumi.String(db.Name)},
+				corev1.EnvVarArgs{Name: pulumi.String("DB_USER"), Value: pulumi.String(db.Username)},
+				db.PasswordEnv,
+				corev1.EnvVarArgs{Name: pulumi.String("BACKUP"), Value: pulumi.String(backupDir)},
+			},
+			VolumeMounts: corev1.VolumeMountArray{backupMount},
+		})
+	}
+
+	// Create the cron job, a backup still running when the next one is due is left to finish
+	_, err := batchv1.NewCronJob(ctx, backupName, &batchv1.CronJobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Spec: &batchv1.CronJobSpecArgs{
+			Schedule:                   pulumi.String(args.Vault.BackupSchedule),
+			ConcurrencyPolicy:          pulumi.String("Forbid"),
+			SuccessfulJobsHistoryLimit: pulumi.Int(3),
+			FailedJobsHistoryLimit:     pulumi.Int(3),
+			JobTemplate: &batchv1.JobTemplateSpecArgs{
+				Spec: &batchv1.JobSpecArgs{
+					BackoffLimit: pulumi.Int(2),
+					Template: &corev1.PodTemplateSpecArgs{
+						Spec: &corev1.PodSpecArgs{
+							RestartPolicy:      pulumi.String("Never"),
+							ServiceAccountName: serviceAccountName,
+							Affinity: &corev1.AffinityArgs{
+								PodAffinity: &corev1.PodAffinityArgs{
+									RequiredDuringSchedulingIgnoredDuringExecution: corev1.PodAffinityTermArray{
+										corev1.PodAffinityTermArgs{
+											LabelSelector: &metav1.LabelSelectorArgs{
+												MatchLabels: pulumi.StringMap{"app": pulumi.String(validatorName)},
+											},
+											TopologyKey: pulumi.String("kubernetes.io/hostname"),
+										},
+									},
+								},
+							},
+							InitContainers: initContainers,
+							Containers: corev1.ContainerArray{
+								corev1.ContainerArgs{
+									Name:    pulumi.String("upload-backup"),
+									Image:   pulumi.String(args.Vault.BackupImage),
+									Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(uploadBackupScript)},
+									Env: corev1.EnvVarArray{
+										corev1.EnvVarArgs{Name: pulumi.String("BUCKET"), Value: args.Bucket},
+										corev1.EnvVarArgs{Name: pulumi.String("KMS_KEY"), Value: args.BucketKeyArn},
+										corev1.EnvVarArgs{Name: pulumi.String("PREFIX"), Value: pulumi.String(backupsPrefix)},
+										corev1.EnvVarArgs{Name: pulumi.String("BACKUP"), Value: pulumi.String(backupDir)},
+									},
+									VolumeMounts: corev1.VolumeMountArray{backupMount},
+								},
+							},
+							Volumes: volumes,
+						},
+					},
+				},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/dynamodb"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ValidatorGuardConfig runs the validator client behind validator-guard, so
+// the keys only sign where the guard holds the lease. The kubernetes backend
+// guards against a second validator pod, the dynamodb backend also against
+// validators outside the cluster, like an ec2 host running the same keys,
+// which point their guard at the exported guardTable.
+//
+// Stack config uses the json names, e.g.
+//
+//	holesky-validator:validatorGuard:
+//	  image: 123456789012.dkr.ecr.us-east-2.amazonaws.com/validator-guard:v1
+//	  backend: dynamodb
+type ValidatorGuardConfig struct {
+	// Image is built from the validator-guard directory of this repo
+	Image string `json:"image"`
+	// Backend is kubernetes or dynamodb
+	Backend string `json:"backend"`
+	// LeaseName is shared by every validator running the same keys
+	LeaseName string `json:"leaseName"`
+	// LeaseDurationSeconds, RenewDeadlineSeconds and RetryPeriodSeconds time
+	// the lease, the validator is killed once it went unrenewed for the renew
+	// deadline and can be taken over after the lease duration
+	LeaseDurationSeconds int `json:"leaseDurationSeconds"`
+	RenewDeadlineSeconds int `json:"renewDeadlineSeconds"`
+	RetryPeriodSeconds   int `json:"retryPeriodSeconds"`
+}
+
+const (
+	guardBackendKubernetes      = "kubernetes"
+	guardBackendDynamoDb        = "dynamodb"
+	guardName                   = "lighthouse-validator-guard"
+	guardDir                    = "/guard"
+	guardConfigDir              = "/etc/validator-guard"
+	defaultLeaseDurationSeconds = 60
+	defaultRenewDeadlineSeconds = 40
+	defaultRetryPeriodSeconds   = 5
+)
+
+func (c *ValidatorGuardConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("validatorGuard image is required")
+	}
+	if c.Backend == "" {
+		c.Backend = guardBackendKubernetes
+	}
+	if c.Backend != guardBackendKubernetes && c.Backend != guardBackendDynamoDb {
+		return fmt.Errorf("validatorGuard backend %q must be %s or %s", c.Backend, guardBackendKubernetes, guardBackendDynamoDb)
+	}
+	if c.LeaseName == "" {
+		c.LeaseName = validatorName
+	}
+	if c.LeaseDurationSeconds == 0 {
+		c.LeaseDurationSeconds = defaultLeaseDurationSeconds
+	}
+	if c.RenewDeadlineSeconds == 0 {
+		c.RenewDeadlineSeconds = defaultRenewDeadlineSeconds
+	}
+	if c.RetryPeriodSeconds == 0 {
+		c.RetryPeriodSeconds = defaultRetryPeriodSeconds
+	}
+	if c.RetryPeriodSeconds <= 0 || c.RenewDeadlineSeconds <= c.RetryPeriodSeconds || c.LeaseDurationSeconds <= c.RenewDeadlineSeconds {
+		return fmt.Errorf("validatorGuard retryPeriodSeconds, renewDeadlineSeconds and leaseDurationSeconds must be positive and increasing")
+	}
+	return nil
+}
+
+// guardCommand wraps the validator client command in the guard installed into the guard volume
+func guardCommand(command pulumi.StringArray) pulumi.StringArray {
+	return append(pulumi.StringArray{
+		pulumi.Sprintf("%s/validator-guard", guardDir),
+		pulumi.String("-config"), pulumi.Sprintf("%s/guard.json", guardConfigDir),
+		pulumi.String("--"),
+	}, command...)
+}
+
+// newValidatorGuard creates the lease backend of the guard and its config,
+// returning the config map holding it and the iam policy statements the
+// validator pod needs for the lease
+func newValidatorGuard(ctx *pulumi.Context, args *validatorArgs, identity *awsIdentity) (pulumi.StringOutput, pulumi.Array, error) {
+	guard := args.Guard
+	namespace := pulumi.String(args.Namespace)
+	var statements pulumi.Array
+
+	backend := pulumi.Map{}
+	if guard.Backend == guardBackendKubernetes {
+		backend["kubernetes"] = pulumi.Map{"namespace": namespace}
+
+		// Create a role for the guard to take and renew its lease, the lease
+		// is created by the first guard so it cannot be limited by name
+		role, err := rbacv1.NewRole(ctx, guardName, &rbacv1.RoleArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			Rules: rbacv1.PolicyRuleArray{
+				rbacv1.PolicyRuleArgs{
+					ApiGroups:     pulumi.StringArray{pulumi.String("coordination.k8s.io")},
+					Resources:     pulumi.StringArray{pulumi.String("leases")},
+					ResourceNames: pulumi.StringArray{pulumi.String(guard.LeaseName)},
+					Verbs:         pulumi.ToStringArray([]string{"get", "update"}),
+				},
+				rbacv1.PolicyRuleArgs{
+					ApiGroups: pulumi.StringArray{pulumi.String("coordination.k8s.io")},
+					Resources: pulumi.StringArray{pulumi.String("leases")},
+					Verbs:     pulumi.ToStringArray([]string{"create"}),
+				},
+			},
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, nil, err
+		}
+		_, err = rbacv1.NewRoleBinding(ctx, guardName, &rbacv1.RoleBindingArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			RoleRef: &rbacv1.RoleRefArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("Role"),
+				Name:     role.Metadata.Name().Elem(),
+			},
+			Subjects: rbacv1.SubjectArray{
+				rbacv1.SubjectArgs{
+					Kind:      pulumi.String("ServiceAccount"),
+					Name:      pulumi.String(validatorName),
+					Namespace: namespace,
+				},
+			},
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, nil, err
+		}
+	} else {
+		// Create the table holding the lease, shared with validators outside the cluster
+		table, err := dynamodb.NewTable(ctx, "validator-guard", &dynamodb.TableArgs{
+			BillingMode: pulumi.String("PAY_PER_REQUEST"),
+			HashKey:     pulumi.String("name"),
+			Attributes: dynamodb.TableAttributeArray{
+				dynamodb.TableAttributeArgs{
+					Name: pulumi.String("name"),
+					Type: pulumi.String("S"),
+				},
+			},
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, nil, err
+		}
+		statements = append(statements, pulumi.Map{
+			"Effect":   pulumi.String("Allow"),
+			"Action":   pulumi.ToStringArray([]string{"dynamodb:GetItem", "dynamodb:PutItem"}),
+			"Resource": table.Arn,
+		})
+		backend["dynamodb"] = pulumi.Map{
+			"table":  table.Name,
+			"region": pulumi.String(identity.Region),
+		}
+		ctx.Export("guardTable", table.Name)
+	}
+
+	config := pulumi.Map{
+		"backend":              pulumi.String(guard.Backend),
+		"name":                 pulumi.String(guard.LeaseName),
+		"leaseDurationSeconds": pulumi.Int(guard.LeaseDurationSeconds),
+		"renewDeadlineSeconds": pulumi.Int(guard.RenewDeadlineSeconds),
+		"retryPeriodSeconds":   pulumi.Int(guard.RetryPeriodSeconds),
+	}
+	for key, value := range backend {
+		config[key] = value
+	}
+	guardJson := pulumi.JSONMarshal(config)
+
+	// Create a config map with the guard config, mounted into the validator pod
+	configMap, err := corev1.NewConfigMap(ctx, guardName, &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Data: pulumi.StringMap{
+			"guard.json": guardJson,
+		},
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, nil, err
+	}
+	return configMap.Metadata.Name().Elem(), statements, nil
+}
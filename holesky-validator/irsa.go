@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// awsIdentity is the account and cluster the validator's iam roles belong to
+type awsIdentity struct {
+	AccountId string
+	Region    string
+	// OidcUrl is the cluster's oidc issuer without the scheme, the same oidcUrl the cluster program is configured with
+	OidcUrl string
+}
+
+// newIrsaRole creates an iam role the service account in namespace assumes
+// through the cluster's oidc provider, with policy as its inline policy document
+func newIrsaRole(ctx *pulumi.Context, name string, identity *awsIdentity, namespace, serviceAccount string, policy pulumi.StringInput) (*iam.Role, error) {
+	if identity.OidcUrl == "" {
+		return nil, fmt.Errorf("oidcUrl is required for the %s service account to assume an iam role", serviceAccount)
+	}
+	assumeRolePolicy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect": "Allow",
+				"Principal": map[string]interface{}{
+					"Federated": fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", identity.AccountId, identity.OidcUrl),
+				},
+				"Action": "sts:AssumeRoleWithWebIdentity",
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]interface{}{
+						identity.OidcUrl + ":aud": "sts.amazonaws.com",
+						identity.OidcUrl + ":sub": fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return iam.NewRole(ctx, name, &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(assumeRolePolicy),
+		InlinePolicies: iam.RoleInlinePolicyArray{
+			iam.RoleInlinePolicyArgs{
+				Name:   pulumi.String(name),
+				Policy: policy,
+			},
+		},
+	})
+}
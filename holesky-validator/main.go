@@ -1,20 +1,194 @@
 package main
 
 import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	"images/manifest"
 )
 
+// getOrDefault returns the stack config value for key, or fallback when unset
+func getOrDefault(cfg *config.Config, key, fallback string) string {
+	if value := cfg.Get(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getAwsIdentity looks up the account and region the stack deploys to
+func getAwsIdentity(ctx *pulumi.Context, cfg *config.Config) (*awsIdentity, error) {
+	caller, err := aws.GetCallerIdentity(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	region, err := aws.GetRegion(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &awsIdentity{
+		AccountId: caller.AccountId,
+		Region:    region.Name,
+		OidcUrl:   cfg.Get("oidcUrl"),
+	}, nil
+}
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
-		// Create an AWS resource (S3 Bucket)
-		bucket, err := s3.NewBucket(ctx, "my-bucket", nil)
+		cfg := config.New(ctx, "")
+
+		// the beacon node the validator attaches to, e.g. organization/swannynode-mainnet/holesky
+		node, err := pulumi.NewStackReference(ctx, cfg.Require("beaconNodeStack"), nil)
+		if err != nil {
+			return err
+		}
+
+		images, err := manifest.NewResolver(ctx, manifest.DefaultPath)
+		if err != nil {
+			return err
+		}
+		image := cfg.Get("validatorImage")
+		if image == "" {
+			if image, err = images.Image("lighthouse"); err != nil {
+				return err
+			}
+		}
+
+		// optional web3signer holding the keys instead of the validator client
+		var web3signer *Web3SignerConfig
+		if err := cfg.GetObject("web3signer", &web3signer); err != nil {
+			return err
+		}
+		var definitionsImage string
+		if web3signer != nil {
+			if web3signer.Image == "" {
+				if web3signer.Image, err = images.Image("web3signer"); err != nil {
+					return err
+				}
+			}
+			if web3signer.MigrationsImage == "" {
+				if web3signer.MigrationsImage, err = images.Image("flyway"); err != nil {
+					return err
+				}
+			}
+			if web3signer.Database.Image == "" {
+				if web3signer.Database.Image, err = images.Image("postgres"); err != nil {
+					return err
+				}
+			}
+			if password, err := cfg.TrySecret("web3signerDbPassword"); err == nil {
+				web3signer.Database.Password = password
+			}
+			if definitionsImage, err = images.Image("curl"); err != nil {
+				return err
+			}
+		}
+
+		// optional lease keeping the keys from signing in two places
+		var guard *ValidatorGuardConfig
+		if err := cfg.GetObject("validatorGuard", &guard); err != nil {
+			return err
+		}
+
+		// optional export or import of the slashing protection history
+		var slashingProtection *SlashingProtectionConfig
+		if err := cfg.GetObject("slashingProtection", &slashingProtection); err != nil {
+			return err
+		}
+		if slashingProtection != nil && slashingProtection.Image == "" {
+			if slashingProtection.Image, err = images.Image("aws-cli"); err != nil {
+				return err
+			}
+		}
+		if slashingProtection != nil && slashingProtection.Export != "" && slashingProtection.KubectlImage == "" {
+			if slashingProtection.KubectlImage, err = images.Image("kubectl"); err != nil {
+				return err
+			}
+		}
+
+		// the vault settings of the bucket, with the backups it keeps
+		var vault VaultConfig
+		if err := cfg.GetObject("vault", &vault); err != nil {
+			return err
+		}
+		if vault.BackupImage == "" {
+			if vault.BackupImage, err = images.Image("aws-cli"); err != nil {
+				return err
+			}
+		}
+		if vault.SnapshotImage == "" {
+			if vault.SnapshotImage, err = images.Image("sqlite"); err != nil {
+				return err
+			}
+		}
+
+		// Create the key encrypting what the validator keeps in its bucket
+		bucketKey, err := kms.NewKey(ctx, "validator-bucket", &kms.KeyArgs{
+			Description:       pulumi.String("holesky-validator bucket"),
+			EnableKeyRotation: pulumi.Bool(true),
+		})
 		if err != nil {
 			return err
 		}
 
-		// Export the name of the bucket
+		// Create the bucket holding the validator's backups, it keeps the
+		// physical bucket the program was created with and newVault locks it down
+		bucket, err := s3.NewBucket(ctx, "validator-bucket", nil, pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String("my-bucket")}}))
+		if err != nil {
+			return err
+		}
+
+		validator := &validatorArgs{
+			Namespace:              getOrDefault(cfg, "namespace", "default"),
+			Image:                  image,
+			Network:                node.GetStringOutput(pulumi.String("network")),
+			BeaconNodeUrl:          node.GetStringOutput(pulumi.String("beaconApiUrl")),
+			FeeRecipient:           cfg.Require("feeRecipient"),
+			Graffiti:               cfg.Get("graffiti"),
+			KeystoresSecret:        getOrDefault(cfg, "keystoresSecret", "validator-keystores"),
+			PasswordsSecret:        getOrDefault(cfg, "passwordsSecret", "validator-passwords"),
+			StorageClass:           getOrDefault(cfg, "storageClass", "aws-gp3"),
+			StorageSize:            getOrDefault(cfg, "storageSize", "10Gi"),
+			BuilderProposals:       cfg.GetBool("builderProposals"),
+			InitSlashingProtection: cfg.GetBool("initSlashingProtection"),
+			Web3Signer:             web3signer,
+			DefinitionsImage:       definitionsImage,
+			Guard:                  guard,
+			SlashingProtection:     slashingProtection,
+			Bucket:                 bucket.ID().ToStringOutput(),
+			BucketArn:              bucket.Arn,
+			BucketKeyArn:           bucketKey.Arn,
+			Vault:                  vault,
+		}
+		if err := validator.validate(); err != nil {
+			return err
+		}
+		identity, err := getAwsIdentity(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if web3signer != nil {
+			if validator.SignerDatabase, err = newWeb3Signer(ctx, validator, identity); err != nil {
+				return err
+			}
+		}
+		validatorRoleArn, err := newValidatorClient(ctx, validator, identity)
+		if err != nil {
+			return err
+		}
+		if err := newVault(ctx, validator, bucket, validatorRoleArn); err != nil {
+			return err
+		}
+
 		ctx.Export("bucketName", bucket.ID())
+		ctx.Export("bucketKeyArn", bucketKey.Arn)
+		ctx.Export("images", images.Resolved())
+		ctx.Export("metricsHost", pulumi.Sprintf("%s-metrics.%s.svc.cluster.local", validatorName, validator.Namespace))
+		if web3signer != nil {
+			ctx.Export("web3signerMetricsHost", pulumi.Sprintf("%s-metrics.%s.svc.cluster.local", web3signerName, validator.Namespace))
+		}
 		return nil
 	})
 }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// SlashingProtectionConfig carries the eip-3076 slashing protection history
+// of the validator keys between the validator client here and a validator
+// elsewhere, like the lighthouse validator of the swannynode-fullnode host.
+// Interchange files are kept under interchange/ in the validator bucket,
+// encrypted with the bucket key.
+//
+// Export stops the validator client and uploads its history to the key, for
+// the next validator to import. Import downloads the key and imports it into
+// the validator client before it starts, once per key. An import merges
+// into the history already there, so every move needs a fresh export under a
+// new key, an older one would miss what was signed since.
+//
+// Stack config uses the json names, e.g.
+//
+//	holesky-validator:slashingProtection:
+//	  import: interchange/2026-10-17-fullnode.json
+type SlashingProtectionConfig struct {
+	// Image is the aws cli image moving interchange files, and KubectlImage
+	// waits for the stopped validator pod to be gone, main sets both from the
+	// image manifest
+	Image        string `json:"image"`
+	KubectlImage string `json:"kubectlImage"`
+	Export       string `json:"export"`
+	Import       string `json:"import"`
+}
+
+const (
+	interchangePrefix = "interchange/"
+	interchangeDir    = "/interchange"
+	interchangeFile   = "/interchange/interchange.json"
+	// importsDir in the data volume records the keys already imported
+	importsDir = validatorDataDir + "/slashing-protection-imports"
+)
+
+func (c *SlashingProtectionConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("slashingProtection image is required")
+	}
+	if c.Export != "" && c.KubectlImage == "" {
+		return fmt.Errorf("slashingProtection kubectlImage is required to export")
+	}
+	if (c.Export == "") == (c.Import == "") {
+		return fmt.Errorf("slashingProtection needs either an export or an import key")
+	}
+	for _, key := range []string{c.Export, c.Import} {
+		if key != "" && (!strings.HasPrefix(key, interchangePrefix) || !strings.HasSuffix(key, ".json")) {
+			return fmt.Errorf("slashingProtection key %q must be %s<name>.json", key, interchangePrefix)
+		}
+	}
+	return nil
+}
+
+// interchangeMarker is the file recording that key was imported
+func interchangeMarker(key string) string {
+	return fmt.Sprintf("%s/%s", importsDir, strings.ReplaceAll(strings.TrimPrefix(key, interchangePrefix), "/", "_"))
+}
+
+// fetchInterchangeScript downloads the interchange file, unless it was imported before
+const fetchInterchangeScript = `set -eu
+if [ -f "$MARKER" ]; then
+  echo "s3://$BUCKET/$KEY was imported before"
+  exit 0
+fi
+aws s3 cp "s3://$BUCKET/$KEY" "$FILE"
+`
+
+// importInterchangeScript imports the downloaded interchange file into the
+// slashing protection database, creating it when the validator never ran
+const importInterchangeScript = `set -eu
+if [ ! -f "$FILE" ]; then
+  exit 0
+fi
+lighthouse account validator slashing-protection import --network "$NETWORK" --datadir "$DATADIR" "$FILE"
+mkdir -p "$(dirname "$MARKER")"
+touch "$MARKER"
+echo "imported s3://$BUCKET/$KEY"
+`
+
+// waitForValidatorScript waits until the validator pod is gone. The
+// statefulset finishes scaling down while the pod is still terminating, and
+// it keeps signing until then.
+const waitForValidatorScript = `set -eu
+pod=$(kubectl get pod "$POD" --ignore-not-found -o name)
+if [ -n "$pod" ]; then
+  echo "waiting for $POD to stop"
+  kubectl wait --for=delete "pod/$POD" --timeout=10m
+fi
+`
+
+// exportInterchangeScript exports the slashing protection database of the stopped validator client
+const exportInterchangeScript = `set -eu
+lighthouse account validator slashing-protection export --network "$NETWORK" --datadir "$DATADIR" "$FILE"
+`
+
+// uploadInterchangeScript uploads the exported interchange file encrypted
+// with the bucket key, with the checksum object lock needs
+const uploadInterchangeScript = `set -eu
+aws s3 cp "$FILE" "s3://$BUCKET/$KEY" --sse aws:kms --sse-kms-key-id "$KMS_KEY" --checksum-algorithm SHA256
+echo "exported to s3://$BUCKET/$KEY"
+`
+
+// interchangeEnv is the environment of the interchange scripts for key
+func interchangeEnv(args *validatorArgs, key string) corev1.EnvVarArray {
+	return corev1.EnvVarArray{
+		corev1.EnvVarArgs{Name: pulumi.String("NETWORK"), Value: args.Network},
+		corev1.EnvVarArgs{Name: pulumi.String("DATADIR"), Value: pulumi.String(validatorDataDir)},
+		corev1.EnvVarArgs{Name: pulumi.String("BUCKET"), Value: args.Bucket},
+		corev1.EnvVarArgs{Name: pulumi.String("KMS_KEY"), Value: args.BucketKeyArn},
+		corev1.EnvVarArgs{Name: pulumi.String("KEY"), Value: pulumi.String(key)},
+		corev1.EnvVarArgs{Name: pulumi.String("FILE"), Value: pulumi.String(interchangeFile)},
+		corev1.EnvVarArgs{Name: pulumi.String("MARKER"), Value: pulumi.String(interchangeMarker(key))},
+	}
+}
+
+// interchangeImportContainers download and import the interchange file ahead of the validator client
+func interchangeImportContainers(args *validatorArgs, dataMount corev1.VolumeMountArgs) corev1.ContainerArray {
+	env := interchangeEnv(args, args.SlashingProtection.Import)
+	mounts := corev1.VolumeMountArray{
+		dataMount,
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String("interchange"),
+			MountPath: pulumi.String(interchangeDir),
+		},
+	}
+	return corev1.ContainerArray{
+		corev1.ContainerArgs{
+			Name:         pulumi.String("fetch-interchange"),
+			Image:        pulumi.String(args.SlashingProtection.Image),
+			Command:      pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(fetchInterchangeScript)},
+			Env:          env,
+			VolumeMounts: mounts,
+		},
+		corev1.ContainerArgs{
+			Name:         pulumi.String("import-interchange"),
+			Image:        pulumi.String(args.Image),
+			Command:      pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(importInterchangeScript)},
+			Env:          env,
+			VolumeMounts: mounts,
+		},
+	}
+}
+
+// newInterchangeExport creates the job exporting the slashing protection
+// history of the stopped validator client from its data volume to the
+// bucket, with the validator's service account
+func newInterchangeExport(ctx *pulumi.Context, args *validatorArgs, serviceAccountName pulumi.StringInput, validator pulumi.Resource) error {
+	key := args.SlashingProtection.Export
+	namespace := pulumi.String(args.Namespace)
+
+	env := interchangeEnv(args, key)
+	interchangeMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("interchange"),
+		MountPath: pulumi.String(interchangeDir),
+	}
+
+	// Create a role letting the job wait for the validator pod to be deleted
+	name := fmt.Sprintf("%s-export", validatorName)
+	role, err := rbacv1.NewRole(ctx, name, &rbacv1.RoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.StringArray{pulumi.String("pods")},
+				Verbs:     pulumi.ToStringArray([]string{"get", "list", "watch"}),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	roleBinding, err := rbacv1.NewRoleBinding(ctx, name, &rbacv1.RoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		RoleRef: &rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("Role"),
+			Name:     role.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccountName,
+				Namespace: namespace,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Create a job exporting the history once the validator client scaled
+	// down and its pod is gone, it is replaced whenever the export key changes
+	_, err = batchv1.NewJob(ctx, "slashing-protection-export", &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(4),
+			Template: &corev1.PodTemplateSpecArgs{
+				Spec: &corev1.PodSpecArgs{
+					RestartPolicy:      pulumi.String("Never"),
+					ServiceAccountName: serviceAccountName,
+					InitContainers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("wait-for-validator"),
+							Image:   pulumi.String(args.SlashingProtection.KubectlImage),
+							Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(waitForValidatorScript)},
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{Name: pulumi.String("POD"), Value: pulumi.Sprintf("%s-0", validatorName)},
+							},
+						},
+						corev1.ContainerArgs{
+							Name:    pulumi.String("export-interchange"),
+							Image:   pulumi.String(args.Image),
+							Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(exportInterchangeScript)},
+							Env:     env,
+							VolumeMounts: corev1.VolumeMountArray{
+								interchangeMount,
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("validator-data"),
+									MountPath: pulumi.String(validatorDataDir),
+								},
+							},
+						},
+					},
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:         pulumi.String("upload-interchange"),
+							Image:        pulumi.String(args.SlashingProtection.Image),
+							Command:      pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(uploadInterchangeScript)},
+							Env:          env,
+							VolumeMounts: corev1.VolumeMountArray{interchangeMount},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name:     pulumi.String("interchange"),
+							EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+						},
+						// the claim of the statefulset's only replica
+						corev1.VolumeArgs{
+							Name: pulumi.String("validator-data"),
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSourceArgs{
+								ClaimName: pulumi.Sprintf("validator-data-%s-0", validatorName),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{validator, roleBinding}))
+	return err
+}
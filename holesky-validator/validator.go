@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// validatorArgs describe the lighthouse validator client and the beacon node it attaches to
+type validatorArgs struct {
+	Namespace string
+	Image     string
+	// Network and BeaconNodeUrl come from the swannynode-holesky stack, so
+	// the validator always follows the node it is attached to
+	Network       pulumi.StringInput
+	BeaconNodeUrl pulumi.StringInput
+	// FeeRecipient receives the priority fees and mev of proposed blocks
+	FeeRecipient string
+	Graffiti     string
+	// KeystoresSecret holds the eip-2335 keystores as <name>.json, and
+	// PasswordsSecret the password of each one as <name>.txt
+	KeystoresSecret string
+	PasswordsSecret string
+	StorageClass    string
+	StorageSize     string
+	// BuilderProposals asks the beacon node for builder blocks, it needs mev-boost on the node
+	BuilderProposals bool
+	// InitSlashingProtection creates an empty slashing protection database.
+	// Only set it for the very first start of a validator, without it a lost
+	// data volume stops the validator instead of signing with no history.
+	InitSlashingProtection bool
+	// Web3Signer signs for the validator client instead of its local keystores when set
+	Web3Signer *Web3SignerConfig
+	// DefinitionsImage is the image with curl listing the keys of web3signer
+	DefinitionsImage string
+	// Guard only lets the validator client sign while it holds the lease when set
+	Guard *ValidatorGuardConfig
+	// SlashingProtection exports or imports the slashing protection history when set
+	SlashingProtection *SlashingProtectionConfig
+	// Bucket is the vault holding the backups and interchange files, encrypted with BucketKeyArn
+	Bucket       pulumi.StringInput
+	BucketArn    pulumi.StringInput
+	BucketKeyArn pulumi.StringInput
+	Vault        VaultConfig
+	// SignerDatabase is the slashing protection database of web3signer, backed up with the validator
+	SignerDatabase *web3signerConnection
+}
+
+const (
+	validatorName        = "lighthouse-validator"
+	validatorMetricsPort = 5064
+	validatorDataDir     = "/data"
+	keystoresDir         = "/keystores"
+	passwordsDir         = "/passwords"
+)
+
+var feeRecipientPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+func (args *validatorArgs) validate() error {
+	if !feeRecipientPattern.MatchString(args.FeeRecipient) {
+		return fmt.Errorf("feeRecipient %q must be a 0x prefixed address", args.FeeRecipient)
+	}
+	if len(args.Graffiti) > 32 {
+		return fmt.Errorf("graffiti is limited to 32 bytes")
+	}
+	if args.KeystoresSecret == "" || args.PasswordsSecret == "" {
+		return fmt.Errorf("keystoresSecret and passwordsSecret are required")
+	}
+	if args.Web3Signer != nil {
+		if args.DefinitionsImage == "" {
+			return fmt.Errorf("definitionsImage is required with web3signer")
+		}
+		if err := args.Web3Signer.validate(); err != nil {
+			return err
+		}
+	}
+	if args.Guard != nil {
+		if err := args.Guard.validate(); err != nil {
+			return err
+		}
+	}
+	if args.SlashingProtection != nil {
+		if err := args.SlashingProtection.validate(); err != nil {
+			return err
+		}
+	}
+	return args.Vault.validate()
+}
+
+// loadKeystoresScript copies the keystores from their secret into the data
+// volume, where lighthouse can lock them, and writes the validator
+// definitions pointing each one at its password. The passwords are read from
+// their secret and never written to disk. The definitions are rewritten on
+// every start, so removing a keystore from the secret disables it.
+const loadKeystoresScript = `set -eu
+validators="$DATADIR/validators"
+mkdir -p "$validators/keystores"
+rm -f "$validators/keystores/"*.json
+definitions="$validators/validator_definitions.yml"
+echo "---" > "$definitions.new"
+count=0
+for keystore in "$KEYSTORES"/*.json; do
+  [ -e "$keystore" ] || continue
+  name="$(basename "$keystore" .json)"
+  password="$PASSWORDS/$name.txt"
+  if [ ! -f "$password" ]; then
+    echo "no password $name.txt for keystore $name.json" >&2
+    exit 1
+  fi
+  pubkey="$(tr -d ' \n' < "$keystore" | sed -n 's/.*"pubkey":"\(0x\)\{0,1\}\([0-9a-fA-F]*\)".*/\2/p')"
+  if [ -z "$pubkey" ]; then
+    echo "keystore $name.json has no pubkey" >&2
+    exit 1
+  fi
+  cp "$keystore" "$validators/keystores/$name.json"
+  cat >> "$definitions.new" <<EOF
+- enabled: true
+  voting_public_key: "0x$pubkey"
+  type: local_keystore
+  voting_keystore_path: $validators/keystores/$name.json
+  voting_keystore_password_path: $password
+EOF
+  count=$((count + 1))
+done
+if [ "$count" -eq 0 ]; then
+  echo "no keystores in $KEYSTORES" >&2
+  exit 1
+fi
+mv "$definitions.new" "$definitions"
+echo "loaded $count keystores"
+`
+
+// loadSignerKeysDefinitionsScript writes validator definitions for every key
+// web3signer holds, waiting for web3signer to list them. Like the keystores,
+// the definitions are rewritten on every start.
+const loadSignerKeysDefinitionsScript = `set -eu
+validators="$DATADIR/validators"
+mkdir -p "$validators"
+definitions="$validators/validator_definitions.yml"
+attempt=0
+until keys="$(curl -sf "$SIGNER/api/v1/eth2/publicKeys")"; do
+  attempt=$((attempt + 1))
+  if [ "$attempt" -ge 60 ]; then
+    echo "web3signer at $SIGNER did not list its keys" >&2
+    exit 1
+  fi
+  sleep 5
+done
+echo "---" > "$definitions.new"
+count=0
+for pubkey in $(echo "$keys" | tr -d '[]" \n' | tr ',' ' '); do
+  cat >> "$definitions.new" <<EOF
+- enabled: true
+  voting_public_key: "$pubkey"
+  type: web3signer
+  url: "$SIGNER"
+EOF
+  count=$((count + 1))
+done
+if [ "$count" -eq 0 ]; then
+  echo "web3signer at $SIGNER has no keys" >&2
+  exit 1
+fi
+mv "$definitions.new" "$definitions"
+echo "loaded $count web3signer keys"
+`
+
+// newValidatorClient creates the lighthouse validator client statefulset,
+// its metrics service and its backups, returning the arn of the iam role of
+// the validator pod
+func newValidatorClient(ctx *pulumi.Context, args *validatorArgs, identity *awsIdentity) (pulumi.StringOutput, error) {
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(validatorName)}
+
+	dataMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("validator-data"),
+		MountPath: pulumi.String(validatorDataDir),
+	}
+	passwordsMount := corev1.VolumeMountArgs{
+		Name:      pulumi.String("passwords"),
+		MountPath: pulumi.String(passwordsDir),
+		ReadOnly:  pulumi.Bool(true),
+	}
+
+	command := pulumi.StringArray{
+		pulumi.String("lighthouse"),
+		pulumi.String("vc"),
+		pulumi.String("--network"), args.Network,
+		pulumi.String("--datadir"), pulumi.String(validatorDataDir),
+		pulumi.String("--beacon-nodes"), args.BeaconNodeUrl,
+		pulumi.String("--suggested-fee-recipient"), pulumi.String(args.FeeRecipient),
+		// only the keystores written into the definitions are loaded
+		pulumi.String("--disable-auto-discover"),
+		// every start sits out two to three epochs watching for the keys
+		// attesting elsewhere, and exits instead of signing if they do
+		pulumi.String("--enable-doppelganger-protection"),
+		pulumi.String("--metrics"),
+		pulumi.String("--metrics-address"), pulumi.String("0.0.0.0"),
+		pulumi.String("--metrics-port"), pulumi.Sprintf("%d", validatorMetricsPort),
+	}
+	if args.Graffiti != "" {
+		command = append(command, pulumi.String("--graffiti"), pulumi.String(args.Graffiti))
+	}
+	if args.BuilderProposals {
+		command = append(command, pulumi.String("--builder-proposals"))
+	}
+	if args.InitSlashingProtection {
+		command = append(command, pulumi.String("--init-slashing-protection"))
+	}
+
+	// the definitions come from the keystores secret, or from the keys web3signer holds
+	loadKeys := corev1.ContainerArgs{
+		Name:    pulumi.String("load-keystores"),
+		Image:   pulumi.String(args.Image),
+		Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(loadKeystoresScript)},
+		Env: corev1.EnvVarArray{
+			corev1.EnvVarArgs{Name: pulumi.String("DATADIR"), Value: pulumi.String(validatorDataDir)},
+			corev1.EnvVarArgs{Name: pulumi.String("KEYSTORES"), Value: pulumi.String(keystoresDir)},
+			corev1.EnvVarArgs{Name: pulumi.String("PASSWORDS"), Value: pulumi.String(passwordsDir)},
+		},
+		VolumeMounts: corev1.VolumeMountArray{
+			dataMount,
+			passwordsMount,
+			corev1.VolumeMountArgs{
+				Name:      pulumi.String("keystores"),
+				MountPath: pulumi.String(keystoresDir),
+				ReadOnly:  pulumi.Bool(true),
+			},
+		},
+	}
+	validatorMounts := corev1.VolumeMountArray{dataMount, passwordsMount}
+	volumes := corev1.VolumeArray{
+		corev1.VolumeArgs{
+			Name: pulumi.String("keystores"),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName: pulumi.String(args.KeystoresSecret),
+			},
+		},
+		corev1.VolumeArgs{
+			Name: pulumi.String("passwords"),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName:  pulumi.String(args.PasswordsSecret),
+				DefaultMode: pulumi.Int(0400),
+			},
+		},
+	}
+	if args.Web3Signer != nil {
+		loadKeys = corev1.ContainerArgs{
+			Name:    pulumi.String("load-web3signer-keys"),
+			Image:   pulumi.String(args.DefinitionsImage),
+			Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(loadSignerKeysDefinitionsScript)},
+			Env: corev1.EnvVarArray{
+				corev1.EnvVarArgs{Name: pulumi.String("DATADIR"), Value: pulumi.String(validatorDataDir)},
+				corev1.EnvVarArgs{Name: pulumi.String("SIGNER"), Value: pulumi.String(web3signerUrl(args.Namespace))},
+			},
+			// the curl image runs as a user that cannot write the data volume
+			SecurityContext: &corev1.SecurityContextArgs{
+				RunAsUser: pulumi.Int(0),
+			},
+			VolumeMounts: corev1.VolumeMountArray{dataMount},
+		}
+		validatorMounts = corev1.VolumeMountArray{dataMount}
+		volumes = nil
+	}
+
+	// iam policy statements of the validator pod, the backups and interchange files share its role
+	statements := vaultPolicy(args)
+	initContainers := corev1.ContainerArray{loadKeys}
+	if args.SlashingProtection != nil && args.SlashingProtection.Import != "" {
+		initContainers = append(interchangeImportContainers(args, dataMount), initContainers...)
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name:     pulumi.String("interchange"),
+			EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+		})
+	}
+	if args.Guard != nil {
+		guardConfig, guardStatements, err := newValidatorGuard(ctx, args, identity)
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+		statements = append(statements, guardStatements...)
+		command = guardCommand(command)
+		guardMount := corev1.VolumeMountArgs{
+			Name:      pulumi.String("guard"),
+			MountPath: pulumi.String(guardDir),
+		}
+		// the guard is copied out of its own image, the validator image does not ship it
+		initContainers = append(initContainers, corev1.ContainerArgs{
+			Name:         pulumi.String("install-guard"),
+			Image:        pulumi.String(args.Guard.Image),
+			Args:         pulumi.StringArray{pulumi.String("install"), pulumi.Sprintf("%s/validator-guard", guardDir)},
+			VolumeMounts: corev1.VolumeMountArray{guardMount},
+		})
+		validatorMounts = append(validatorMounts, guardMount, corev1.VolumeMountArgs{
+			Name:      pulumi.String("guard-config"),
+			MountPath: pulumi.String(guardConfigDir),
+			ReadOnly:  pulumi.Bool(true),
+		})
+		volumes = append(volumes,
+			corev1.VolumeArgs{
+				Name:     pulumi.String("guard"),
+				EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+			},
+			corev1.VolumeArgs{
+				Name: pulumi.String("guard-config"),
+				ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+					Name: guardConfig,
+				},
+			},
+		)
+	}
+
+	// Create the service account of the validator pod with its iam role
+	policy := pulumi.JSONMarshal(pulumi.Map{
+		"Version":   pulumi.String("2012-10-17"),
+		"Statement": statements,
+	})
+	role, err := newIrsaRole(ctx, validatorName, identity, args.Namespace, validatorName, policy)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	serviceAccount, err := corev1.NewServiceAccount(ctx, validatorName, &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(validatorName),
+			Namespace: namespace,
+			Annotations: pulumi.StringMap{
+				"eks.amazonaws.com/role-arn": role.Arn,
+			},
+		},
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	// Create a headless service governing the statefulset, prometheus scrapes the validator through it
+	metricsService, err := corev1.NewService(ctx, fmt.Sprintf("%s-metrics", validatorName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:  labels,
+			ClusterIP: pulumi.String("None"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(validatorMetricsPort),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-metrics", validatorName),
+			Namespace: namespace,
+		},
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	// Create the validator client statefulset. It never runs more than one
+	// replica, the statefulset stops the old pod before it starts a new one,
+	// so the keys are never signing in two places at once. A node that drops
+	// out of the cluster can keep its pod running though, the guard's lease
+	// covers that case and validators outside the cluster.
+	replicas := 1
+	if args.SlashingProtection != nil && args.SlashingProtection.Export != "" {
+		// an exported history is only complete once the validator stopped signing
+		replicas = 0
+	}
+	validator, err := appsv1.NewStatefulSet(ctx, validatorName, &appsv1.StatefulSetArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(validatorName),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.StatefulSetSpecArgs{
+			Replicas:    pulumi.Int(replicas),
+			ServiceName: metricsService.Metadata.Name().Elem(),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			VolumeClaimTemplates: corev1.PersistentVolumeClaimTypeArray{
+				corev1.PersistentVolumeClaimTypeArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Name: pulumi.String("validator-data"),
+					},
+					Spec: &corev1.PersistentVolumeClaimSpecArgs{
+						AccessModes: pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+						Resources: &corev1.VolumeResourceRequirementsArgs{
+							Requests: pulumi.StringMap{
+								"storage": pulumi.String(args.StorageSize),
+							},
+						},
+						StorageClassName: pulumi.String(args.StorageClass),
+					},
+				},
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount.Metadata.Name(),
+					InitContainers:     initContainers,
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String(validatorName),
+							Image:   pulumi.String(args.Image),
+							Command: command,
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("metrics"),
+									ContainerPort: pulumi.Int(validatorMetricsPort),
+								},
+							},
+							VolumeMounts: validatorMounts,
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("100m"),
+									"memory": pulumi.String("256Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("1Gi"),
+								},
+							},
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	serviceAccountName := serviceAccount.Metadata.Name().Elem()
+	if replicas == 0 {
+		err = newInterchangeExport(ctx, args, serviceAccountName, validator)
+	} else {
+		// the backups run next to the validator pod, there is none while exporting
+		err = newValidatorBackup(ctx, args, serviceAccountName)
+	}
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	return role.Arn, nil
+}
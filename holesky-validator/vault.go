@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// VaultConfig turns the validator bucket into a vault for keystore and
+// slashing protection backups. Objects are encrypted with the bucket key,
+// versioned and locked for LockDays, and only the validator's iam role, plus
+// Principals, can read or write them. Nobody can delete them, old backups
+// only go through the lifecycle rules.
+//
+// Stack config uses the json names, e.g.
+//
+//	holesky-validator:vault:
+//	  backupSchedule: "0 */6 * * *"
+//	  backupRetentionDays: 180
+//	  principals:
+//	    - arn:aws:iam::123456789012:role/swannynode-fullnode
+type VaultConfig struct {
+	// BackupImage is the aws cli image uploading backups, main sets it from the image manifest
+	BackupImage string `json:"backupImage"`
+	// SnapshotImage is the sqlite image snapshotting the slashing protection
+	// database for the backups, main sets it from the image manifest
+	SnapshotImage string `json:"snapshotImage"`
+	// LockMode is GOVERNANCE, or COMPLIANCE which not even the account root can shorten
+	LockMode string `json:"lockMode"`
+	LockDays int    `json:"lockDays"`
+	// BackupRetentionDays is how long a backup is kept, NoncurrentRetentionDays
+	// how long an overwritten interchange file or backup is
+	BackupRetentionDays     int `json:"backupRetentionDays"`
+	NoncurrentRetentionDays int `json:"noncurrentRetentionDays"`
+	// BackupSchedule is the cron schedule of the backup job
+	BackupSchedule string `json:"backupSchedule"`
+	// Principals are other iam role arns with access to the objects, like the
+	// instance role of a host moving slashing protection history
+	Principals []string `json:"principals"`
+}
+
+const (
+	backupsPrefix                  = "backups/"
+	defaultVaultLockMode           = "GOVERNANCE"
+	defaultVaultLockDays           = 30
+	defaultBackupRetentionDays     = 90
+	defaultNoncurrentRetentionDays = 30
+	defaultBackupSchedule          = "17 */6 * * *"
+)
+
+func (c *VaultConfig) validate() error {
+	if c.BackupImage == "" {
+		return fmt.Errorf("vault backupImage is required")
+	}
+	if c.SnapshotImage == "" {
+		return fmt.Errorf("vault snapshotImage is required")
+	}
+	if c.LockMode == "" {
+		c.LockMode = defaultVaultLockMode
+	}
+	if c.LockMode != "GOVERNANCE" && c.LockMode != "COMPLIANCE" {
+		return fmt.Errorf("vault lockMode %q must be GOVERNANCE or COMPLIANCE", c.LockMode)
+	}
+	if c.LockDays == 0 {
+		c.LockDays = defaultVaultLockDays
+	}
+	if c.BackupRetentionDays == 0 {
+		c.BackupRetentionDays = defaultBackupRetentionDays
+	}
+	if c.NoncurrentRetentionDays == 0 {
+		c.NoncurrentRetentionDays = defaultNoncurrentRetentionDays
+	}
+	if c.BackupSchedule == "" {
+		c.BackupSchedule = defaultBackupSchedule
+	}
+	if c.LockDays < 0 || c.NoncurrentRetentionDays < 0 {
+		return fmt.Errorf("vault lockDays and noncurrentRetentionDays cannot be negative")
+	}
+	// a backup expiring while locked would only leave a delete marker on it
+	if c.BackupRetentionDays < c.LockDays {
+		return fmt.Errorf("vault backupRetentionDays must be at least lockDays")
+	}
+	// s3 won't expire a noncurrent version that is still locked, so a shorter
+	// retention would silently keep it until the lock ends
+	if c.NoncurrentRetentionDays < c.LockDays {
+		return fmt.Errorf("vault noncurrentRetentionDays must be at least lockDays")
+	}
+	return nil
+}
+
+// vaultPolicy is what the validator's iam role may do in the vault: write
+// backups, and read and write interchange files
+func vaultPolicy(args *validatorArgs) pulumi.Array {
+	return pulumi.Array{
+		pulumi.Map{
+			"Effect": pulumi.String("Allow"),
+			"Action": pulumi.String("s3:PutObject"),
+			"Resource": pulumi.Array{
+				pulumi.Sprintf("%s/%s*", args.BucketArn, backupsPrefix),
+				pulumi.Sprintf("%s/%s*", args.BucketArn, interchangePrefix),
+			},
+		},
+		pulumi.Map{
+			"Effect":   pulumi.String("Allow"),
+			"Action":   pulumi.String("s3:GetObject"),
+			"Resource": pulumi.Sprintf("%s/%s*", args.BucketArn, interchangePrefix),
+		},
+		pulumi.Map{
+			"Effect":   pulumi.String("Allow"),
+			"Action":   pulumi.ToStringArray([]string{"kms:GenerateDataKey", "kms:Encrypt", "kms:Decrypt"}),
+			"Resource": args.BucketKeyArn,
+		},
+	}
+}
+
+// newVault encrypts, versions, locks and closes off the validator bucket,
+// limiting its objects to the validator's iam role
+func newVault(ctx *pulumi.Context, args *validatorArgs, bucket *s3.Bucket, validatorRoleArn pulumi.StringInput) error {
+	vault := args.Vault
+
+	_, err := s3.NewBucketPublicAccessBlock(ctx, "validator-bucket", &s3.BucketPublicAccessBlockArgs{
+		Bucket:                bucket.ID(),
+		BlockPublicAcls:       pulumi.Bool(true),
+		BlockPublicPolicy:     pulumi.Bool(true),
+		IgnorePublicAcls:      pulumi.Bool(true),
+		RestrictPublicBuckets: pulumi.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s3.NewBucketServerSideEncryptionConfigurationV2(ctx, "validator-bucket", &s3.BucketServerSideEncryptionConfigurationV2Args{
+		Bucket: bucket.ID(),
+		Rules: s3.BucketServerSideEncryptionConfigurationV2RuleArray{
+			s3.BucketServerSideEncryptionConfigurationV2RuleArgs{
+				ApplyServerSideEncryptionByDefault: &s3.BucketServerSideEncryptionConfigurationV2RuleApplyServerSideEncryptionByDefaultArgs{
+					SseAlgorithm:   pulumi.String("aws:kms"),
+					KmsMasterKeyId: args.BucketKeyArn,
+				},
+				// one data key per object for a while instead of a kms call per request
+				BucketKeyEnabled: pulumi.Bool(true),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	versioning, err := s3.NewBucketVersioningV2(ctx, "validator-bucket", &s3.BucketVersioningV2Args{
+		Bucket: bucket.ID(),
+		VersioningConfiguration: &s3.BucketVersioningV2VersioningConfigurationArgs{
+			Status: pulumi.String("Enabled"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Object lock needs versioning, it is enabled on the existing bucket
+	// rather than replacing it
+	_, err = s3.NewBucketObjectLockConfigurationV2(ctx, "validator-bucket", &s3.BucketObjectLockConfigurationV2Args{
+		Bucket:            bucket.ID(),
+		ObjectLockEnabled: pulumi.String("Enabled"),
+		Rule: &s3.BucketObjectLockConfigurationV2RuleArgs{
+			DefaultRetention: &s3.BucketObjectLockConfigurationV2RuleDefaultRetentionArgs{
+				Mode: pulumi.String(vault.LockMode),
+				Days: pulumi.Int(vault.LockDays),
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{versioning}))
+	if err != nil {
+		return err
+	}
+
+	abortUploads := &s3.BucketLifecycleConfigurationV2RuleAbortIncompleteMultipartUploadArgs{
+		DaysAfterInitiation: pulumi.Int(7),
+	}
+	noncurrent := &s3.BucketLifecycleConfigurationV2RuleNoncurrentVersionExpirationArgs{
+		NoncurrentDays: pulumi.Int(vault.NoncurrentRetentionDays),
+	}
+	_, err = s3.NewBucketLifecycleConfigurationV2(ctx, "validator-bucket", &s3.BucketLifecycleConfigurationV2Args{
+		Bucket: bucket.ID(),
+		Rules: s3.BucketLifecycleConfigurationV2RuleArray{
+			s3.BucketLifecycleConfigurationV2RuleArgs{
+				Id:     pulumi.String("backups"),
+				Status: pulumi.String("Enabled"),
+				Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{
+					Prefix: pulumi.String(backupsPrefix),
+				},
+				Expiration: &s3.BucketLifecycleConfigurationV2RuleExpirationArgs{
+					Days: pulumi.Int(vault.BackupRetentionDays),
+				},
+				NoncurrentVersionExpiration:    noncurrent,
+				AbortIncompleteMultipartUpload: abortUploads,
+			},
+			// interchange files are kept, only their overwritten versions expire
+			s3.BucketLifecycleConfigurationV2RuleArgs{
+				Id:     pulumi.String("interchange"),
+				Status: pulumi.String("Enabled"),
+				Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{
+					Prefix: pulumi.String(interchangePrefix),
+				},
+				NoncurrentVersionExpiration:    noncurrent,
+				AbortIncompleteMultipartUpload: abortUploads,
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{versioning}))
+	if err != nil {
+		return err
+	}
+
+	principals := pulumi.Array{validatorRoleArn}
+	for _, principal := range vault.Principals {
+		principals = append(principals, pulumi.String(principal))
+	}
+	objects := pulumi.Sprintf("%s/*", bucket.Arn)
+
+	// The policy leaves the bucket's own settings to the account, so the
+	// program keeps managing them, and closes off its objects
+	_, err = s3.NewBucketPolicy(ctx, "validator-bucket", &s3.BucketPolicyArgs{
+		Bucket: bucket.ID(),
+		Policy: pulumi.JSONMarshal(pulumi.Map{
+			"Version": pulumi.String("2012-10-17"),
+			"Statement": pulumi.Array{
+				pulumi.Map{
+					"Sid":       pulumi.String("DenyInsecureTransport"),
+					"Effect":    pulumi.String("Deny"),
+					"Principal": pulumi.String("*"),
+					"Action":    pulumi.String("s3:*"),
+					"Resource":  pulumi.Array{bucket.Arn, objects},
+					"Condition": pulumi.Map{
+						"Bool": pulumi.Map{"aws:SecureTransport": pulumi.String("false")},
+					},
+				},
+				pulumi.Map{
+					"Sid":       pulumi.String("DenyObjectAccessOutsideValidator"),
+					"Effect":    pulumi.String("Deny"),
+					"Principal": pulumi.String("*"),
+					"Action":    pulumi.ToStringArray([]string{"s3:GetObject*", "s3:PutObject*", "s3:RestoreObject"}),
+					"Resource":  objects,
+					"Condition": pulumi.Map{
+						"ArnNotEquals": pulumi.Map{"aws:PrincipalArn": principals},
+					},
+				},
+				pulumi.Map{
+					"Sid":       pulumi.String("DenyDeletes"),
+					"Effect":    pulumi.String("Deny"),
+					"Principal": pulumi.String("*"),
+					"Action":    pulumi.ToStringArray([]string{"s3:DeleteObject*", "s3:BypassGovernanceRetention"}),
+					"Resource":  objects,
+				},
+			},
+		}),
+	})
+	return err
+}
@@ -0,0 +1,638 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Web3SignerConfig moves the validator keys out of the validator client into
+// web3signer, which signs for it over http and keeps its slashing protection
+// in postgres. The keys come from the keystores and passwords secrets of the
+// validator, or from aws secrets manager as raw bls keys, and the validator
+// client only learns their public keys.
+//
+// Stack config uses the json names, e.g.
+//
+//	holesky-validator:web3signer:
+//	  keySource: secretsManager
+//	  secretsManagerPrefix: holesky-validator/
+//	  database:
+//	    mode: rds
+//	    host: web3signer.abc123.us-east-2.rds.amazonaws.com
+type Web3SignerConfig struct {
+	// Image, MigrationsImage (flyway) and Database.Image default to the image manifest
+	Image           string `json:"image"`
+	MigrationsImage string `json:"migrationsImage"`
+	// Replicas can be above one, the shared slashing protection database
+	// keeps them from signing anything slashable between them
+	Replicas int `json:"replicas"`
+	// KeySource is secret or secretsManager
+	KeySource string `json:"keySource"`
+	// SecretsManagerPrefix selects the secrets holding the keys by name
+	SecretsManagerPrefix string             `json:"secretsManagerPrefix"`
+	Database             Web3SignerDatabase `json:"database"`
+}
+
+// Web3SignerDatabase is the postgres database holding the slashing protection
+// of web3signer. Its password is the web3signerDbPassword config secret,
+// generated when unset for the in-cluster database.
+type Web3SignerDatabase struct {
+	// Mode is inCluster, running postgres next to web3signer, or rds
+	Mode string `json:"mode"`
+	// Image and StorageSize are for the in-cluster postgres
+	Image       string `json:"image"`
+	StorageSize string `json:"storageSize"`
+	// Host and Port are the endpoint of the rds instance
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	// Password is the web3signerDbPassword config secret rather than part of the object
+	Password pulumi.StringInput `json:"-"`
+}
+
+// Key sources and database modes of web3signer
+const (
+	KeysFromSecret         = "secret"
+	KeysFromSecretsManager = "secretsManager"
+	DatabaseInCluster      = "inCluster"
+	DatabaseRds            = "rds"
+)
+
+const (
+	web3signerName           = "web3signer"
+	web3signerPort           = 9000
+	web3signerMetricsPort    = 9001
+	web3signerKeysDir        = "/keys"
+	web3signerMigrationsDir  = "/opt/web3signer/migrations/postgresql"
+	web3signerPostgres       = "web3signer-postgres"
+	defaultWeb3signerDbName  = "web3signer"
+	defaultWeb3signerDbUser  = "web3signer"
+	defaultWeb3signerDbPort  = 5432
+	defaultWeb3signerDbSize  = "10Gi"
+	web3signerPasswordSecret = "web3signer-db"
+)
+
+func (c *Web3SignerConfig) validate() error {
+	if c.Image == "" || c.MigrationsImage == "" {
+		return fmt.Errorf("web3signer image and migrationsImage are required")
+	}
+	if c.Replicas == 0 {
+		c.Replicas = 1
+	}
+	if c.Replicas < 0 {
+		return fmt.Errorf("web3signer replicas cannot be negative")
+	}
+	if c.KeySource == "" {
+		c.KeySource = KeysFromSecret
+	}
+	switch c.KeySource {
+	case KeysFromSecret:
+	case KeysFromSecretsManager:
+		if c.SecretsManagerPrefix == "" {
+			return fmt.Errorf("web3signer secretsManagerPrefix is required to load keys from secrets manager")
+		}
+	default:
+		return fmt.Errorf("web3signer keySource %q must be %s or %s", c.KeySource, KeysFromSecret, KeysFromSecretsManager)
+	}
+
+	db := &c.Database
+	if db.Mode == "" {
+		db.Mode = DatabaseInCluster
+	}
+	if db.Name == "" {
+		db.Name = defaultWeb3signerDbName
+	}
+	if db.Username == "" {
+		db.Username = defaultWeb3signerDbUser
+	}
+	if db.Port == 0 {
+		db.Port = defaultWeb3signerDbPort
+	}
+	switch db.Mode {
+	case DatabaseInCluster:
+		if db.Image == "" {
+			return fmt.Errorf("web3signer database image is required for the in-cluster postgres")
+		}
+		if db.Host != "" {
+			return fmt.Errorf("web3signer database host only applies to rds")
+		}
+		if db.StorageSize == "" {
+			db.StorageSize = defaultWeb3signerDbSize
+		}
+	case DatabaseRds:
+		if db.Host == "" || db.Password == nil {
+			return fmt.Errorf("web3signer rds needs a database host and the web3signerDbPassword config secret")
+		}
+	default:
+		return fmt.Errorf("web3signer database mode %q must be %s or %s", db.Mode, DatabaseInCluster, DatabaseRds)
+	}
+	return nil
+}
+
+// web3signerUrl is where the validator client reaches web3signer
+func web3signerUrl(namespace string) string {
+	return fmt.Sprintf("http://%s.%s:%d", web3signerName, namespace, web3signerPort)
+}
+
+// loadSignerKeysScript writes a web3signer key config for every keystore in
+// the keystores secret, reading it and its password in place
+const loadSignerKeysScript = `set -eu
+count=0
+for keystore in "$KEYSTORES"/*.json; do
+  [ -e "$keystore" ] || continue
+  name="$(basename "$keystore" .json)"
+  password="$PASSWORDS/$name.txt"
+  if [ ! -f "$password" ]; then
+    echo "no password $name.txt for keystore $name.json" >&2
+    exit 1
+  fi
+  cat > "$KEYS/$name.yaml" <<EOF
+type: file-keystore
+keyType: BLS
+keystoreFile: $keystore
+keystorePasswordFile: $password
+EOF
+  count=$((count + 1))
+done
+if [ "$count" -eq 0 ]; then
+  echo "no keystores in $KEYSTORES" >&2
+  exit 1
+fi
+echo "configured $count keys"
+`
+
+// web3signerConnection is how to reach the slashing protection database of
+// web3signer, PasswordEnv reads its password from the database secret
+type web3signerConnection struct {
+	Host        string
+	Port        int
+	Name        string
+	Username    string
+	PasswordEnv corev1.EnvVarArgs
+}
+
+// newWeb3Signer creates web3signer, its slashing protection database and the
+// job migrating the database schema, which completes before web3signer starts.
+// It returns the connection to the database for the backups.
+func newWeb3Signer(ctx *pulumi.Context, args *validatorArgs, identity *awsIdentity) (*web3signerConnection, error) {
+	signer := args.Web3Signer
+	db := signer.Database
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(web3signerName)}
+
+	// the password of an in-cluster database is generated unless the stack pins one
+	password := db.Password
+	if password == nil {
+		generated, err := random.NewRandomPassword(ctx, web3signerPasswordSecret, &random.RandomPasswordArgs{
+			Length:  pulumi.Int(32),
+			Special: pulumi.Bool(false),
+		})
+		if err != nil {
+			return nil, err
+		}
+		password = generated.Result
+	}
+
+	// Create a secret with the database password, shared by postgres, the migrations and web3signer
+	passwordSecret, err := corev1.NewSecret(ctx, web3signerPasswordSecret, &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		StringData: pulumi.StringMap{
+			"password": password.ToStringOutput(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	passwordEnv := corev1.EnvVarArgs{
+		Name: pulumi.String("DB_PASSWORD"),
+		ValueFrom: &corev1.EnvVarSourceArgs{
+			SecretKeyRef: &corev1.SecretKeySelectorArgs{
+				Name: passwordSecret.Metadata.Name(),
+				Key:  pulumi.String("password"),
+			},
+		},
+	}
+
+	host := db.Host
+	var migrationsDependsOn []pulumi.Resource
+	if db.Mode == DatabaseInCluster {
+		database, err := newWeb3SignerPostgres(ctx, args, passwordEnv)
+		if err != nil {
+			return nil, err
+		}
+		host = fmt.Sprintf("%s.%s", web3signerPostgres, args.Namespace)
+		migrationsDependsOn = append(migrationsDependsOn, database)
+	}
+	jdbcUrl := fmt.Sprintf("jdbc:postgresql://%s:%d/%s", host, db.Port, db.Name)
+
+	// Create a job applying the slashing protection schema shipped with
+	// web3signer. The job is replaced whenever web3signer is upgraded, and
+	// web3signer waits for it to complete.
+	migrations, err := batchv1.NewJob(ctx, fmt.Sprintf("%s-migrations", web3signerName), &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(4),
+			Template: &corev1.PodTemplateSpecArgs{
+				Spec: &corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("Never"),
+					InitContainers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("copy-migrations"),
+							Image:   pulumi.String(signer.Image),
+							Command: pulumi.ToStringArray([]string{"sh", "-c", fmt.Sprintf("cp %s/*.sql /migrations/", web3signerMigrationsDir)}),
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("migrations"),
+									MountPath: pulumi.String("/migrations"),
+								},
+							},
+						},
+					},
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("migrate"),
+							Image: pulumi.String(signer.MigrationsImage),
+							Args: pulumi.ToStringArray([]string{
+								fmt.Sprintf("-url=%s", jdbcUrl),
+								fmt.Sprintf("-user=%s", db.Username),
+								"-password=$(DB_PASSWORD)",
+								"-locations=filesystem:/migrations",
+								// the database may still be starting
+								"-connectRetries=60",
+								"migrate",
+							}),
+							Env: corev1.EnvVarArray{passwordEnv},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("migrations"),
+									MountPath: pulumi.String("/migrations"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name:     pulumi.String("migrations"),
+							EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn(migrationsDependsOn))
+	if err != nil {
+		return nil, err
+	}
+
+	flags := pulumi.StringArray{
+		pulumi.String("--http-listen-host=0.0.0.0"),
+		pulumi.Sprintf("--http-listen-port=%d", web3signerPort),
+		pulumi.String("--http-host-allowlist=*"),
+		pulumi.String("--metrics-enabled=true"),
+		pulumi.String("--metrics-host=0.0.0.0"),
+		pulumi.Sprintf("--metrics-port=%d", web3signerMetricsPort),
+		pulumi.String("--metrics-host-allowlist=*"),
+	}
+	if signer.KeySource == KeysFromSecret {
+		flags = append(flags, pulumi.Sprintf("--key-store-path=%s", web3signerKeysDir))
+	}
+	flags = append(flags,
+		pulumi.String("eth2"),
+		pulumi.Sprintf("--network=%s", args.Network),
+		pulumi.String("--slashing-protection-enabled=true"),
+		pulumi.Sprintf("--slashing-protection-db-url=%s", jdbcUrl),
+		pulumi.Sprintf("--slashing-protection-db-username=%s", db.Username),
+		pulumi.String("--slashing-protection-db-password=$(DB_PASSWORD)"),
+	)
+
+	var initContainers corev1.ContainerArrayInput
+	volumeMounts := corev1.VolumeMountArray{}
+	volumes := corev1.VolumeArray{}
+	var serviceAccountName pulumi.StringPtrInput
+	if signer.KeySource == KeysFromSecret {
+		keysMounts := corev1.VolumeMountArray{
+			corev1.VolumeMountArgs{
+				Name:      pulumi.String("keys"),
+				MountPath: pulumi.String(web3signerKeysDir),
+			},
+			corev1.VolumeMountArgs{
+				Name:      pulumi.String("keystores"),
+				MountPath: pulumi.String(keystoresDir),
+				ReadOnly:  pulumi.Bool(true),
+			},
+			corev1.VolumeMountArgs{
+				Name:      pulumi.String("passwords"),
+				MountPath: pulumi.String(passwordsDir),
+				ReadOnly:  pulumi.Bool(true),
+			},
+		}
+		volumeMounts = keysMounts
+		volumes = corev1.VolumeArray{
+			corev1.VolumeArgs{
+				Name:     pulumi.String("keys"),
+				EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+			},
+			corev1.VolumeArgs{
+				Name: pulumi.String("keystores"),
+				Secret: &corev1.SecretVolumeSourceArgs{
+					SecretName: pulumi.String(args.KeystoresSecret),
+				},
+			},
+			corev1.VolumeArgs{
+				Name: pulumi.String("passwords"),
+				Secret: &corev1.SecretVolumeSourceArgs{
+					SecretName:  pulumi.String(args.PasswordsSecret),
+					DefaultMode: pulumi.Int(0400),
+				},
+			},
+		}
+		initContainers = corev1.ContainerArray{
+			corev1.ContainerArgs{
+				Name:    pulumi.String("load-keys"),
+				Image:   pulumi.String(signer.Image),
+				Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(loadSignerKeysScript)},
+				Env: corev1.EnvVarArray{
+					corev1.EnvVarArgs{Name: pulumi.String("KEYS"), Value: pulumi.String(web3signerKeysDir)},
+					corev1.EnvVarArgs{Name: pulumi.String("KEYSTORES"), Value: pulumi.String(keystoresDir)},
+					corev1.EnvVarArgs{Name: pulumi.String("PASSWORDS"), Value: pulumi.String(passwordsDir)},
+				},
+				VolumeMounts: keysMounts,
+			},
+		}
+	} else {
+		// secrets manager is read with the web identity of the web3signer service account
+		secretArn := fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s*", identity.Region, identity.AccountId, signer.SecretsManagerPrefix)
+		policy, err := json.Marshal(map[string]interface{}{
+			"Version": "2012-10-17",
+			"Statement": []map[string]interface{}{
+				{"Effect": "Allow", "Action": "secretsmanager:ListSecrets", "Resource": "*"},
+				{"Effect": "Allow", "Action": "secretsmanager:GetSecretValue", "Resource": secretArn},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		role, err := newIrsaRole(ctx, web3signerName, identity, args.Namespace, web3signerName, pulumi.String(policy))
+		if err != nil {
+			return nil, err
+		}
+		serviceAccount, err := corev1.NewServiceAccount(ctx, web3signerName, &corev1.ServiceAccountArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(web3signerName),
+				Namespace: namespace,
+				Annotations: pulumi.StringMap{
+					"eks.amazonaws.com/role-arn": role.Arn,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		serviceAccountName = serviceAccount.Metadata.Name().Elem()
+		flags = append(flags,
+			pulumi.String("--aws-secrets-enabled=true"),
+			pulumi.String("--aws-secrets-auth-mode=ENVIRONMENT"),
+			pulumi.Sprintf("--aws-secrets-region=%s", identity.Region),
+			pulumi.Sprintf("--aws-secrets-prefixes-filter=%s", signer.SecretsManagerPrefix),
+		)
+	}
+
+	upcheck := &corev1.HTTPGetActionArgs{
+		Path: pulumi.String("/upcheck"),
+		Port: pulumi.Int(web3signerPort),
+	}
+
+	// Create a deployment for web3signer, started once the schema is migrated
+	_, err = appsv1.NewDeployment(ctx, web3signerName, &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(web3signerName),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(signer.Replicas),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccountName,
+					InitContainers:     initContainers,
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String(web3signerName),
+							Image: pulumi.String(signer.Image),
+							Args:  flags,
+							Env:   corev1.EnvVarArray{passwordEnv},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(web3signerPort),
+								},
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("metrics"),
+									ContainerPort: pulumi.Int(web3signerMetricsPort),
+								},
+							},
+							VolumeMounts: volumeMounts,
+							ReadinessProbe: &corev1.ProbeArgs{
+								HttpGet:       upcheck,
+								PeriodSeconds: pulumi.Int(5),
+							},
+							LivenessProbe: &corev1.ProbeArgs{
+								HttpGet:             upcheck,
+								InitialDelaySeconds: pulumi.Int(60),
+								PeriodSeconds:       pulumi.Int(15),
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("100m"),
+									"memory": pulumi.String("512Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("2Gi"),
+								},
+							},
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{migrations}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a service for the validator client to reach web3signer
+	_, err = corev1.NewService(ctx, web3signerName, &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: labels,
+			Type:     pulumi.String("ClusterIP"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(web3signerPort),
+					Name: pulumi.String("http"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(web3signerName),
+			Namespace: namespace,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a headless service so prometheus discovers and scrapes every web3signer replica
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-metrics", web3signerName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:  labels,
+			ClusterIP: pulumi.String("None"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(web3signerMetricsPort),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-metrics", web3signerName),
+			Namespace: namespace,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &web3signerConnection{
+		Host:        host,
+		Port:        db.Port,
+		Name:        db.Name,
+		Username:    db.Username,
+		PasswordEnv: passwordEnv,
+	}, nil
+}
+
+// newWeb3SignerPostgres creates the in-cluster postgres holding the slashing protection of web3signer
+func newWeb3SignerPostgres(ctx *pulumi.Context, args *validatorArgs, passwordEnv corev1.EnvVarArgs) (pulumi.Resource, error) {
+	db := args.Web3Signer.Database
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(web3signerPostgres)}
+
+	// Create a service for the database, also governing its statefulset
+	service, err := corev1.NewService(ctx, web3signerPostgres, &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: labels,
+			Type:     pulumi.String("ClusterIP"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(db.Port),
+					Name: pulumi.String("postgres"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(web3signerPostgres),
+			Namespace: namespace,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ready := &corev1.ExecActionArgs{
+		Command: pulumi.ToStringArray([]string{"sh", "-c", fmt.Sprintf("pg_isready -U %s -d %s", db.Username, db.Name)}),
+	}
+
+	// Create a statefulset running postgres on a volume of its own
+	return appsv1.NewStatefulSet(ctx, web3signerPostgres, &appsv1.StatefulSetArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(web3signerPostgres),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.StatefulSetSpecArgs{
+			Replicas:    pulumi.Int(1),
+			ServiceName: service.Metadata.Name().Elem(),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			VolumeClaimTemplates: corev1.PersistentVolumeClaimTypeArray{
+				corev1.PersistentVolumeClaimTypeArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Name: pulumi.String("postgres-data"),
+					},
+					Spec: &corev1.PersistentVolumeClaimSpecArgs{
+						AccessModes: pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+						Resources: &corev1.VolumeResourceRequirementsArgs{
+							Requests: pulumi.StringMap{
+								"storage": pulumi.String(db.StorageSize),
+							},
+						},
+						StorageClassName: pulumi.String(args.StorageClass),
+					},
+				},
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("postgres"),
+							Image: pulumi.String(db.Image),
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{Name: pulumi.String("POSTGRES_DB"), Value: pulumi.String(db.Name)},
+								corev1.EnvVarArgs{Name: pulumi.String("POSTGRES_USER"), Value: pulumi.String(db.Username)},
+								corev1.EnvVarArgs{
+									Name:      pulumi.String("POSTGRES_PASSWORD"),
+									ValueFrom: passwordEnv.ValueFrom,
+								},
+								// the volume root holds lost+found, postgres wants an empty directory
+								corev1.EnvVarArgs{Name: pulumi.String("PGDATA"), Value: pulumi.String("/var/lib/postgresql/data/pgdata")},
+							},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(db.Port),
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("postgres-data"),
+									MountPath: pulumi.String("/var/lib/postgresql/data"),
+								},
+							},
+							ReadinessProbe: &corev1.ProbeArgs{
+								Exec:          ready,
+								PeriodSeconds: pulumi.Int(5),
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("100m"),
+									"memory": pulumi.String("256Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("1Gi"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,60 @@
+// lock records the registry digest of image manifest tags in images.json.
+// Without arguments it locks the images that have no digest yet, with image
+// names it locks those again, after their tag changed or was re-pushed.
+//
+//	go run ./cmd/lock
+//	go run ./cmd/lock reth lighthouse
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"images/manifest"
+)
+
+func main() {
+	path := flag.String("manifest", "images.json", "path to the image manifest")
+	all := flag.Bool("all", false, "lock every image again")
+	flag.Parse()
+
+	m, err := manifest.Load(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	names := flag.Args()
+	for _, name := range names {
+		if _, ok := m.Images[name]; !ok {
+			log.Fatalf("image %s is not in the image manifest", name)
+		}
+	}
+	if len(names) == 0 {
+		for name, image := range m.Images {
+			if *all || image.Digest == "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	registry := &registryClient{client: &http.Client{Timeout: 30 * time.Second}}
+	ctx := context.Background()
+	for _, name := range names {
+		image := m.Images[name]
+		digest, err := registry.digest(ctx, image.Repository, image.Tag)
+		if err != nil {
+			log.Fatalf("locking %s: %v", name, err)
+		}
+		if digest != image.Digest {
+			fmt.Printf("%s %s:%s %s\n", name, image.Repository, image.Tag, digest)
+		}
+		image.Digest = digest
+		m.Images[name] = image
+	}
+	if err := m.Save(*path); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// manifestTypes are the manifests a tag may point at, a multi-platform index
+// is preferred so the digest is the one docker and pulumi-docker resolve
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// registryClient reads tag digests from docker registry v2 apis, with an
+// anonymous token when the registry asks for one
+type registryClient struct {
+	client *http.Client
+}
+
+// registryPath splits a repository into its registry host and path, docker
+// hub repositories have neither a host nor, for official images, an owner
+func registryPath(repository string) (string, string) {
+	first, rest, found := strings.Cut(repository, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		if first == "docker.io" {
+			first = "registry-1.docker.io"
+		}
+		repository = rest
+	} else {
+		first = "registry-1.docker.io"
+	}
+	if first == "registry-1.docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return first, repository
+}
+
+// digest returns the digest repository:tag points at
+func (r *registryClient) digest(ctx context.Context, repository, tag string) (string, error) {
+	host, path := registryPath(repository)
+	manifest := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path, tag)
+
+	response, err := r.head(ctx, manifest, "")
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		token, err := r.token(ctx, response.Header.Get("Www-Authenticate"))
+		if err != nil {
+			return "", err
+		}
+		if response, err = r.head(ctx, manifest, token); err != nil {
+			return "", err
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s:%s: %s", repository, tag, response.Status)
+	}
+	digest := response.Header.Get("Docker-Content-Digest")
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("%s:%s: registry returned digest %q", repository, tag, digest)
+	}
+	return digest, nil
+}
+
+func (r *registryClient) head(ctx context.Context, manifest, token string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, manifest, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	return response, nil
+}
+
+// token fetches an anonymous pull token for a bearer challenge like
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/postgres:pull"
+func (r *registryClient) token(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("unsupported registry challenge %q", challenge)
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token: %s", response.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("parsing registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge parses the quoted parameters of a Bearer challenge
+func parseChallenge(challenge string) (map[string]string, bool) {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	params := map[string]string{}
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=\"")
+		if !found {
+			return nil, false
+		}
+		value, rest, found = strings.Cut(value, "\"")
+		if !found {
+			return nil, false
+		}
+		params[strings.TrimSpace(strings.TrimPrefix(key, ","))] = value
+		rest = strings.TrimPrefix(rest, ",")
+	}
+	return params, true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryPath(t *testing.T) {
+	tests := []struct {
+		repository string
+		host       string
+		path       string
+	}{
+		{"postgres", "registry-1.docker.io", "library/postgres"},
+		{"sigp/lighthouse", "registry-1.docker.io", "sigp/lighthouse"},
+		{"docker.io/sigp/lighthouse", "registry-1.docker.io", "sigp/lighthouse"},
+		{"ghcr.io/paradigmxyz/reth", "ghcr.io", "paradigmxyz/reth"},
+		{"gcr.io/prysmaticlabs/prysm/beacon-chain", "gcr.io", "prysmaticlabs/prysm/beacon-chain"},
+		{"localhost:5000/reth", "localhost:5000", "reth"},
+	}
+	for _, tt := range tests {
+		host, path := registryPath(tt.repository)
+		if host != tt.host || path != tt.path {
+			t.Errorf("registryPath(%q) = %q, %q, want %q, %q", tt.repository, host, path, tt.host, tt.path)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	params, ok := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/postgres:pull"`)
+	if !ok {
+		t.Fatal("challenge not parsed")
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/postgres:pull",
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("%s = %q, want %q", key, params[key], value)
+		}
+	}
+	for _, challenge := range []string{`Basic realm="registry"`, `Bearer realm="unterminated`, ""} {
+		if _, ok := parseChallenge(challenge); ok {
+			t.Errorf("parsed unsupported challenge %q", challenge)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:org/client:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token":"anonymous"}`))
+		case r.URL.Path != "/v2/org/client/manifests/v1.0.0":
+			http.NotFound(w, r)
+		case r.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+server.URL+`/token",service="test",scope="repository:org/client:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case !strings.Contains(r.Header.Get("Accept"), "manifest.list.v2+json"):
+			http.Error(w, "index not accepted", http.StatusNotAcceptable)
+		default:
+			w.Header().Set("Docker-Content-Digest", digest)
+		}
+	}))
+	defer server.Close()
+
+	registry := &registryClient{client: server.Client()}
+	repository := strings.TrimPrefix(server.URL, "https://") + "/org/client"
+	got, err := registry.digest(context.Background(), repository, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != digest {
+		t.Errorf("digest = %s, want %s", got, digest)
+	}
+	if _, err := registry.digest(context.Background(), repository, "v2.0.0"); err == nil {
+		t.Error("missing tag resolved")
+	}
+}
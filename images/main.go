@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"images/manifest"
+)
+
+// The images program publishes the resolved image manifest as stack outputs
+// for programs that cannot read images.json themselves, e.g. the yaml programs
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		m, err := manifest.Load("images.json")
+		if err != nil {
+			return err
+		}
+		resolved, err := m.ResolveAll(ctx)
+		if err != nil {
+			return err
+		}
+		for name, image := range resolved {
+			ctx.Export(name, pulumi.String(image))
+		}
+		return nil
+	})
+}
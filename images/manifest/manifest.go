@@ -0,0 +1,192 @@
+// Package manifest reads the versioned image manifest in images/images.json,
+// which locks every tag to the digest it had when it was pinned, so every
+// program deploys the same images and an upgrade is an explicit change to the
+// manifest. The lock command in images/cmd/lock records the digests.
+//
+// Clients only move forward: a pin older than the version a stack already
+// runs downgrades its database, and one from before a network's latest hard
+// fork leaves the node on the wrong fork.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-docker/sdk/v4/go/docker"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Version is the manifest format this package reads
+const Version = 1
+
+// DefaultPath is where programs in this repo find the manifest, relative to their project directory
+const DefaultPath = "../images/images.json"
+
+// Image is a pinned container image. Digest is the digest the tag was locked
+// to, deploys fail until it is recorded.
+type Image struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+type Manifest struct {
+	Version int              `json:"version"`
+	Images  map[string]Image `json:"images"`
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Load reads and validates the manifest at path
+func Load(path string) (*Manifest, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("parsing image manifest %s: %w", path, err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("image manifest %s has version %d, expected %d", path, m.Version, Version)
+	}
+	for name, image := range m.Images {
+		if image.Repository == "" || image.Tag == "" {
+			return nil, fmt.Errorf("image %s needs a repository and a tag", name)
+		}
+		if image.Tag == "latest" {
+			return nil, fmt.Errorf("image %s must be pinned to a version, not latest", name)
+		}
+		if image.Digest != "" && !digestPattern.MatchString(image.Digest) {
+			return nil, fmt.Errorf("image %s digest %q is not a sha256 digest", name, image.Digest)
+		}
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to path, one image per line in name order
+func (m *Manifest) Save(path string) error {
+	names := make([]string, 0, len(m.Images))
+	for name := range m.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "{\n  \"version\": %d,\n  \"images\": {\n", m.Version)
+	for i, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		image, err := json.Marshal(m.Images[name])
+		if err != nil {
+			return err
+		}
+		separator := ","
+		if i == len(names)-1 {
+			separator = ""
+		}
+		fmt.Fprintf(&out, "    %s: %s%s\n", key, image, separator)
+	}
+	out.WriteString("  }\n}\n")
+	return os.WriteFile(filepath.Clean(path), []byte(out.String()), 0o644)
+}
+
+// Tagged returns the repository:tag reference of the named image
+func (m *Manifest) Tagged(name string) (string, error) {
+	image, ok := m.Images[name]
+	if !ok {
+		return "", fmt.Errorf("image %s is not in the image manifest", name)
+	}
+	return fmt.Sprintf("%s:%s", image.Repository, image.Tag), nil
+}
+
+// Resolve returns the repository:tag@digest reference of the named image
+// with its locked digest. The tag is looked up in its registry, and a tag
+// that no longer points at the locked digest fails the deploy: it was either
+// re-pushed upstream or changed in the manifest without locking it again.
+// A registry that cannot be reached only warns, the locked digest still deploys.
+func (m *Manifest) Resolve(ctx *pulumi.Context, name string) (string, error) {
+	tagged, err := m.Tagged(name)
+	if err != nil {
+		return "", err
+	}
+	digest := m.Images[name].Digest
+	if digest == "" {
+		return "", fmt.Errorf("image %s has no locked digest, run go run ./cmd/lock %s in images", name, name)
+	}
+	image, err := docker.LookupRegistryImage(ctx, &docker.LookupRegistryImageArgs{
+		Name: tagged,
+	})
+	if err != nil {
+		ctx.Log.Warn(fmt.Sprintf("cannot check image %s against its registry, deploying the locked %s: %v", tagged, digest, err), nil)
+	} else if image.Sha256Digest != digest {
+		return "", fmt.Errorf("image %s now resolves to %s but is locked to %s, "+
+			"run go run ./cmd/lock %s in images to take the new image", tagged, image.Sha256Digest, digest, name)
+	}
+	return fmt.Sprintf("%s@%s", tagged, digest), nil
+}
+
+// ResolveAll resolves every image in the manifest
+func (m *Manifest) ResolveAll(ctx *pulumi.Context) (map[string]string, error) {
+	names := make([]string, 0, len(m.Images))
+	for name := range m.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := map[string]string{}
+	for _, name := range names {
+		image, err := m.Resolve(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = image
+	}
+	return resolved, nil
+}
+
+// Resolver resolves images once per program run and keeps what it resolved
+// so programs can record the images they deployed in their stack outputs
+type Resolver struct {
+	ctx      *pulumi.Context
+	manifest *Manifest
+	resolved map[string]string
+}
+
+// NewResolver loads the manifest at path for ctx
+func NewResolver(ctx *pulumi.Context, path string) (*Resolver, error) {
+	m, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{ctx: ctx, manifest: m, resolved: map[string]string{}}, nil
+}
+
+// Image returns the resolved reference of the named image
+func (r *Resolver) Image(name string) (string, error) {
+	if image, ok := r.resolved[name]; ok {
+		return image, nil
+	}
+	image, err := r.manifest.Resolve(r.ctx, name)
+	if err != nil {
+		return "", err
+	}
+	r.resolved[name] = image
+	return image, nil
+}
+
+// Resolved returns every image resolved so far, for a stack output
+func (r *Resolver) Resolved() pulumi.StringMap {
+	images := pulumi.StringMap{}
+	for name, image := range r.resolved {
+		images[name] = pulumi.String(image)
+	}
+	return images
+}
@@ -12,6 +12,8 @@ import (
 	storagev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/storage/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	"images/manifest"
 )
 
 func main() {
@@ -23,6 +25,49 @@ func main() {
 		consensusTargetIp := cfg.Require("consensusTargetIp")
 		tempNodeTargetIp := cfg.Require("tempNodeTargetIp")
 		recordName := cfg.Require("recordName")
+		// headless service of the holesky rpc gateway, resolving to every replica
+		rpcGatewayMetricsHost := cfg.Get("rpcGatewayMetricsHost")
+		if rpcGatewayMetricsHost == "" {
+			rpcGatewayMetricsHost = "reth-gateway-metrics.default.svc.cluster.local"
+		}
+		// service of the holesky pvc autoscaler
+		pvcAutoscalerMetricsHost := cfg.Get("pvcAutoscalerMetricsHost")
+		if pvcAutoscalerMetricsHost == "" {
+			pvcAutoscalerMetricsHost = "reth-lighthouse-pvc-autoscaler-metrics.default.svc.cluster.local"
+		}
+		// headless service of the holesky mev-boost, resolving to every replica
+		mevBoostMetricsHost := cfg.Get("mevBoostMetricsHost")
+		if mevBoostMetricsHost == "" {
+			mevBoostMetricsHost = "mev-boost-metrics.default.svc.cluster.local"
+		}
+		// headless service of the holesky-validator web3signer, resolving to every replica
+		web3signerMetricsHost := cfg.Get("web3signerMetricsHost")
+		if web3signerMetricsHost == "" {
+			web3signerMetricsHost = "web3signer-metrics.default.svc.cluster.local"
+		}
+		// headless service of the holesky-validator validator client, the stack's metricsHost output
+		validatorMetricsHost := cfg.Get("validatorMetricsHost")
+		if validatorMetricsHost == "" {
+			validatorMetricsHost = "lighthouse-validator-metrics.default.svc.cluster.local"
+		}
+
+		// resolve the pinned monitoring images from the repo's image manifest
+		images, err := manifest.NewResolver(ctx, manifest.DefaultPath)
+		if err != nil {
+			return err
+		}
+		prometheusImage, err := images.Image("prometheus")
+		if err != nil {
+			return err
+		}
+		grafanaImage, err := images.Image("grafana-oss")
+		if err != nil {
+			return err
+		}
+		nodeExporterImage, err := images.Image("node-exporter")
+		if err != nil {
+			return err
+		}
 
 		// dashboard vars
 		rethDashboard, err := os.ReadFile("config/grafana/dashboards/reth-overview.json")
@@ -77,6 +122,29 @@ scrape_configs:
   - job_name: temp_node
     static_configs:
       - targets: ['` + tempNodeTargetIp + `']
+  - job_name: rpc_gateway
+    dns_sd_configs:
+      - names: ['` + rpcGatewayMetricsHost + `']
+        type: A
+        port: 9102
+  - job_name: pvc_autoscaler
+    static_configs:
+      - targets: ['` + pvcAutoscalerMetricsHost + `:9103']
+  - job_name: mev_boost
+    dns_sd_configs:
+      - names: ['` + mevBoostMetricsHost + `']
+        type: A
+        port: 18551
+  - job_name: web3signer
+    dns_sd_configs:
+      - names: ['` + web3signerMetricsHost + `']
+        type: A
+        port: 9001
+  - job_name: holesky_validator
+    dns_sd_configs:
+      - names: ['` + validatorMetricsHost + `']
+        type: A
+        port: 5064
 `),
 			},
 		}, pulumi.DependsOn([]pulumi.Resource{ns}))
@@ -102,7 +170,7 @@ scrape_configs:
 						Containers: corev1.ContainerArray{
 							&corev1.ContainerArgs{
 								Name:  pulumi.String("prometheus"),
-								Image: pulumi.String("prom/prometheus"),
+								Image: pulumi.String(prometheusImage),
 								Ports: corev1.ContainerPortArray{
 									&corev1.ContainerPortArgs{
 										ContainerPort: pulumi.Int(9090),
@@ -272,9 +340,8 @@ scrape_configs:
 					Spec: &corev1.PodSpecArgs{
 						Containers: corev1.ContainerArray{
 							&corev1.ContainerArgs{
-								Name:            pulumi.String("grafana"),
-								Image:           pulumi.String("grafana/grafana-oss:latest"),
-								ImagePullPolicy: pulumi.String("Always"),
+								Name:  pulumi.String("grafana"),
+								Image: pulumi.String(grafanaImage),
 								Ports: corev1.ContainerPortArray{
 									&corev1.ContainerPortArgs{
 										ContainerPort: pulumi.Int(3000),
@@ -504,7 +571,7 @@ scrape_configs:
 						Containers: corev1.ContainerArray{
 							&corev1.ContainerArgs{
 								Name:  pulumi.String("prometheus-node-exporter"),
-								Image: pulumi.String("prom/node-exporter"),
+								Image: pulumi.String(nodeExporterImage),
 								Ports: corev1.ContainerPortArray{
 									&corev1.ContainerPortArgs{
 										ContainerPort: pulumi.Int(9100),
@@ -537,6 +604,7 @@ scrape_configs:
 			return err
 		}
 
+		ctx.Export("images", images.Resolved())
 		return nil
 	})
 }
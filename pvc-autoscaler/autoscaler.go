@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Reasons of the events the autoscaler records on a claim
+const (
+	reasonExpanding       = "Expanding"
+	reasonExpansionFailed = "ExpansionFailed"
+	reasonAtCeiling       = "AtCeiling"
+)
+
+// autoscaler grows the configured claims as their filesystems fill up
+type autoscaler struct {
+	cfg     *Config
+	kube    *kubeClient
+	metrics *metrics
+	// atCeiling remembers the claims already reported at their ceiling, so
+	// the warning is recorded once rather than every interval
+	atCeiling map[string]bool
+}
+
+func newAutoscaler(cfg *Config, kube *kubeClient, metrics *metrics) *autoscaler {
+	return &autoscaler{cfg: cfg, kube: kube, metrics: metrics, atCeiling: map[string]bool{}}
+}
+
+// run checks the volumes every interval until ctx is done
+func (a *autoscaler) run(ctx context.Context) {
+	interval := time.Duration(a.cfg.IntervalSeconds) * time.Second
+	for {
+		a.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check reads the usage of every volume and expands the ones past their threshold
+func (a *autoscaler) check(ctx context.Context) {
+	stats, err := a.volumeStats(ctx)
+	if err != nil {
+		log.Printf("reading volume stats: %v", err)
+		return
+	}
+	for _, volume := range a.cfg.Volumes {
+		usage, ok := stats[volume.Claim]
+		if !ok {
+			// the claim is not mounted by a running pod, there is nothing to measure
+			continue
+		}
+		a.metrics.setUsage(volume.Claim, usage.UsedBytes, usage.CapacityBytes, volume.ceiling)
+		if usage.CapacityBytes == 0 || usage.UsedBytes*100 < usage.CapacityBytes*int64(volume.ThresholdPercent) {
+			delete(a.atCeiling, volume.Claim)
+			continue
+		}
+		if err := a.expand(ctx, volume, usage); err != nil {
+			log.Printf("expanding %s: %v", volume.Claim, err)
+			a.metrics.countError(volume.Claim)
+		}
+	}
+}
+
+// volumeStats returns the usage of the mounted claims of the namespace, read
+// from the stats summary of every kubelet
+func (a *autoscaler) volumeStats(ctx context.Context) (map[string]volumeStats, error) {
+	var nodes nodeList
+	if err := a.kube.do(ctx, http.MethodGet, "/api/v1/nodes", "", nil, &nodes); err != nil {
+		return nil, err
+	}
+	stats := map[string]volumeStats{}
+	for _, node := range nodes.Items {
+		var summary statsSummary
+		if err := a.kube.kubeletStats(ctx, &node, &summary); err != nil {
+			// a node that is going away should not stop the others from being checked
+			log.Printf("reading stats of node %s: %v", node.Metadata.Name, err)
+			continue
+		}
+		for _, pod := range summary.Pods {
+			for _, volume := range pod.Volume {
+				if volume.PvcRef != nil && volume.PvcRef.Namespace == a.cfg.Namespace {
+					stats[volume.PvcRef.Name] = volume
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// expand grows the claim by a step, up to its ceiling
+func (a *autoscaler) expand(ctx context.Context, volume *Volume, usage volumeStats) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/persistentvolumeclaims/%s", url.PathEscape(a.cfg.Namespace), url.PathEscape(volume.Claim))
+	var claim persistentVolumeClaim
+	if err := a.kube.do(ctx, http.MethodGet, path, "", nil, &claim); err != nil {
+		return err
+	}
+	if claim.resizing() {
+		log.Printf("%s is still being resized, waiting", volume.Claim)
+		return nil
+	}
+	requested, err := parseQuantity(claim.Spec.Resources.Requests["storage"])
+	if err != nil {
+		return err
+	}
+	used := fmt.Sprintf("%d%% of %s used", usage.UsedBytes*100/usage.CapacityBytes, formatQuantity(roundUpGi(usage.CapacityBytes)))
+
+	if requested >= volume.ceiling {
+		if !a.atCeiling[volume.Claim] {
+			a.atCeiling[volume.Claim] = true
+			a.record(ctx, &claim, "Warning", reasonAtCeiling,
+				fmt.Sprintf("%s and the claim is at its %s ceiling, raise it or prune the database", used, volume.Ceiling))
+		}
+		return nil
+	}
+
+	size := min(roundUpGi(requested+volume.step), volume.ceiling)
+	patch := map[string]any{
+		"spec": map[string]any{
+			"resources": map[string]any{
+				"requests": map[string]string{"storage": formatQuantity(size)},
+			},
+		},
+	}
+	if err := a.kube.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil); err != nil {
+		a.record(ctx, &claim, "Warning", reasonExpansionFailed, fmt.Sprintf("%s, expanding to %s failed: %v", used, formatQuantity(size), err))
+		return err
+	}
+	log.Printf("%s: %s, expanding from %s to %s", volume.Claim, used, formatQuantity(requested), formatQuantity(size))
+	a.record(ctx, &claim, "Normal", reasonExpanding, fmt.Sprintf("%s, expanding from %s to %s", used, formatQuantity(requested), formatQuantity(size)))
+	a.metrics.countExpansion(volume.Claim)
+	return nil
+}
+
+// record creates an event on the claim, failures are only logged
+func (a *autoscaler) record(ctx context.Context, claim *persistentVolumeClaim, eventType, reason, message string) {
+	now := time.Now().UTC()
+	path := fmt.Sprintf("/api/v1/namespaces/%s/events", url.PathEscape(a.cfg.Namespace))
+	err := a.kube.do(ctx, http.MethodPost, path, "", event{
+		Metadata: eventMeta{GenerateName: claim.Metadata.Name + ".", Namespace: a.cfg.Namespace},
+		InvolvedObject: objectRef{
+			ApiVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+			Name:       claim.Metadata.Name,
+			Namespace:  a.cfg.Namespace,
+			Uid:        claim.Metadata.Uid,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         map[string]any{"component": "pvc-autoscaler"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}, nil)
+	if err != nil {
+		log.Printf("recording %s event on %s: %v", reason, claim.Metadata.Name, err)
+	}
+}
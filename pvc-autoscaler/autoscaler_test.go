@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeCluster serves the api server and kubelet endpoints the autoscaler
+// uses, for one claim in the default namespace on one node
+type fakeCluster struct {
+	mu         sync.Mutex
+	server     *httptest.Server
+	used       int64
+	capacity   int64
+	claim      persistentVolumeClaim
+	patchFails bool
+	patches    []string
+	events     []event
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *kubeClient) {
+	f := &fakeCluster{}
+	f.claim.Metadata = objectMeta{Name: "reth-data", Namespace: "default", Uid: "uid-1"}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f, &kubeClient{host: f.server.URL, client: f.server.Client(), tokenPath: token}
+}
+
+// setClaim sets the storage the claim requests and has
+func (f *fakeCluster) setClaim(requested, capacity string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claim.Spec.Resources.Requests = map[string]string{"storage": requested}
+	f.claim.Status.Capacity = map[string]string{"storage": capacity}
+}
+
+func (f *fakeCluster) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	claimPath := "/api/v1/namespaces/default/persistentvolumeclaims/reth-data"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/nodes":
+		host, port, _ := net.SplitHostPort(f.server.Listener.Addr().String())
+		portNumber, _ := strconv.Atoi(port)
+		fmt.Fprintf(w, `{"items":[{"metadata":{"name":"node-1"},"status":{
+			"addresses":[{"type":"Hostname","address":"node-1"},{"type":"InternalIP","address":%q}],
+			"daemonEndpoints":{"kubeletEndpoint":{"Port":%d}}}}]}`, host, portNumber)
+	case r.Method == http.MethodGet && r.URL.Path == "/stats/summary":
+		fmt.Fprintf(w, `{"pods":[{"volume":[
+			{"name":"data","capacityBytes":%d,"usedBytes":%d,"pvcRef":{"name":"reth-data","namespace":"default"}},
+			{"name":"other","capacityBytes":100,"usedBytes":100,"pvcRef":{"name":"reth-data","namespace":"elsewhere"}},
+			{"name":"tmp","capacityBytes":100,"usedBytes":100}]}]}`, f.capacity, f.used)
+	case r.Method == http.MethodGet && r.URL.Path == claimPath:
+		json.NewEncoder(w).Encode(f.claim)
+	case r.Method == http.MethodPatch && r.URL.Path == claimPath:
+		if f.patchFails {
+			http.Error(w, "quota exceeded", http.StatusForbidden)
+			return
+		}
+		var patch struct {
+			Spec struct {
+				Resources struct {
+					Requests map[string]string `json:"requests"`
+				} `json:"resources"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&patch)
+		f.patches = append(f.patches, patch.Spec.Resources.Requests["storage"])
+		f.claim.Spec.Resources.Requests = patch.Spec.Resources.Requests
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/default/events":
+		var e event
+		json.NewDecoder(r.Body).Decode(&e)
+		f.events = append(f.events, e)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// reasons returns the reasons of the recorded events
+func (f *fakeCluster) reasons() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var reasons []string
+	for _, e := range f.events {
+		reasons = append(reasons, e.Reason)
+	}
+	return reasons
+}
+
+func testAutoscaler(t *testing.T, kube *kubeClient) *autoscaler {
+	cfg := &Config{Namespace: "default", Volumes: []*Volume{
+		{Claim: "reth-data", ThresholdPercent: 80, Step: "50Gi", Ceiling: "200Gi"},
+	}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newAutoscaler(cfg, kube, newMetrics())
+}
+
+func TestAutoscalerCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		requested  string
+		capacity   string
+		usedGi     int64
+		resizing   bool
+		patchFails bool
+		wantPatch  string
+		wantEvents []string
+	}{
+		{name: "below threshold", requested: "100Gi", capacity: "100Gi", usedGi: 79},
+		{name: "threshold reached", requested: "100Gi", capacity: "100Gi", usedGi: 80,
+			wantPatch: "150Gi", wantEvents: []string{reasonExpanding}},
+		{name: "step clamped to the ceiling", requested: "180Gi", capacity: "180Gi", usedGi: 170,
+			wantPatch: "200Gi", wantEvents: []string{reasonExpanding}},
+		{name: "at the ceiling", requested: "200Gi", capacity: "200Gi", usedGi: 190,
+			wantEvents: []string{reasonAtCeiling}},
+		{name: "resize in progress", requested: "150Gi", capacity: "100Gi", usedGi: 95},
+		{name: "resizing condition", requested: "100Gi", capacity: "100Gi", usedGi: 95, resizing: true},
+		{name: "patch rejected", requested: "100Gi", capacity: "100Gi", usedGi: 95, patchFails: true,
+			wantEvents: []string{reasonExpansionFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, kube := newFakeCluster(t)
+			cluster.setClaim(tt.requested, tt.capacity)
+			cluster.used, cluster.capacity = tt.usedGi<<30, 100<<30
+			cluster.patchFails = tt.patchFails
+			if tt.resizing {
+				cluster.claim.Status.Conditions = append(cluster.claim.Status.Conditions, struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				}{"Resizing", "True"})
+			}
+
+			a := testAutoscaler(t, kube)
+			a.check(context.Background())
+
+			var wantPatches []string
+			if tt.wantPatch != "" {
+				wantPatches = []string{tt.wantPatch}
+			}
+			if fmt.Sprint(cluster.patches) != fmt.Sprint(wantPatches) {
+				t.Errorf("patches = %v, want %v", cluster.patches, wantPatches)
+			}
+			if got := cluster.reasons(); fmt.Sprint(got) != fmt.Sprint(tt.wantEvents) {
+				t.Errorf("events = %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestAutoscalerCeilingEventOnce(t *testing.T) {
+	cluster, kube := newFakeCluster(t)
+	cluster.setClaim("200Gi", "200Gi")
+	cluster.used, cluster.capacity = 190<<30, 200<<30
+	a := testAutoscaler(t, kube)
+
+	a.check(context.Background())
+	a.check(context.Background())
+	if got := cluster.reasons(); fmt.Sprint(got) != fmt.Sprint([]string{reasonAtCeiling}) {
+		t.Fatalf("events after two checks at the ceiling = %v, want one %s", got, reasonAtCeiling)
+	}
+
+	// dropping below the threshold, e.g. after a prune, rearms the warning
+	cluster.mu.Lock()
+	cluster.used = 100 << 30
+	cluster.mu.Unlock()
+	a.check(context.Background())
+	cluster.mu.Lock()
+	cluster.used = 190 << 30
+	cluster.mu.Unlock()
+	a.check(context.Background())
+	if got := cluster.reasons(); len(got) != 2 {
+		t.Fatalf("events after filling up again = %v, want a second %s", got, reasonAtCeiling)
+	}
+}
+
+func TestAutoscalerExpandsStepByStep(t *testing.T) {
+	cluster, kube := newFakeCluster(t)
+	cluster.setClaim("100Gi", "100Gi")
+	cluster.used, cluster.capacity = 90<<30, 100<<30
+	a := testAutoscaler(t, kube)
+
+	a.check(context.Background())
+	// the claim has not grown yet, so the next check waits for the resize
+	a.check(context.Background())
+	if fmt.Sprint(cluster.patches) != "[150Gi]" {
+		t.Fatalf("patches while resizing = %v, want [150Gi]", cluster.patches)
+	}
+
+	cluster.setClaim("150Gi", "150Gi")
+	cluster.mu.Lock()
+	cluster.used, cluster.capacity = 140<<30, 150<<30
+	cluster.mu.Unlock()
+	a.check(context.Background())
+	if fmt.Sprint(cluster.patches) != "[150Gi 200Gi]" {
+		t.Fatalf("patches = %v, want [150Gi 200Gi]", cluster.patches)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the autoscaler config file rendered by the node program
+type Config struct {
+	// Namespace holds the claims
+	Namespace string `json:"namespace"`
+	// IntervalSeconds is how often volume usage is checked
+	IntervalSeconds int `json:"intervalSeconds"`
+	// MetricsListen is the address prometheus metrics are served on
+	MetricsListen string `json:"metricsListen"`
+	// Volumes are the claims the autoscaler grows
+	Volumes []*Volume `json:"volumes"`
+}
+
+// Volume grows a claim by Step when its filesystem is more than
+// ThresholdPercent full, until the claim requests Ceiling. Step and Ceiling
+// are kubernetes quantities, e.g. 50Gi.
+type Volume struct {
+	Claim            string `json:"claim"`
+	ThresholdPercent int    `json:"thresholdPercent"`
+	Step             string `json:"step"`
+	Ceiling          string `json:"ceiling"`
+
+	step    int64
+	ceiling int64
+}
+
+const (
+	defaultIntervalSeconds  = 60
+	defaultMetricsListen    = ":9103"
+	defaultThresholdPercent = 80
+)
+
+// LoadConfig reads and validates the config file at path
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = defaultIntervalSeconds
+	}
+	if c.IntervalSeconds < 0 {
+		return fmt.Errorf("intervalSeconds cannot be negative")
+	}
+	if c.MetricsListen == "" {
+		c.MetricsListen = defaultMetricsListen
+	}
+	if len(c.Volumes) == 0 {
+		return fmt.Errorf("at least one volume is required")
+	}
+	claims := map[string]bool{}
+	for _, volume := range c.Volumes {
+		if volume.Claim == "" || claims[volume.Claim] {
+			return fmt.Errorf("volume claim %q must be named and listed once", volume.Claim)
+		}
+		claims[volume.Claim] = true
+		if err := volume.validate(); err != nil {
+			return fmt.Errorf("volume %s: %w", volume.Claim, err)
+		}
+	}
+	return nil
+}
+
+func (v *Volume) validate() error {
+	if v.ThresholdPercent == 0 {
+		v.ThresholdPercent = defaultThresholdPercent
+	}
+	if v.ThresholdPercent < 1 || v.ThresholdPercent > 99 {
+		return fmt.Errorf("thresholdPercent must be between 1 and 99")
+	}
+	var err error
+	if v.step, err = parseQuantity(v.Step); err != nil {
+		return fmt.Errorf("step: %w", err)
+	}
+	if v.ceiling, err = parseQuantity(v.Ceiling); err != nil {
+		return fmt.Errorf("ceiling: %w", err)
+	}
+	if v.step <= 0 || v.ceiling <= 0 {
+		return fmt.Errorf("step and ceiling must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	volume := func() *Volume {
+		return &Volume{Claim: "reth-data", Step: "50Gi", Ceiling: "2Ti"}
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"valid", Config{Namespace: "default", Volumes: []*Volume{volume()}}, ""},
+		{"no namespace", Config{Volumes: []*Volume{volume()}}, "namespace is required"},
+		{"negative interval", Config{Namespace: "default", IntervalSeconds: -1, Volumes: []*Volume{volume()}}, "intervalSeconds"},
+		{"no volumes", Config{Namespace: "default"}, "at least one volume"},
+		{"unnamed claim", Config{Namespace: "default", Volumes: []*Volume{{Step: "1Gi", Ceiling: "2Gi"}}}, "must be named"},
+		{"claim listed twice", Config{Namespace: "default", Volumes: []*Volume{volume(), volume()}}, "listed once"},
+		{"threshold too high", Config{Namespace: "default", Volumes: []*Volume{{Claim: "a", ThresholdPercent: 100, Step: "1Gi", Ceiling: "2Gi"}}}, "thresholdPercent"},
+		{"invalid step", Config{Namespace: "default", Volumes: []*Volume{{Claim: "a", Step: "lots", Ceiling: "2Gi"}}}, "step"},
+		{"invalid ceiling", Config{Namespace: "default", Volumes: []*Volume{{Claim: "a", Step: "1Gi"}}}, "ceiling"},
+		{"zero step", Config{Namespace: "default", Volumes: []*Volume{{Claim: "a", Step: "0", Ceiling: "2Gi"}}}, "must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := Config{Namespace: "default", Volumes: []*Volume{{Claim: "reth-data", Step: "50Gi", Ceiling: "2Ti"}}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.IntervalSeconds != defaultIntervalSeconds || cfg.MetricsListen != defaultMetricsListen {
+		t.Errorf("interval %d and metrics listen %q are not the defaults", cfg.IntervalSeconds, cfg.MetricsListen)
+	}
+	volume := cfg.Volumes[0]
+	if volume.ThresholdPercent != defaultThresholdPercent || volume.step != 50<<30 || volume.ceiling != 2<<40 {
+		t.Errorf("volume %+v not defaulted and parsed", volume)
+	}
+}
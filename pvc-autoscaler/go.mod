@@ -0,0 +1,3 @@
+module pvc-autoscaler
+
+go 1.22.0
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeletPort is where kubelets serve their api when a node does not report it
+const kubeletPort = 10250
+
+// kubeClient talks to the api server, and to the kubelets for their stats,
+// with the pod's service account
+type kubeClient struct {
+	host      string
+	client    *http.Client
+	tokenPath string
+}
+
+func newInClusterClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are unset")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+	}
+	return &kubeClient{
+		host:      "https://" + net.JoinHostPort(host, port),
+		tokenPath: serviceAccountDir + "/token",
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// do sends body as json, or as contentType when it is set, and decodes the response into out
+func (k *kubeClient) do(ctx context.Context, method, path, contentType string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, k.host+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		request.Header.Set("Content-Type", contentType)
+	}
+	return k.send(request, out)
+}
+
+// kubeletStats reads the stats summary straight from the kubelet of node.
+// Kubelets authorize the service account token for the nodes/stats
+// subresource, so unlike the api server's node proxy this does not give
+// access to the rest of the kubelet api. Their serving certificates are
+// signed by the cluster CA.
+func (k *kubeClient) kubeletStats(ctx context.Context, node *node, out *statsSummary) error {
+	address := node.internalIp()
+	if address == "" {
+		return fmt.Errorf("node %s has no internal ip", node.Metadata.Name)
+	}
+	port := node.Status.DaemonEndpoints.KubeletEndpoint.Port
+	if port == 0 {
+		port = kubeletPort
+	}
+	url := fmt.Sprintf("https://%s/stats/summary", net.JoinHostPort(address, strconv.Itoa(port)))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return k.send(request, out)
+}
+
+// send authenticates request with the service account token and decodes the response into out
+func (k *kubeClient) send(request *http.Request, out any) error {
+	// the token is read on every request, projected tokens are rotated on disk
+	token, err := os.ReadFile(k.tokenPath)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	request.Header.Set("Accept", "application/json")
+
+	response, err := k.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", request.Method, request.URL.Path, response.Status, strings.TrimSpace(string(detail)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+type objectMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Uid       string `json:"uid"`
+}
+
+type persistentVolumeClaim struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Resources struct {
+			Requests map[string]string `json:"requests"`
+		} `json:"resources"`
+	} `json:"spec"`
+	Status struct {
+		Capacity   map[string]string `json:"capacity"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// resizing is true while an earlier expansion of the claim is still being applied
+func (c *persistentVolumeClaim) resizing() bool {
+	for _, condition := range c.Status.Conditions {
+		if (condition.Type == "Resizing" || condition.Type == "FileSystemResizePending") && condition.Status == "True" {
+			return true
+		}
+	}
+	capacity, err := parseQuantity(c.Status.Capacity["storage"])
+	if err != nil {
+		return true
+	}
+	requested, err := parseQuantity(c.Spec.Resources.Requests["storage"])
+	return err == nil && capacity < requested
+}
+
+type nodeList struct {
+	Items []node `json:"items"`
+}
+
+type node struct {
+	Metadata objectMeta `json:"metadata"`
+	Status   struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		DaemonEndpoints struct {
+			KubeletEndpoint struct {
+				Port int `json:"Port"`
+			} `json:"kubeletEndpoint"`
+		} `json:"daemonEndpoints"`
+	} `json:"status"`
+}
+
+// internalIp is the address the kubelet of the node is reached on inside the vpc
+func (n *node) internalIp() string {
+	for _, address := range n.Status.Addresses {
+		if address.Type == "InternalIP" {
+			return address.Address
+		}
+	}
+	return ""
+}
+
+// statsSummary is the part of the kubelet stats summary with the pod volumes
+type statsSummary struct {
+	Pods []struct {
+		Volume []volumeStats `json:"volume"`
+	} `json:"pods"`
+}
+
+type volumeStats struct {
+	CapacityBytes int64 `json:"capacityBytes"`
+	UsedBytes     int64 `json:"usedBytes"`
+	PvcRef        *struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"pvcRef"`
+}
+
+type event struct {
+	Metadata       eventMeta      `json:"metadata"`
+	InvolvedObject objectRef      `json:"involvedObject"`
+	Reason         string         `json:"reason"`
+	Message        string         `json:"message"`
+	Type           string         `json:"type"`
+	Source         map[string]any `json:"source"`
+	FirstTimestamp time.Time      `json:"firstTimestamp"`
+	LastTimestamp  time.Time      `json:"lastTimestamp"`
+	Count          int            `json:"count"`
+}
+
+type eventMeta struct {
+	GenerateName string `json:"generateName"`
+	Namespace    string `json:"namespace"`
+}
+
+type objectRef struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Uid        string `json:"uid"`
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestClaimResizing(t *testing.T) {
+	type condition struct{ Type, Status string }
+	tests := []struct {
+		name       string
+		requested  string
+		capacity   string
+		conditions []condition
+		want       bool
+	}{
+		{"settled", "100Gi", "100Gi", nil, false},
+		{"capacity above request", "100Gi", "110Gi", nil, false},
+		{"capacity behind request", "150Gi", "100Gi", nil, true},
+		{"resizing", "100Gi", "100Gi", []condition{{"Resizing", "True"}}, true},
+		{"filesystem resize pending", "100Gi", "100Gi", []condition{{"FileSystemResizePending", "True"}}, true},
+		{"finished condition", "100Gi", "100Gi", []condition{{"Resizing", "False"}}, false},
+		{"no capacity yet", "100Gi", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claim persistentVolumeClaim
+			claim.Spec.Resources.Requests = map[string]string{"storage": tt.requested}
+			claim.Status.Capacity = map[string]string{"storage": tt.capacity}
+			for _, c := range tt.conditions {
+				claim.Status.Conditions = append(claim.Status.Conditions, struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				}{c.Type, c.Status})
+			}
+			if got := claim.resizing(); got != tt.want {
+				t.Errorf("resizing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
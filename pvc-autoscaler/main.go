@@ -0,0 +1,55 @@
+// pvc-autoscaler grows persistent volume claims before their filesystems
+// fill up. It reads the usage of every mounted claim from the kubelet stats
+// summaries, and when a claim is past its threshold raises its storage
+// request by a step, up to a ceiling, for the storage class to expand the
+// volume in place. Each expansion, failure and claim stuck at its ceiling is
+// recorded as an event on the claim and counted in the metrics.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "/etc/pvc-autoscaler/autoscaler.json", "path to the autoscaler config file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	kube, err := newInClusterClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	metrics := newMetrics()
+	server := &http.Server{
+		Addr:              cfg.MetricsListen,
+		Handler:           metrics,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("metrics server: %v", err)
+		}
+	}()
+
+	log.Printf("checking %d claims in %s every %ds", len(cfg.Volumes), cfg.Namespace, cfg.IntervalSeconds)
+	newAutoscaler(cfg, kube, metrics).run(ctx)
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// claimMetrics are the gauges and counters of one claim
+type claimMetrics struct {
+	used, capacity, ceiling int64
+	expansions, errors      uint64
+}
+
+// metrics are the autoscaler gauges and counters exposed in the prometheus text format
+type metrics struct {
+	mu     sync.Mutex
+	claims map[string]*claimMetrics
+}
+
+func newMetrics() *metrics {
+	return &metrics{claims: map[string]*claimMetrics{}}
+}
+
+// claim returns the metrics of a claim, the caller holds the lock
+func (m *metrics) claim(name string) *claimMetrics {
+	if m.claims[name] == nil {
+		m.claims[name] = &claimMetrics{}
+	}
+	return m.claims[name]
+}
+
+func (m *metrics) setUsage(claim string, used, capacity, ceiling int64) {
+	m.mu.Lock()
+	c := m.claim(claim)
+	c.used, c.capacity, c.ceiling = used, capacity, ceiling
+	m.mu.Unlock()
+}
+
+func (m *metrics) countExpansion(claim string) {
+	m.mu.Lock()
+	m.claim(claim).expansions++
+	m.mu.Unlock()
+}
+
+func (m *metrics) countError(claim string) {
+	m.mu.Lock()
+	m.claim(claim).errors++
+	m.mu.Unlock()
+}
+
+func labelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.claims))
+	for name := range m.claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	series := []struct {
+		name, kind, help string
+		value            func(*claimMetrics) string
+	}{
+		{"pvc_autoscaler_used_bytes", "gauge", "Bytes used on the filesystem of the claim.",
+			func(c *claimMetrics) string { return fmt.Sprint(c.used) }},
+		{"pvc_autoscaler_capacity_bytes", "gauge", "Size of the filesystem of the claim.",
+			func(c *claimMetrics) string { return fmt.Sprint(c.capacity) }},
+		{"pvc_autoscaler_ceiling_bytes", "gauge", "Size the claim is never expanded beyond.",
+			func(c *claimMetrics) string { return fmt.Sprint(c.ceiling) }},
+		{"pvc_autoscaler_expansions_total", "counter", "Expansions requested for the claim.",
+			func(c *claimMetrics) string { return fmt.Sprint(c.expansions) }},
+		{"pvc_autoscaler_errors_total", "counter", "Failed attempts to expand the claim.",
+			func(c *claimMetrics) string { return fmt.Sprint(c.errors) }},
+	}
+	for _, s := range series {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", s.name, s.help, s.name, s.kind)
+		for _, name := range names {
+			fmt.Fprintf(&out, "%s{claim=\"%s\"} %s\n", s.name, labelValue(name), s.value(m.claims[name]))
+		}
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(out.String()))
+}
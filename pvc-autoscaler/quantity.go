@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const gibibyte = 1 << 30
+
+// quantitySuffixes are the kubernetes quantity suffixes of byte sizes, binary first
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// parseQuantity converts a kubernetes quantity such as 100Gi or 1.5T to bytes
+func parseQuantity(quantity string) (int64, error) {
+	number, multiplier := quantity, 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			number, multiplier = strings.TrimSuffix(quantity, s.suffix), s.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%q is not a quantity", quantity)
+	}
+	return int64(math.Ceil(value * multiplier)), nil
+}
+
+// formatQuantity renders bytes as a quantity, in whole Gi when it divides evenly
+func formatQuantity(bytes int64) string {
+	if bytes%gibibyte == 0 {
+		return fmt.Sprintf("%dGi", bytes/gibibyte)
+	}
+	return strconv.FormatInt(bytes, 10)
+}
+
+// roundUpGi rounds bytes up to whole Gi, the granularity volumes are provisioned in
+func roundUpGi(bytes int64) int64 {
+	return (bytes + gibibyte - 1) / gibibyte * gibibyte
+}
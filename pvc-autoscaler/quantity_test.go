@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		want     int64
+		wantErr  bool
+	}{
+		{"100Gi", 100 << 30, false},
+		{"1Ti", 1 << 40, false},
+		{"512Mi", 512 << 20, false},
+		{"2Ki", 2048, false},
+		{"100G", 100e9, false},
+		{"1.5T", 1.5e12, false},
+		{"1k", 1000, false},
+		{"0.5Gi", 1 << 29, false},
+		// a fraction of a byte is rounded up
+		{"1.0001", 2, false},
+		{"1073741824", 1 << 30, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"Gi", 0, true},
+		{"ten", 0, true},
+		{"-1Gi", 0, true},
+		{"10GB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseQuantity(tt.quantity)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseQuantity(%q) error = %v, wantErr %v", tt.quantity, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseQuantity(%q) = %d, want %d", tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{100 << 30, "100Gi"},
+		{0, "0Gi"},
+		{1 << 40, "1024Gi"},
+		{100e9, "100000000000"},
+		{(1 << 30) + 1, "1073741825"},
+	}
+	for _, tt := range tests {
+		if got := formatQuantity(tt.bytes); got != tt.want {
+			t.Errorf("formatQuantity(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestRoundUpGi(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  int64
+	}{
+		{0, 0},
+		{1, 1 << 30},
+		{1 << 30, 1 << 30},
+		{(1 << 30) + 1, 2 << 30},
+		{100e9, 94 << 30},
+	}
+	for _, tt := range tests {
+		if got := roundUpGi(tt.bytes); got != tt.want {
+			t.Errorf("roundUpGi(%d) = %d, want %d", tt.bytes, got, tt.want)
+		}
+	}
+}
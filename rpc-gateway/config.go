@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Config is the gateway config file rendered by the node program
+type Config struct {
+	// Listen is the address the gateway serves json-rpc on, e.g. ":8545"
+	Listen string `json:"listen"`
+	// Upstream is the execution client rpc endpoint requests are forwarded to
+	Upstream string `json:"upstream"`
+	// Pool replaces Upstream with a set of replicas routed by head, when set
+	Pool *Pool `json:"pool"`
+	// MaxBodyBytes caps the size of a request body
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+	// MaxBatchSize caps the number of calls in a batch request
+	MaxBatchSize int `json:"maxBatchSize"`
+	// Routes map a request path to the methods it allows
+	Routes []Route `json:"routes"`
+	// KeysFile lists the api keys, every request needs one of them when it is set
+	KeysFile string `json:"keysFile"`
+	// MetricsListen is the address prometheus metrics are served on
+	MetricsListen string `json:"metricsListen"`
+	// MethodClasses group methods that share a rate limit, methods in no
+	// class belong to the default class
+	MethodClasses []MethodClass `json:"methodClasses"`
+	// RateLimits are the per key limits of each method class
+	RateLimits map[string]RateLimit `json:"rateLimits"`
+}
+
+// Route allows a set of methods on a path. A method ending in `*` allows
+// every method with that prefix, e.g. `eth_*`.
+type Route struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+}
+
+// MethodClass names a group of methods using the same patterns as a Route
+type MethodClass struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+const (
+	defaultClass         = "default"
+	defaultMetricsListen = ":9102"
+	defaultListen        = ":8545"
+	defaultMaxBodyBytes  = 1 << 20
+	defaultMaxBatchSize  = 100
+)
+
+// LoadConfig reads and validates the config file at path
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Listen == "" {
+		c.Listen = defaultListen
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if c.MetricsListen == "" {
+		c.MetricsListen = defaultMetricsListen
+	}
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = defaultMaxBatchSize
+	}
+	if c.MaxBodyBytes < 0 || c.MaxBatchSize < 0 {
+		return fmt.Errorf("maxBodyBytes and maxBatchSize cannot be negative")
+	}
+	if c.Pool != nil {
+		if err := c.Pool.validate(); err != nil {
+			return err
+		}
+	} else {
+		upstream, err := url.Parse(c.Upstream)
+		if err != nil || upstream.Host == "" {
+			return fmt.Errorf("upstream %q must be an absolute url", c.Upstream)
+		}
+	}
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("at least one route is required")
+	}
+	paths := map[string]bool{}
+	for _, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route path %q must start with /", route.Path)
+		}
+		if paths[route.Path] {
+			return fmt.Errorf("route path %q is defined more than once", route.Path)
+		}
+		paths[route.Path] = true
+		if len(route.Methods) == 0 {
+			return fmt.Errorf("route %s allows no methods", route.Path)
+		}
+	}
+	classes := map[string]bool{defaultClass: true}
+	for _, class := range c.MethodClasses {
+		if classes[class.Name] || class.Name == "" {
+			return fmt.Errorf("method class %q must be named and defined once, %s is reserved", class.Name, defaultClass)
+		}
+		classes[class.Name] = true
+	}
+	for class, limit := range c.RateLimits {
+		if !classes[class] {
+			return fmt.Errorf("rate limit for unknown method class %q", class)
+		}
+		if limit.RequestsPerSecond < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rate limit for %s cannot be negative", class)
+		}
+	}
+	return nil
+}
+
+// allowlist matches methods against a route's exact names and prefixes
+type allowlist struct {
+	exact    map[string]bool
+	prefixes []string
+}
+
+func newAllowlist(methods []string) *allowlist {
+	list := &allowlist{exact: map[string]bool{}}
+	for _, method := range methods {
+		if prefix, ok := strings.CutSuffix(method, "*"); ok {
+			list.prefixes = append(list.prefixes, prefix)
+		} else {
+			list.exact[method] = true
+		}
+	}
+	return list
+}
+
+func (a *allowlist) allows(method string) bool {
+	if a.exact[method] {
+		return true
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// codeRateLimited is the json-rpc error code commonly used by providers for exceeded limits
+const codeRateLimited = -32005
+
+// Gateway forwards json-rpc calls allowed by a route to the upstream
+// execution client and answers everything else with a json-rpc error.
+type Gateway struct {
+	cfg     *Config
+	routes  map[string]*allowlist
+	classes []methodClass
+	keys    *keyStore
+	pool    *upstreamPool
+	limiter *limiter
+	metrics *metrics
+	client  *http.Client
+}
+
+type methodClass struct {
+	name    string
+	methods *allowlist
+}
+
+// NewGateway builds a gateway for cfg, keys is nil when api keys are not
+// required and pool is nil when requests go to a single upstream
+func NewGateway(cfg *Config, keys *keyStore, pool *upstreamPool) *Gateway {
+	routes := map[string]*allowlist{}
+	for _, route := range cfg.Routes {
+		routes[route.Path] = newAllowlist(route.Methods)
+	}
+	var classes []methodClass
+	for _, class := range cfg.MethodClasses {
+		classes = append(classes, methodClass{name: class.Name, methods: newAllowlist(class.Methods)})
+	}
+	return &Gateway{
+		cfg:     cfg,
+		routes:  routes,
+		classes: classes,
+		keys:    keys,
+		pool:    pool,
+		limiter: newLimiter(),
+		metrics: newMetrics(pool),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// classify returns the first method class matching method
+func (g *Gateway) classify(method string) string {
+	for _, class := range g.classes {
+		if class.methods.allows(method) {
+			return class.name
+		}
+	}
+	return defaultClass
+}
+
+// rateLimit returns the limit of class for key, preferring the key's own limits
+func (g *Gateway) rateLimit(key *ApiKey, class string) RateLimit {
+	if key != nil {
+		if limit, ok := key.RateLimits[class]; ok {
+			return limit
+		}
+	}
+	return g.cfg.RateLimits[class]
+}
+
+// authenticate returns the api key of the request, or false when keys are
+// required and the request has no valid one
+func (g *Gateway) authenticate(r *http.Request) (*ApiKey, bool) {
+	if g.keys == nil {
+		return &ApiKey{Name: anonymousKey}, true
+	}
+	return g.keys.lookup(requestKey(r))
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	writeJson(w, status, newErrorResponse(nil, code, message))
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	allowed, ok := g.routes[r.URL.Path]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeInvalidRequest, fmt.Sprintf("no json-rpc route at %s", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, codeInvalidRequest, "json-rpc requests must use POST")
+		return
+	}
+
+	key, ok := g.authenticate(r)
+	if !ok {
+		g.metrics.countUnauthorized()
+		writeError(w, http.StatusUnauthorized, codeInvalidRequest, "missing or unknown api key")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, g.cfg.MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, codeInvalidRequest, fmt.Sprintf("request body exceeds %d bytes", g.cfg.MaxBodyBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "could not read request body")
+		return
+	}
+
+	calls, batch, err := parseBody(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeParseError, "invalid json")
+		return
+	}
+	if batch && len(calls) == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "empty batch")
+		return
+	}
+	if len(calls) > g.cfg.MaxBatchSize {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("batch of %d calls exceeds the limit of %d", len(calls), g.cfg.MaxBatchSize))
+		return
+	}
+
+	forward, rejected, limited := g.filter(key, calls, allowed)
+	if len(forward) == 0 {
+		status := http.StatusOK
+		if limited && !batch {
+			status = http.StatusTooManyRequests
+		}
+		g.writeRejected(w, status, rejected, batch)
+		return
+	}
+	// forward the body untouched unless some calls had to be dropped
+	if len(forward) != len(calls) {
+		if body, err = json.Marshal(forward); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternalError, "could not encode request")
+			return
+		}
+	}
+	g.forward(w, r, body, rejected)
+}
+
+// filter splits calls into the ones to forward and the errors for the rest,
+// taking a rate limit token for every allowed call. Notifications that are
+// not forwarded are dropped without a response. It also reports whether any
+// call was rate limited.
+func (g *Gateway) filter(key *ApiKey, calls []request, allowed *allowlist) ([]request, []errorResponse, bool) {
+	var forward []request
+	var rejected []errorResponse
+	limited := false
+	reject := func(call request, code int, message string) {
+		if !call.isNotification() {
+			rejected = append(rejected, newErrorResponse(call.Id, code, message))
+		}
+	}
+	for _, call := range calls {
+		class := g.classify(call.Method)
+		switch {
+		case call.JsonRpc != "2.0" || call.Method == "":
+			rejected = append(rejected, newErrorResponse(call.Id, codeInvalidRequest, "invalid json-rpc request"))
+			g.metrics.countCall(key.Name, class, resultRejected)
+		case !allowed.allows(call.Method):
+			reject(call, codeMethodNotFound, fmt.Sprintf("the method %s is not available on this endpoint", call.Method))
+			g.metrics.countCall(key.Name, class, resultRejected)
+		case !g.limiter.allow(key.Name, class, g.rateLimit(key, class)):
+			reject(call, codeRateLimited, fmt.Sprintf("rate limit exceeded for %s methods", class))
+			g.metrics.countCall(key.Name, class, resultRateLimited)
+			limited = true
+		default:
+			forward = append(forward, call)
+			g.metrics.countCall(key.Name, class, resultForwarded)
+		}
+	}
+	return forward, rejected, limited
+}
+
+func (g *Gateway) writeRejected(w http.ResponseWriter, status int, rejected []errorResponse, batch bool) {
+	switch {
+	case len(rejected) == 0:
+		// only notifications were sent, there is nothing to answer
+		w.WriteHeader(status)
+	case batch:
+		writeJson(w, status, rejected)
+	default:
+		writeJson(w, status, rejected[0])
+	}
+}
+
+// send posts body to the upstream, or to the pool replicas in turn until one
+// answers, taking replicas that fail out of rotation
+func (g *Gateway) send(r *http.Request, body []byte) (*http.Response, error) {
+	attempts := 1
+	if g.pool != nil {
+		_, eligible, _ := g.pool.status()
+		attempts = max(eligible, 1)
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		upstream := g.cfg.Upstream
+		if g.pool != nil {
+			if upstream, err = g.pool.pick(); err != nil {
+				return nil, err
+			}
+		}
+		var upstreamRequest *http.Request
+		upstreamRequest, err = http.NewRequestWithContext(r.Context(), http.MethodPost, upstream, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		upstreamRequest.Header.Set("Content-Type", "application/json")
+		var response *http.Response
+		if response, err = g.client.Do(upstreamRequest); err == nil {
+			return response, nil
+		}
+		log.Printf("upstream request to %s failed: %v", upstream, err)
+		if g.pool != nil {
+			g.pool.markDown(upstream)
+		}
+	}
+	return nil, err
+}
+
+// forward sends body upstream and relays the response, adding the rejected
+// calls to the upstream batch response when part of a batch was dropped
+func (g *Gateway) forward(w http.ResponseWriter, r *http.Request, body []byte, rejected []errorResponse) {
+	response, err := g.send(r, body)
+	if errors.Is(err, errNoReplicas) {
+		writeError(w, http.StatusServiceUnavailable, codeInternalError, "no upstream is in sync")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, codeInternalError, "upstream unavailable")
+		return
+	}
+	defer response.Body.Close()
+	upstreamBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, codeInternalError, "could not read upstream response")
+		return
+	}
+
+	if len(rejected) > 0 {
+		// an empty body means every forwarded call was a notification, so
+		// the rejected calls are the only responses
+		var results []json.RawMessage
+		var err error
+		if len(bytes.TrimSpace(upstreamBody)) > 0 {
+			err = json.Unmarshal(upstreamBody, &results)
+		}
+		if err == nil {
+			for _, rejection := range rejected {
+				encoded, _ := json.Marshal(rejection)
+				results = append(results, encoded)
+			}
+			writeJson(w, response.StatusCode, results)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode)
+	if _, err := w.Write(upstreamBody); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoUpstream answers every call with its method as the result and records
+// the methods it was sent
+type echoUpstream struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+func (u *echoUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	calls, batch, err := parseBody(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var results []map[string]interface{}
+	u.mu.Lock()
+	for _, call := range calls {
+		u.methods = append(u.methods, call.Method)
+		if !call.isNotification() {
+			results = append(results, map[string]interface{}{"jsonrpc": "2.0", "id": call.Id, "result": call.Method})
+		}
+	}
+	u.mu.Unlock()
+	switch {
+	case len(results) == 0:
+		w.WriteHeader(http.StatusOK)
+	case batch:
+		writeJson(w, http.StatusOK, results)
+	default:
+		writeJson(w, http.StatusOK, results[0])
+	}
+}
+
+func testGateway(t *testing.T, upstream *echoUpstream, keys *keyStore) *Gateway {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	cfg := &Config{
+		Upstream: server.URL,
+		Routes: []Route{
+			{Path: "/", Methods: []string{"eth_*", "net_version"}},
+			{Path: "/trace", Methods: []string{"eth_*", "trace_*"}},
+		},
+		MethodClasses: []MethodClass{{Name: "trace", Methods: []string{"trace_*"}}},
+		RateLimits: map[string]RateLimit{
+			"trace": {RequestsPerSecond: 0.001, Burst: 1},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return NewGateway(cfg, keys, nil)
+}
+
+func TestGatewayFilterAndForward(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		// response is the expected json body, empty when there is none
+		response string
+		// forwarded are the methods the upstream should see
+		forwarded []string
+	}{
+		{
+			name:      "allowed call",
+			path:      "/",
+			body:      `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`,
+			status:    http.StatusOK,
+			response:  `{"jsonrpc":"2.0","id":1,"result":"eth_blockNumber"}`,
+			forwarded: []string{"eth_blockNumber"},
+		},
+		{
+			name:     "denied call",
+			path:     "/",
+			body:     `{"jsonrpc":"2.0","id":"a","method":"debug_traceTransaction"}`,
+			status:   http.StatusOK,
+			response: `{"jsonrpc":"2.0","id":"a","error":{"code":-32601,"message":"the method debug_traceTransaction is not available on this endpoint"}}`,
+		},
+		{
+			name:     "method allowed on another route",
+			path:     "/",
+			body:     `{"jsonrpc":"2.0","id":1,"method":"trace_block"}`,
+			status:   http.StatusOK,
+			response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method trace_block is not available on this endpoint"}}`,
+		},
+		{
+			name:     "invalid version",
+			path:     "/",
+			body:     `{"jsonrpc":"1.0","id":1,"method":"eth_blockNumber"}`,
+			status:   http.StatusOK,
+			response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid json-rpc request"}}`,
+		},
+		{
+			name: "mixed batch",
+			path: "/",
+			body: `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},` +
+				`{"jsonrpc":"2.0","id":2,"method":"admin_peers"},` +
+				`{"jsonrpc":"2.0","id":3,"method":"net_version"}]`,
+			status: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","id":1,"result":"eth_chainId"},` +
+				`{"jsonrpc":"2.0","id":3,"result":"net_version"},` +
+				`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"the method admin_peers is not available on this endpoint"}}]`,
+			forwarded: []string{"eth_chainId", "net_version"},
+		},
+		{
+			name:   "fully denied batch",
+			path:   "/",
+			body:   `[{"jsonrpc":"2.0","id":1,"method":"admin_peers"},{"jsonrpc":"2.0","id":2,"method":"debug_setHead"}]`,
+			status: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method admin_peers is not available on this endpoint"}},` +
+				`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"the method debug_setHead is not available on this endpoint"}}]`,
+		},
+		{
+			name:      "allowed notification",
+			path:      "/",
+			body:      `{"jsonrpc":"2.0","method":"eth_blockNumber"}`,
+			status:    http.StatusOK,
+			forwarded: []string{"eth_blockNumber"},
+		},
+		{
+			name:   "denied notification gets no response",
+			path:   "/",
+			body:   `{"jsonrpc":"2.0","method":"admin_addPeer"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:      "denied notification dropped from batch",
+			path:      "/",
+			body:      `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","method":"admin_addPeer"}]`,
+			status:    http.StatusOK,
+			response:  `[{"jsonrpc":"2.0","id":1,"result":"eth_chainId"}]`,
+			forwarded: []string{"eth_chainId"},
+		},
+		{
+			name:      "batch of forwarded notifications and a denied call",
+			path:      "/",
+			body:      `[{"jsonrpc":"2.0","method":"eth_blockNumber"},{"jsonrpc":"2.0","id":1,"method":"admin_peers"}]`,
+			status:    http.StatusOK,
+			response:  `[{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method admin_peers is not available on this endpoint"}}]`,
+			forwarded: []string{"eth_blockNumber"},
+		},
+		{
+			name:     "empty batch",
+			path:     "/",
+			body:     `[]`,
+			status:   http.StatusBadRequest,
+			response: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"empty batch"}}`,
+		},
+		{
+			name:     "invalid json",
+			path:     "/",
+			body:     `{"jsonrpc":`,
+			status:   http.StatusBadRequest,
+			response: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid json"}}`,
+		},
+		{
+			name:     "unknown route",
+			path:     "/admin",
+			body:     `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`,
+			status:   http.StatusNotFound,
+			response: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"no json-rpc route at /admin"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &echoUpstream{}
+			gateway := testGateway(t, upstream, nil)
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			assertJson(t, recorder.Body.String(), tt.response)
+			if !reflect.DeepEqual(upstream.methods, tt.forwarded) {
+				t.Errorf("forwarded %v, want %v", upstream.methods, tt.forwarded)
+			}
+		})
+	}
+}
+
+func TestGatewayRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		response string
+	}{
+		{
+			// a single limited call is answered with 429
+			name:     "single call",
+			body:     `{"jsonrpc":"2.0","id":2,"method":"trace_block"}`,
+			status:   http.StatusTooManyRequests,
+			response: `{"jsonrpc":"2.0","id":2,"error":{"code":-32005,"message":"rate limit exceeded for trace methods"}}`,
+		},
+		{
+			// limited calls in a batch are per item errors next to the forwarded results
+			name:   "batch",
+			body:   `[{"jsonrpc":"2.0","id":2,"method":"trace_block"},{"jsonrpc":"2.0","id":3,"method":"eth_chainId"}]`,
+			status: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","id":3,"result":"eth_chainId"},` +
+				`{"jsonrpc":"2.0","id":2,"error":{"code":-32005,"message":"rate limit exceeded for trace methods"}}]`,
+		},
+		{
+			// a batch of only limited calls is still a 200 with per item errors
+			name:     "fully limited batch",
+			body:     `[{"jsonrpc":"2.0","id":2,"method":"trace_block"}]`,
+			status:   http.StatusOK,
+			response: `[{"jsonrpc":"2.0","id":2,"error":{"code":-32005,"message":"rate limit exceeded for trace methods"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := testGateway(t, &echoUpstream{}, nil)
+			// spend the single trace token
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/trace", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"trace_block"}`)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("first trace call status = %d", recorder.Code)
+			}
+
+			recorder = httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/trace", strings.NewReader(tt.body)))
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			assertJson(t, recorder.Body.String(), tt.response)
+		})
+	}
+}
+
+func TestGatewayApiKeys(t *testing.T) {
+	keys := &keyStore{keys: map[string]*ApiKey{
+		"secret": {Name: "alice"},
+		"tracer": {Name: "bob", RateLimits: map[string]RateLimit{"trace": {}}},
+	}}
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		target string
+		status int
+	}{
+		{"missing key", "", "", "/", http.StatusUnauthorized},
+		{"unknown key", "X-Api-Key", "wrong", "/", http.StatusUnauthorized},
+		{"header key", "X-Api-Key", "secret", "/", http.StatusOK},
+		{"bearer token", "Authorization", "Bearer secret", "/", http.StatusOK},
+		{"query parameter", "", "", "/?apikey=secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := testGateway(t, &echoUpstream{}, keys)
+			request := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			if tt.header != "" {
+				request.Header.Set(tt.header, tt.value)
+			}
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, request)
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+		})
+	}
+
+	t.Run("key rate limit overrides the gateway limit", func(t *testing.T) {
+		gateway := testGateway(t, &echoUpstream{}, keys)
+		for i := 0; i < 5; i++ {
+			request := httptest.NewRequest(http.MethodPost, "/trace", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"trace_block"}`))
+			request.Header.Set("X-Api-Key", "tracer")
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("call %d status = %d, want %d", i, recorder.Code, http.StatusOK)
+			}
+		}
+	})
+}
+
+func TestGatewayHttp(t *testing.T) {
+	gateway := testGateway(t, &echoUpstream{}, nil)
+
+	recorder := httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("healthz status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	recorder = httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET / status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := recorder.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("Allow = %q, want %q", allow, http.MethodPost)
+	}
+
+	gateway.cfg.MaxBatchSize = 1
+	recorder = httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}]`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("oversized batch status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+// assertJson compares two json documents ignoring formatting, an empty want
+// expects an empty body
+func assertJson(t *testing.T, got, want string) {
+	t.Helper()
+	if want == "" {
+		if strings.TrimSpace(got) != "" {
+			t.Errorf("body = %s, want none", got)
+		}
+		return
+	}
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Fatalf("body %q is not json: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("expected body %q is not json: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
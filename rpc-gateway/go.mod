@@ -0,0 +1,3 @@
+module rpc-gateway
+
+go 1.22.0
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Standard json-rpc 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// request is a single json-rpc call. Params are kept raw so allowed calls
+// are forwarded exactly as the client sent them.
+type request struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// isNotification reports whether the caller expects no response
+func (r *request) isNotification() bool {
+	return len(r.Id) == 0
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+// newErrorResponse builds an error for id, using null when the id is unknown
+func newErrorResponse(id json.RawMessage, code int, message string) errorResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return errorResponse{JsonRpc: "2.0", Id: id, Error: rpcError{Code: code, Message: message}}
+}
+
+// parseBody decodes a single call or a batch. It reports whether the body was a batch.
+func parseBody(body []byte) ([]request, bool, error) {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []request
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return nil, true, err
+		}
+		return batch, true, nil
+	}
+	var single request
+	if err := json.Unmarshal(trimmed, &single); err != nil {
+		return nil, false, err
+	}
+	return []request{single}, false, nil
+}
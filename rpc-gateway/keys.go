@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// anonymousKey names the callers when the gateway runs without api keys
+const anonymousKey = "anonymous"
+
+// ApiKey is an entry in the keys file. Name is used in logs and metrics so
+// the key itself never leaves the gateway.
+type ApiKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// RateLimits override the gateway limits for this key by method class
+	RateLimits map[string]RateLimit `json:"rateLimits"`
+}
+
+func parseKeys(raw []byte) (map[string]*ApiKey, error) {
+	var list []*ApiKey
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	keys := map[string]*ApiKey{}
+	names := map[string]bool{}
+	for _, key := range list {
+		if key.Name == "" || key.Key == "" {
+			return nil, fmt.Errorf("every api key needs a name and a key")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("api key name %q is used more than once", key.Name)
+		}
+		if _, ok := keys[key.Key]; ok {
+			return nil, fmt.Errorf("api key %q duplicates another key", key.Name)
+		}
+		names[key.Name] = true
+		keys[key.Key] = key
+	}
+	return keys, nil
+}
+
+// keyStore holds the api keys from the keys file and reloads them when the
+// mounted secret changes
+type keyStore struct {
+	path     string
+	mu       sync.RWMutex
+	keys     map[string]*ApiKey
+	modified time.Time
+}
+
+func newKeyStore(path string) (*keyStore, error) {
+	store := &keyStore{path: path}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *keyStore) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modified)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeys(raw)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.modified = info.ModTime()
+	s.mu.Unlock()
+	log.Printf("loaded %d api keys", len(keys))
+	return nil
+}
+
+// watch reloads the keys every interval, keeping the current keys when the file is invalid
+func (s *keyStore) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.reload(); err != nil {
+			log.Printf("reloading api keys: %v", err)
+		}
+	}
+}
+
+func (s *keyStore) lookup(key string) (*ApiKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	apiKey, ok := s.keys[key]
+	return apiKey, ok
+}
+
+// requestKey reads the api key from the X-Api-Key header, a bearer token or
+// the apikey query parameter for clients that cannot set headers
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("apikey")
+}
@@ -0,0 +1,84 @@
+// rpc-gateway is a json-rpc proxy that sits in front of an execution client's
+// rpc service. It only forwards the methods allowed on each route, caps the
+// size of request bodies and batches, and when api keys are configured
+// authenticates callers and rate limits them per key and method class. With a
+// pool it spreads requests over the replicas that are close to the best head.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "/etc/rpc-gateway/gateway.json", "path to the gateway config file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var keys *keyStore
+	if cfg.KeysFile != "" {
+		if keys, err = newKeyStore(cfg.KeysFile); err != nil {
+			log.Fatal(err)
+		}
+		go keys.watch(30 * time.Second)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var pool *upstreamPool
+	upstream := cfg.Upstream
+	if cfg.Pool != nil {
+		pool = newUpstreamPool(cfg.Pool)
+		go pool.watch(ctx)
+		upstream = fmt.Sprintf("the replicas of %s within %d blocks of the best head", cfg.Pool.Host, cfg.Pool.MaxBlockLag)
+	}
+	gateway := NewGateway(cfg, keys, pool)
+
+	go func() {
+		metricsServer := &http.Server{
+			Addr:              cfg.MetricsListen,
+			Handler:           gateway.metrics,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		if err := metricsServer.ListenAndServe(); err != nil {
+			log.Fatalf("metrics server: %v", err)
+		}
+	}()
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           gateway,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      90 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdown); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("forwarding %d routes on %s to %s", len(cfg.Routes), cfg.Listen, upstream)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-stopped
+}
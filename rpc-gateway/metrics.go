@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Results a json-rpc call is counted under
+const (
+	resultForwarded   = "forwarded"
+	resultRejected    = "rejected"
+	resultRateLimited = "rate_limited"
+)
+
+// metrics are the gateway counters exposed in the prometheus text format
+type metrics struct {
+	mu           sync.Mutex
+	calls        map[[3]string]uint64
+	unauthorized uint64
+	// pool reports the replica heads, it is nil without a pool
+	pool *upstreamPool
+}
+
+func newMetrics(pool *upstreamPool) *metrics {
+	return &metrics{calls: map[[3]string]uint64{}, pool: pool}
+}
+
+func (m *metrics) countCall(key, class, result string) {
+	m.mu.Lock()
+	m.calls[[3]string{key, class, result}]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) countUnauthorized() {
+	m.mu.Lock()
+	m.unauthorized++
+	m.mu.Unlock()
+}
+
+func labelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	labels := make([][3]string, 0, len(m.calls))
+	for label := range m.calls {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return strings.Join(labels[i][:], "/") < strings.Join(labels[j][:], "/")
+	})
+
+	var out strings.Builder
+	out.WriteString("# HELP rpc_gateway_calls_total JSON-RPC calls by api key, method class and result.\n")
+	out.WriteString("# TYPE rpc_gateway_calls_total counter\n")
+	for _, label := range labels {
+		fmt.Fprintf(&out, "rpc_gateway_calls_total{key=\"%s\",class=\"%s\",result=\"%s\"} %d\n",
+			labelValue(label[0]), labelValue(label[1]), labelValue(label[2]), m.calls[label])
+	}
+	out.WriteString("# HELP rpc_gateway_unauthorized_total Requests rejected for a missing or unknown api key.\n")
+	out.WriteString("# TYPE rpc_gateway_unauthorized_total counter\n")
+	fmt.Fprintf(&out, "rpc_gateway_unauthorized_total %d\n", m.unauthorized)
+	m.mu.Unlock()
+
+	if m.pool != nil {
+		best, eligible, total := m.pool.status()
+		out.WriteString("# HELP rpc_gateway_pool_best_head Highest block number reported by a pool replica.\n")
+		out.WriteString("# TYPE rpc_gateway_pool_best_head gauge\n")
+		fmt.Fprintf(&out, "rpc_gateway_pool_best_head %d\n", best)
+		out.WriteString("# HELP rpc_gateway_pool_replicas Pool replicas, by whether they are close enough to the best head to get requests.\n")
+		out.WriteString("# TYPE rpc_gateway_pool_replicas gauge\n")
+		fmt.Fprintf(&out, "rpc_gateway_pool_replicas{eligible=\"true\"} %d\n", eligible)
+		fmt.Fprintf(&out, "rpc_gateway_pool_replicas{eligible=\"false\"} %d\n", total-eligible)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(out.String()))
+}
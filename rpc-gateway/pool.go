@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pool spreads requests over the replicas behind a headless service, only
+// routing to replicas whose head is within MaxBlockLag blocks of the best
+// head seen across the pool
+type Pool struct {
+	// Host is resolved to the replica addresses, e.g. the headless service of the execution clients
+	Host string `json:"host"`
+	// Port is the rpc port of every replica
+	Port int `json:"port"`
+	// MaxBlockLag is how far behind the best head a replica may be and still get requests
+	MaxBlockLag uint64 `json:"maxBlockLag"`
+	// PollIntervalSeconds is how often replicas are resolved and their heads polled
+	PollIntervalSeconds int `json:"pollIntervalSeconds"`
+}
+
+const (
+	defaultMaxBlockLag         = 2
+	defaultPollIntervalSeconds = 2
+)
+
+func (p *Pool) validate() error {
+	if p.Host == "" || p.Port <= 0 {
+		return fmt.Errorf("pool needs a host and a port")
+	}
+	if p.MaxBlockLag == 0 {
+		p.MaxBlockLag = defaultMaxBlockLag
+	}
+	if p.PollIntervalSeconds == 0 {
+		p.PollIntervalSeconds = defaultPollIntervalSeconds
+	}
+	if p.PollIntervalSeconds < 0 {
+		return fmt.Errorf("pool pollIntervalSeconds cannot be negative")
+	}
+	return nil
+}
+
+// replica is one upstream of the pool and the head it last reported
+type replica struct {
+	url  string
+	head uint64
+	// up is false when the last poll or request to the replica failed
+	up bool
+}
+
+// errNoReplicas is returned when no replica is close enough to the best head
+var errNoReplicas = errors.New("no replica within range of the best head")
+
+// upstreamPool tracks the heads of the pool replicas and picks one per request
+type upstreamPool struct {
+	cfg    *Pool
+	client *http.Client
+	// lookup resolves the pool host to the replica addresses
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mu       sync.Mutex
+	replicas map[string]*replica
+	best     uint64
+	next     int
+}
+
+func newUpstreamPool(cfg *Pool) *upstreamPool {
+	return &upstreamPool{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		lookup:   net.DefaultResolver.LookupHost,
+		replicas: map[string]*replica{},
+	}
+}
+
+// watch refreshes the pool until ctx is done
+func (p *upstreamPool) watch(ctx context.Context) {
+	interval := time.Duration(p.cfg.PollIntervalSeconds) * time.Second
+	for {
+		p.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// refresh resolves the pool host and polls the head of every replica
+func (p *upstreamPool) refresh(ctx context.Context) {
+	addrs, err := p.lookup(ctx, p.cfg.Host)
+	if err != nil {
+		log.Printf("resolving pool %s: %v", p.cfg.Host, err)
+		return
+	}
+
+	heads := map[string]uint64{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		url := fmt.Sprintf("http://%s", net.JoinHostPort(addr, strconv.Itoa(p.cfg.Port)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			head, err := p.blockNumber(ctx, url)
+			if err != nil {
+				log.Printf("polling %s: %v", url, err)
+				return
+			}
+			mu.Lock()
+			heads[url] = head
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	replicas := map[string]*replica{}
+	var best uint64
+	for _, addr := range addrs {
+		url := fmt.Sprintf("http://%s", net.JoinHostPort(addr, strconv.Itoa(p.cfg.Port)))
+		head, ok := heads[url]
+		replicas[url] = &replica{url: url, head: head, up: ok}
+		if ok && head > best {
+			best = head
+		}
+	}
+	p.replicas = replicas
+	p.best = best
+}
+
+// blockNumber asks a replica for its head
+func (p *upstreamPool) blockNumber(ctx context.Context, url string) (uint64, error) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(result.Result, "0x"), 16, 64)
+}
+
+// eligible returns the urls of the replicas within range of the best head, in a stable order
+func (p *upstreamPool) eligible() []string {
+	var urls []string
+	for url, replica := range p.replicas {
+		if replica.up && replica.head+p.cfg.MaxBlockLag >= p.best {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// pick returns the next eligible replica, round robin
+func (p *upstreamPool) pick() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	urls := p.eligible()
+	if len(urls) == 0 {
+		return "", errNoReplicas
+	}
+	p.next = (p.next + 1) % len(urls)
+	return urls[p.next], nil
+}
+
+// markDown takes a replica out of rotation until the next poll finds it healthy
+func (p *upstreamPool) markDown(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if replica, ok := p.replicas[url]; ok {
+		replica.up = false
+	}
+}
+
+// status reports the best head and the number of eligible and known replicas
+func (p *upstreamPool) status() (best uint64, eligible, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.best, len(p.eligible()), len(p.replicas)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeReplica answers eth_blockNumber with its head and every other call
+// with the url it was sent to, or drops every connection while it is down
+type fakeReplica struct {
+	mu   sync.Mutex
+	url  string
+	head uint64
+	down bool
+}
+
+func (r *fakeReplica) set(head uint64, down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.head, r.down = head, down
+}
+
+func (r *fakeReplica) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	var call request
+	if err := json.NewDecoder(req.Body).Decode(&call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := r.url
+	if call.Method == "eth_blockNumber" {
+		result = fmt.Sprintf("0x%x", r.head)
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"jsonrpc": "2.0", "id": call.Id, "result": result})
+}
+
+// testPool starts one replica per head, each on its own loopback address and
+// all on the same port like the pods behind a headless service
+func testPool(t *testing.T, heads ...uint64) (*upstreamPool, []*fakeReplica) {
+	t.Helper()
+	var replicas []*fakeReplica
+	var addrs []string
+	port := "0"
+	for i, head := range heads {
+		addr := fmt.Sprintf("127.0.0.%d", i+1)
+		listener, err := net.Listen("tcp", net.JoinHostPort(addr, port))
+		if err != nil {
+			t.Skipf("listening on %s: %v", addr, err)
+		}
+		_, port, _ = net.SplitHostPort(listener.Addr().String())
+		replica := &fakeReplica{url: "http://" + listener.Addr().String(), head: head}
+		server := &httptest.Server{Listener: listener, Config: &http.Server{Handler: replica}}
+		server.Start()
+		t.Cleanup(server.Close)
+		replicas = append(replicas, replica)
+		addrs = append(addrs, addr)
+	}
+
+	portNumber, _ := strconv.Atoi(port)
+	cfg := &Pool{Host: "reth.default.svc", Port: portNumber}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	pool := newUpstreamPool(cfg)
+	pool.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if host != cfg.Host {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return addrs, nil
+	}
+	return pool, replicas
+}
+
+// picks returns the replicas pick rotates over, in order
+func picks(t *testing.T, pool *upstreamPool) []string {
+	t.Helper()
+	_, eligible, _ := pool.status()
+	var urls []string
+	for i := 0; i < eligible; i++ {
+		url, err := pool.pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+func TestPoolEligible(t *testing.T) {
+	tests := []struct {
+		name  string
+		heads []uint64
+		down  []bool
+		// want are the indexes of the replicas that should get requests
+		want []int
+	}{
+		{name: "all in sync", heads: []uint64{100, 100, 100}, want: []int{0, 1, 2}},
+		{name: "within the allowed lag", heads: []uint64{100, 98, 99}, want: []int{0, 1, 2}},
+		{name: "lagging replica excluded", heads: []uint64{100, 97, 100}, want: []int{0, 2}},
+		{name: "best head from any replica", heads: []uint64{50, 100, 101}, want: []int{1, 2}},
+		{name: "down replica excluded", heads: []uint64{100, 100, 100}, down: []bool{false, true, false}, want: []int{0, 2}},
+		{name: "down replica does not set the best head", heads: []uint64{100, 100, 200}, down: []bool{false, false, true}, want: []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, replicas := testPool(t, tt.heads...)
+			for i, down := range tt.down {
+				replicas[i].set(tt.heads[i], down)
+			}
+			pool.refresh(context.Background())
+
+			var want []string
+			for _, i := range tt.want {
+				want = append(want, replicas[i].url)
+			}
+			pool.mu.Lock()
+			got := pool.eligible()
+			pool.mu.Unlock()
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("eligible = %v, want %v", got, want)
+			}
+			best, eligible, total := pool.status()
+			if eligible != len(want) || total != len(tt.heads) {
+				t.Errorf("status = %d eligible of %d, want %d of %d", eligible, total, len(want), len(tt.heads))
+			}
+			if best == 0 {
+				t.Errorf("best head not set")
+			}
+		})
+	}
+}
+
+func TestPoolPickRoundRobin(t *testing.T) {
+	pool, replicas := testPool(t, 100, 100, 90)
+	pool.refresh(context.Background())
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		url, err := pool.pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[url]++
+	}
+	if seen[replicas[0].url] != 3 || seen[replicas[1].url] != 3 || seen[replicas[2].url] != 0 {
+		t.Errorf("picks = %v, want three each for the replicas in sync", seen)
+	}
+}
+
+func TestPoolMarkDown(t *testing.T) {
+	pool, replicas := testPool(t, 100, 100)
+	pool.refresh(context.Background())
+
+	pool.markDown(replicas[0].url)
+	for _, url := range picks(t, pool) {
+		if url == replicas[0].url {
+			t.Fatalf("picked %s after it was marked down", url)
+		}
+	}
+	// unknown replicas, e.g. ones dropped by a refresh, are ignored
+	pool.markDown("http://10.0.0.1:8545")
+
+	// the next poll finds the replica healthy and puts it back in rotation
+	pool.refresh(context.Background())
+	if _, eligible, _ := pool.status(); eligible != 2 {
+		t.Fatalf("eligible after refresh = %d, want 2", eligible)
+	}
+
+	// a replica that still fails its poll stays out
+	pool.markDown(replicas[1].url)
+	replicas[1].set(100, true)
+	pool.refresh(context.Background())
+	if got := picks(t, pool); fmt.Sprint(got) != fmt.Sprint([]string{replicas[0].url}) {
+		t.Errorf("picks = %v, want only %s", got, replicas[0].url)
+	}
+}
+
+func TestPoolNoReplicas(t *testing.T) {
+	pool, replicas := testPool(t, 100, 100)
+	if _, err := pool.pick(); err != errNoReplicas {
+		t.Errorf("pick before the first refresh = %v, want %v", err, errNoReplicas)
+	}
+
+	replicas[0].set(100, true)
+	replicas[1].set(100, true)
+	pool.refresh(context.Background())
+	if _, err := pool.pick(); err != errNoReplicas {
+		t.Errorf("pick with every replica down = %v, want %v", err, errNoReplicas)
+	}
+}
+
+func TestGatewayPool(t *testing.T) {
+	pool, replicas := testPool(t, 100, 95)
+	pool.refresh(context.Background())
+	cfg := &Config{Pool: pool.cfg, Routes: []Route{{Path: "/", Methods: []string{"eth_*"}}}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	gateway := NewGateway(cfg, nil, pool)
+
+	call := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)))
+		return recorder
+	}
+
+	// requests only reach the replica near the best head
+	for i := 0; i < 3; i++ {
+		recorder := call()
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), replicas[0].url) {
+			t.Fatalf("response = %d %s, want an answer from %s", recorder.Code, recorder.Body, replicas[0].url)
+		}
+	}
+
+	// a replica that drops a request is taken out of rotation and the
+	// request is retried on the other one
+	replicas[1].set(100, false)
+	pool.refresh(context.Background())
+	replicas[0].set(100, true)
+	for i := 0; i < 3; i++ {
+		recorder := call()
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), replicas[1].url) {
+			t.Fatalf("response with %s down = %d %s, want an answer from %s", replicas[0].url, recorder.Code, recorder.Body, replicas[1].url)
+		}
+	}
+	if _, eligible, _ := pool.status(); eligible != 1 {
+		t.Errorf("eligible after a failed request = %d, want 1", eligible)
+	}
+
+	// without a replica in sync the gateway answers unavailable rather than
+	// sending requests to a lagging node
+	replicas[0].set(100, false)
+	replicas[1].set(90, false)
+	pool.refresh(context.Background())
+	pool.markDown(replicas[0].url)
+	recorder := call()
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status with no replica in sync = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	assertJson(t, recorder.Body.String(), `{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"no upstream is in sync"}}`)
+}
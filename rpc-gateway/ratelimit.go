@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket refilled at RequestsPerSecond up to Burst
+// tokens. A zero rate leaves the calls unlimited.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+func (l RateLimit) unlimited() bool {
+	return l.RequestsPerSecond <= 0
+}
+
+// capacity is the bucket size, at least one call so a limit always lets something through
+func (l RateLimit) capacity() float64 {
+	return math.Max(float64(l.Burst), 1)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter keeps a token bucket per api key and method class
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// allow takes a token from the bucket for key and class if one is available
+func (l *limiter) allow(key, class string, limit RateLimit) bool {
+	if limit.unlimited() {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	id := key + "/" + class
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: limit.capacity(), last: now}
+		l.buckets[id] = b
+	}
+	// refill for the time since the last call, the limit may have been
+	// changed by a key reload so the capacity is applied on every call
+	b.tokens = math.Min(limit.capacity(), b.tokens+now.Sub(b.last).Seconds()*limit.RequestsPerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
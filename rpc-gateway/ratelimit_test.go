@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimiter returns a limiter whose clock only moves when advance is called
+func testLimiter() (*limiter, func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	l := newLimiter()
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l, advance := testLimiter()
+	limit := RateLimit{RequestsPerSecond: 2, Burst: 3}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    bool
+	}{
+		{"burst 1", 0, true},
+		{"burst 2", 0, true},
+		{"burst 3", 0, true},
+		{"bucket empty", 0, false},
+		{"quarter second is half a token", 250 * time.Millisecond, false},
+		{"half second refills one token", 250 * time.Millisecond, true},
+		{"token spent", 0, false},
+		{"long idle", time.Minute, true},
+		{"refill capped at burst 2", 0, true},
+		{"refill capped at burst 3", 0, true},
+		{"refill capped at burst", 0, false},
+	}
+	for _, step := range steps {
+		advance(step.advance)
+		if got := l.allow("key", "default", limit); got != step.want {
+			t.Fatalf("%s: allow = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestLimiterBucketsPerKeyAndClass(t *testing.T) {
+	l, _ := testLimiter()
+	limit := RateLimit{RequestsPerSecond: 1, Burst: 1}
+
+	if !l.allow("a", "default", limit) {
+		t.Fatal("first call of a/default was limited")
+	}
+	if l.allow("a", "default", limit) {
+		t.Fatal("second call of a/default was allowed")
+	}
+	if !l.allow("b", "default", limit) {
+		t.Fatal("b shares a bucket with a")
+	}
+	if !l.allow("a", "trace", limit) {
+		t.Fatal("trace shares a bucket with default")
+	}
+}
+
+func TestLimiterLimits(t *testing.T) {
+	l, _ := testLimiter()
+
+	for i := 0; i < 100; i++ {
+		if !l.allow("key", "default", RateLimit{}) {
+			t.Fatalf("call %d limited by a zero rate", i)
+		}
+	}
+	// a zero burst still lets one call through
+	zeroBurst := RateLimit{RequestsPerSecond: 1}
+	if !l.allow("key", "zero", zeroBurst) {
+		t.Fatal("zero burst limited the first call")
+	}
+	if l.allow("key", "zero", zeroBurst) {
+		t.Fatal("zero burst allowed a second call")
+	}
+}
+
+func TestLimiterShrunkLimit(t *testing.T) {
+	l, advance := testLimiter()
+
+	if !l.allow("key", "default", RateLimit{RequestsPerSecond: 1, Burst: 10}) {
+		t.Fatal("first call limited")
+	}
+	// a reloaded key with a smaller burst caps the tokens left in the bucket
+	advance(time.Minute)
+	small := RateLimit{RequestsPerSecond: 1, Burst: 1}
+	if !l.allow("key", "default", small) {
+		t.Fatal("call after reload limited")
+	}
+	if l.allow("key", "default", small) {
+		t.Fatal("bucket kept tokens above the new burst")
+	}
+}
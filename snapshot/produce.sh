@@ -0,0 +1,110 @@
+#!/bin/sh
+# produce archives the data volumes of the last replica of a statefulset and
+# publishes them for restore. The replica is stopped first so the databases
+# are consistent on disk, and started again however the run ends. The job
+# runs on the replica's node with its volumes already mounted, which is what
+# lets it keep them while the replica is down.
+#
+#   NAMESPACE, STATEFULSET  the statefulset whose last replica is archived
+#   POD                     the name of that replica
+#   ARCHIVES                space separated client:kind:path entries, kind is
+#                           execution or consensus and path the mounted volume
+#   SNAPSHOT_PREFIX         s3://bucket/prefix the archives are published under
+#   RETAIN                  how many snapshots of each client are kept
+#   EXECUTION_RPC_PORT      json-rpc port, for the block number and version
+#   CONSENSUS_HTTP_PORT     beacon api port, for the slot and version
+#   AWS_ENDPOINT_URL        the object store endpoint, unset for aws s3
+set -eu -o pipefail
+
+: "${NAMESPACE:?}" "${STATEFULSET:?}" "${POD:?}" "${ARCHIVES:?}" "${SNAPSHOT_PREFIX:?}"
+RETAIN="${RETAIN:-3}"
+
+replicas="$(kubectl -n "$NAMESPACE" get statefulset "$STATEFULSET" -o jsonpath='{.spec.replicas}')"
+if [ "$POD" != "$STATEFULSET-$((replicas - 1))" ]; then
+	echo "$POD is not the last replica of $STATEFULSET with $replicas replicas, skipping" >&2
+	exit 1
+fi
+ip="$(kubectl -n "$NAMESPACE" get pod "$POD" -o jsonpath='{.status.podIP}')"
+
+rpc() {
+	curl -sf -m 10 -H 'Content-Type: application/json' \
+		-d "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"$1\",\"params\":[]}" \
+		"http://$ip:$EXECUTION_RPC_PORT" | jq -r .result
+}
+
+beacon() {
+	curl -sf -m 10 "http://$ip:$CONSENSUS_HTTP_PORT$1" | jq -r "$2"
+}
+
+# record where each client is before it stops, the manifest tells restoring
+# nodes what they get. blockNumber is the head slot for consensus clients.
+meta="$(mktemp -d)"
+for entry in $ARCHIVES; do
+	client="${entry%%:*}"
+	kind="$(echo "$entry" | cut -d: -f2)"
+	case "$kind" in
+	execution)
+		echo "$(($(rpc eth_blockNumber)))" >"$meta/$client.block"
+		rpc web3_clientVersion >"$meta/$client.version"
+		;;
+	consensus)
+		beacon /eth/v1/beacon/headers/head .data.header.message.slot >"$meta/$client.block"
+		beacon /eth/v1/node/version .data.version >"$meta/$client.version"
+		;;
+	esac
+done
+
+scale() {
+	kubectl -n "$NAMESPACE" scale statefulset "$STATEFULSET" --replicas="$1"
+}
+trap 'scale "$replicas"' EXIT
+scale "$((replicas - 1))"
+kubectl -n "$NAMESPACE" wait --for=delete "pod/$POD" --timeout=15m
+
+stamp="$(date -u +%Y%m%dT%H%M%SZ)"
+for entry in $ARCHIVES; do
+	client="${entry%%:*}"
+	dir="${entry##*:}"
+	key="$SNAPSHOT_PREFIX/$client/$stamp"
+	echo "archiving $dir to $key.tar.zst"
+
+	checksum="$(mktemp)"
+	fifo="$(mktemp -u)"
+	mkfifo "$fifo"
+	sha256sum <"$fifo" | cut -d' ' -f1 >"$checksum" &
+	tar -C "$dir" --exclude=./lost+found --exclude=./.snapshot-restore -c . |
+		zstd -T0 -3 -q |
+		tee "$fifo" |
+		aws s3 cp --no-progress --expected-size "$(du -sb "$dir" | cut -f1)" - "$key.tar.zst"
+	wait
+	sum="$(cat "$checksum")"
+	rm -f "$fifo" "$checksum"
+
+	echo "$sum  $stamp.tar.zst" | aws s3 cp --no-progress - "$key.tar.zst.sha256"
+	jq -n \
+		--arg client "$client" \
+		--arg version "$(cat "$meta/$client.version")" \
+		--argjson block "$(cat "$meta/$client.block")" \
+		--arg archive "$key.tar.zst" \
+		--arg sha256 "$sum" \
+		--arg created "$stamp" \
+		'{client: $client, clientVersion: $version, blockNumber: $block, archive: $archive, sha256: $sha256, created: $created}' >"$meta/$client.json"
+	aws s3 cp --no-progress "$meta/$client.json" "$key.json"
+	aws s3 cp --no-progress "$meta/$client.json" "$SNAPSHOT_PREFIX/$client/latest.json"
+done
+
+# the replica can serve again before old snapshots are pruned
+trap - EXIT
+scale "$replicas"
+
+for entry in $ARCHIVES; do
+	client="${entry%%:*}"
+	aws s3 ls "$SNAPSHOT_PREFIX/$client/" | awk '{print $4}' | grep -E '^[0-9TZ]+\.json$' | sort -r |
+		tail -n "+$((RETAIN + 1))" | while read -r manifest; do
+		old="${manifest%.json}"
+		echo "pruning $SNAPSHOT_PREFIX/$client/$old"
+		for suffix in .tar.zst .tar.zst.sha256 .json; do
+			aws s3 rm "$SNAPSHOT_PREFIX/$client/$old$suffix"
+		done
+	done
+done
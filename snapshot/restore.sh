@@ -0,0 +1,60 @@
+#!/bin/sh
+# restore fills an empty client data directory from a zstd compressed tar
+# archive in an s3 compatible bucket. The archive is streamed into a staging
+# directory and only moved into place once its sha256 checksum matches, so an
+# interrupted or corrupt download never leaves a half restored database.
+#
+#   SNAPSHOT_URL     s3://bucket/key of the archive, or of a manifest written
+#                    by produce such as .../reth/latest.json
+#   SNAPSHOT_SHA256  expected checksum, read from SNAPSHOT_URL.sha256 or the
+#                    manifest when unset
+#   DATA_DIR         the client data directory to restore into
+#   AWS_ENDPOINT_URL the object store endpoint, unset for aws s3
+set -eu -o pipefail
+
+: "${SNAPSHOT_URL:?SNAPSHOT_URL is required}"
+: "${DATA_DIR:?DATA_DIR is required}"
+
+staging="$DATA_DIR/.snapshot-restore"
+mkdir -p "$DATA_DIR"
+rm -rf "$staging"
+
+# anything but the filesystem's lost+found means the client already has data
+if [ -n "$(ls -A "$DATA_DIR" | grep -v '^lost+found$' || true)" ]; then
+	echo "$DATA_DIR already has data, skipping the snapshot restore"
+	exit 0
+fi
+
+expected="${SNAPSHOT_SHA256:-}"
+case "$SNAPSHOT_URL" in
+*.json)
+	manifest="$(aws s3 cp --no-progress "$SNAPSHOT_URL" -)"
+	echo "restoring the snapshot of block $(echo "$manifest" | jq -r .blockNumber) from $SNAPSHOT_URL"
+	SNAPSHOT_URL="$(echo "$manifest" | jq -r .archive)"
+	expected="${expected:-$(echo "$manifest" | jq -r .sha256)}"
+	;;
+esac
+if [ -z "$expected" ]; then
+	expected="$(aws s3 cp --no-progress "$SNAPSHOT_URL.sha256" - | cut -d' ' -f1)"
+fi
+
+echo "restoring $SNAPSHOT_URL into $DATA_DIR"
+mkdir -p "$staging"
+checksum="$(mktemp)"
+fifo="$(mktemp -u)"
+mkfifo "$fifo"
+sha256sum <"$fifo" | cut -d' ' -f1 >"$checksum" &
+aws s3 cp --no-progress "$SNAPSHOT_URL" - | tee "$fifo" | zstd -dc | tar -x -C "$staging"
+wait
+actual="$(cat "$checksum")"
+rm -f "$fifo" "$checksum"
+
+if [ "$actual" != "$expected" ]; then
+	echo "checksum mismatch for $SNAPSHOT_URL: expected $expected, got $actual" >&2
+	rm -rf "$staging"
+	exit 1
+fi
+
+find "$staging" -mindepth 1 -maxdepth 1 -exec mv -t "$DATA_DIR" {} +
+rmdir "$staging"
+echo "restored $SNAPSHOT_URL ($actual)"
@@ -0,0 +1,54 @@
+#!/bin/sh
+# volume-snapshot takes a csi VolumeSnapshot of each claim and prunes the
+# oldest ones beyond the retention. Snapshots are taken of running volumes,
+# which is crash consistent, the same state a node finds after losing power.
+# Old snapshots are only pruned once the new one is ready, so a failing
+# snapshot never eats into the retained ones.
+#
+#   NAMESPACE   the namespace of the claims
+#   CLAIMS      space separated persistent volume claim names
+#   CLASS       the VolumeSnapshotClass to snapshot with
+#   RETAIN      how many snapshots of each claim are kept
+set -eu
+
+: "${NAMESPACE:?}" "${CLAIMS:?}" "${CLASS:?}"
+RETAIN="${RETAIN:-7}"
+label=swannynode/volume-snapshot-of
+
+stamp="$(date -u +%Y%m%d%H%M%S)"
+status=0
+for claim in $CLAIMS; do
+	if ! kubectl -n "$NAMESPACE" get pvc "$claim" >/dev/null 2>&1; then
+		echo "claim $claim does not exist yet, skipping" >&2
+		continue
+	fi
+	name="$claim-$stamp"
+	echo "snapshotting $claim as $name"
+	kubectl -n "$NAMESPACE" apply -f - <<EOF
+apiVersion: snapshot.storage.k8s.io/v1
+kind: VolumeSnapshot
+metadata:
+  name: $name
+  labels:
+    $label: $claim
+spec:
+  volumeSnapshotClassName: $CLASS
+  source:
+    persistentVolumeClaimName: $claim
+EOF
+	if ! kubectl -n "$NAMESPACE" wait --for=jsonpath='{.status.readyToUse}'=true \
+		"volumesnapshot/$name" --timeout=6h; then
+		echo "snapshot $name is not ready, keeping the old ones" >&2
+		status=1
+		continue
+	fi
+
+	kubectl -n "$NAMESPACE" get volumesnapshot -l "$label=$claim" \
+		--sort-by=.metadata.creationTimestamp -o jsonpath='{range .items[*]}{.metadata.name}{"\n"}{end}' |
+		head -n "-$RETAIN" |
+		while read -r old; do
+			echo "pruning $old"
+			kubectl -n "$NAMESPACE" delete volumesnapshot "$old" --wait=false
+		done
+done
+exit "$status"
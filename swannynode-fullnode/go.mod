@@ -6,6 +6,7 @@ toolchain go1.22.3
 
 require (
 	github.com/pulumi/pulumi-command/sdk v0.10.0
+	github.com/pulumi/pulumi-random/sdk/v4 v4.8.2
 	github.com/pulumi/pulumi/sdk/v3 v3.116.0
 	github.com/rswanson/node_deployer v0.1.24
 )
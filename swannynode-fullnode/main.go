@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/pulumi/pulumi-command/sdk/go/command/remote"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
 
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
@@ -98,31 +99,90 @@ func main() {
 			return err
 		}
 
-		_, err = consensusClient.NewConsensusClientComponent(ctx, "consensusClient", &consensusClient.ConsensusClientComponentArgs{
+		// Generate the engine api jwt shared by reth and lighthouse, changing
+		// jwtRotation generates a new one
+		jwt, err := random.NewRandomId(ctx, "execution-jwt", &random.RandomIdArgs{
+			ByteLength: pulumi.Int(32),
+			Keepers: pulumi.Map{
+				"rotation": pulumi.String(cfg.Get("jwtRotation")),
+			},
+		}, pulumi.AdditionalSecretOutputs([]string{"hex", "b64Std", "b64Url", "dec"}))
+		if err != nil {
+			ctx.Log.Error("Error generating execution jwt", nil)
+			return err
+		}
+		jwtHex := pulumi.ToSecret(jwt.Hex).(pulumi.StringOutput)
+
+		// Write the jwt where both start scripts read it, readable by the eth group only.
+		// A rotation deletes the old file before writing the new one, the old
+		// command's delete would otherwise remove the new jwt at the end of the update.
+		writeJwt, err := remote.NewCommand(ctx, "writeExecutionJwt", &remote.CommandArgs{
+			Connection: connection,
+			Create:     pulumi.String("umask 027 && cat > /data/shared/jwt.hex && chgrp eth /data/shared/jwt.hex"),
+			Delete:     pulumi.String("rm -f /data/shared/jwt.hex"),
+			Stdin:      jwtHex,
+			Triggers:   pulumi.Array{jwtHex},
+		}, pulumi.DependsOn([]pulumi.Resource{dataDir, ethGroup}), pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			ctx.Log.Error("Error writing execution jwt", nil)
+			return err
+		}
+
+		consensus, err := consensusClient.NewConsensusClientComponent(ctx, "consensusClient", &consensusClient.ConsensusClientComponentArgs{
 			Client:         "lighthouse",
 			Network:        "mainnet",
 			DeploymentType: "source",
 			DataDir:        "/data/mainnet/lighthouse",
 			Connection:     connection,
-		}, pulumi.DependsOn([]pulumi.Resource{groupAddLighthouse, dataDir, installDeps}))
+		}, pulumi.DependsOn([]pulumi.Resource{groupAddLighthouse, dataDir, installDeps, writeJwt}))
 		if err != nil {
 			ctx.Log.Error("Error creating consensus client", nil)
 			return err
 		}
 
 		// Create execution client
-		_, err = executionClient.NewExecutionClientComponent(ctx, "executionClient", &executionClient.ExecutionClientComponentArgs{
+		execution, err := executionClient.NewExecutionClientComponent(ctx, "executionClient", &executionClient.ExecutionClientComponentArgs{
 			Client:         "reth",
 			Network:        "mainnet",
 			DeploymentType: "source",
 			DataDir:        "/data/mainnet/reth",
 			Connection:     connection,
-		}, pulumi.DependsOn([]pulumi.Resource{groupAddReth, dataDir, installDeps}))
+		}, pulumi.DependsOn([]pulumi.Resource{groupAddReth, dataDir, installDeps, writeJwt}))
 		if err != nil {
 			ctx.Log.Error("Error creating execution client", nil)
 			return err
 		}
 
+		// Restart both clients in one transaction whenever the jwt changes, so
+		// the engine api never sees one client on the old secret
+		_, err = remote.NewCommand(ctx, "restartOnJwtRotation", &remote.CommandArgs{
+			Connection: connection,
+			Create:     pulumi.String("systemctl try-restart reth.mainnet lighthouse.mainnet"),
+			Triggers:   pulumi.Array{jwtHex},
+		}, pulumi.DependsOn([]pulumi.Resource{writeJwt, consensus, execution}))
+		if err != nil {
+			ctx.Log.Error("Error restarting clients after jwt rotation", nil)
+			return err
+		}
+
+		// optional move of a validator's slashing protection history to or from holesky-validator
+		var slashingProtection *SlashingProtectionConfig
+		if err := cfg.GetObject("slashingProtection", &slashingProtection); err != nil {
+			return err
+		}
+		if slashingProtection != nil {
+			if err := slashingProtection.validate(); err != nil {
+				return err
+			}
+			err = newSlashingProtectionCommand(ctx, connection, slashingProtection, pulumi.DependsOn([]pulumi.Resource{groupAddLighthouse, dataDir, consensus}))
+			if err != nil {
+				ctx.Log.Error("Error moving slashing protection history", nil)
+				return err
+			}
+		}
+
+		ctx.Export("executionJwt", jwtHex)
+
 		return nil
 	})
 }
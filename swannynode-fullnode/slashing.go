@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-command/sdk/go/command/remote"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// SlashingProtectionConfig moves the eip-3076 slashing protection history of
+// a lighthouse validator on the host through the bucket of the
+// holesky-validator stack, encrypted with its bucket key. Export stops the
+// validator service and uploads its history, import downloads a history
+// exported by the holesky-validator stack and imports it before the service
+// starts again. The host's instance role needs s3 and kms access to both, and
+// to be one of the vault principals of the holesky-validator stack.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-fullnode:slashingProtection:
+//	  validatorStack: organization/holesky-validator/holesky
+//	  network: holesky
+//	  dataDir: /data/holesky/lighthouse-validator
+//	  service: lighthouse-validator.holesky
+//	  export: interchange/2026-10-17-fullnode.json
+type SlashingProtectionConfig struct {
+	ValidatorStack string `json:"validatorStack"`
+	Network        string `json:"network"`
+	// DataDir is the --datadir of the validator client
+	DataDir string `json:"dataDir"`
+	// Service is the systemd unit of the validator client, stopped around the move
+	Service string `json:"service"`
+	Export  string `json:"export"`
+	Import  string `json:"import"`
+}
+
+const interchangeFile = "/data/shared/interchange.json"
+
+func (c *SlashingProtectionConfig) validate() error {
+	if c.ValidatorStack == "" || c.Network == "" || c.DataDir == "" {
+		return fmt.Errorf("slashingProtection validatorStack, network and dataDir are required")
+	}
+	if (c.Export == "") == (c.Import == "") {
+		return fmt.Errorf("slashingProtection needs either an export or an import key")
+	}
+	for _, key := range []string{c.Export, c.Import} {
+		if key != "" && (!strings.HasPrefix(key, "interchange/") || !strings.HasSuffix(key, ".json") || strings.ContainsAny(key, "' ")) {
+			return fmt.Errorf("slashingProtection key %q must be interchange/<name>.json", key)
+		}
+	}
+	return nil
+}
+
+// newSlashingProtectionCommand exports or imports the slashing protection
+// history of the validator on the host, rerun whenever the key changes
+func newSlashingProtectionCommand(ctx *pulumi.Context, connection *remote.ConnectionArgs, slashing *SlashingProtectionConfig, opts ...pulumi.ResourceOption) error {
+	validator, err := pulumi.NewStackReference(ctx, slashing.ValidatorStack, nil)
+	if err != nil {
+		return err
+	}
+	bucket := validator.GetStringOutput(pulumi.String("bucketName"))
+	bucketKey := validator.GetStringOutput(pulumi.String("bucketKeyArn"))
+
+	disable, stop, start := "true", "true", "true"
+	if slashing.Service != "" {
+		disable = fmt.Sprintf("systemctl disable --now '%s'", slashing.Service)
+		stop = fmt.Sprintf("systemctl stop '%s'", slashing.Service)
+		start = fmt.Sprintf("systemctl start '%s'", slashing.Service)
+	}
+	lighthouse := fmt.Sprintf("/data/bin/lighthouse account validator slashing-protection --network '%s' --datadir '%s'", slashing.Network, slashing.DataDir)
+
+	var name, key string
+	var script pulumi.StringOutput
+	if slashing.Export != "" {
+		// the validator stays stopped, the history is only complete if it never signs again here
+		name, key = "exportSlashingProtection", slashing.Export
+		script = pulumi.Sprintf(`set -eu
+%s
+%s export %s
+aws s3 cp %s 's3://%s/%s' --sse aws:kms --sse-kms-key-id '%s' --checksum-algorithm SHA256
+rm -f %s`, disable, lighthouse, interchangeFile, interchangeFile, bucket, key, bucketKey, interchangeFile)
+	} else {
+		name, key = "importSlashingProtection", slashing.Import
+		script = pulumi.Sprintf(`set -eu
+%s
+aws s3 cp 's3://%s/%s' %s
+%s import %s
+chown -R lighthouse:lighthouse '%s'
+rm -f %s
+%s`, stop, bucket, key, interchangeFile, lighthouse, interchangeFile, slashing.DataDir, interchangeFile, start)
+	}
+
+	_, err = remote.NewCommand(ctx, name, &remote.CommandArgs{
+		Connection: connection,
+		Create:     script,
+		Triggers:   pulumi.Array{pulumi.String(key)},
+	}, opts...)
+	return err
+}
@@ -0,0 +1,99 @@
+package consensusClient
+
+import "fmt"
+
+// Client describes how to run a consensus client in a kubernetes pod: the
+// image to pull, where its data lives and how the common Spec maps onto its
+// command line flags.
+type Client interface {
+	// Name is the client name, also used for kubernetes resource names and labels
+	Name() string
+	// Image is the default container image for the client
+	Image() string
+	// DataDir is both the mount path of the data persistent volume and the
+	// directory the client keeps its database in
+	DataDir(network string) string
+	// ConfigFile is the name of the client's config file inside ConfigDir,
+	// or an empty string when the client is configured with flags only
+	ConfigFile() string
+	// InitCommand returns a command to run in an init container before the
+	// client starts, or nil when the client needs no initialisation
+	InitCommand(spec Spec) []string
+	// Command returns the container command that starts the beacon node
+	Command(spec Spec) []string
+	// P2PPorts returns the peer to peer ports the client listens on
+	P2PPorts(spec Spec) []Port
+}
+
+// Spec is the client-agnostic description of a beacon node that every
+// implementation translates into its own flags.
+type Spec struct {
+	Network           string
+	CheckpointSyncUrl string
+	EngineEndpoint    string
+	JwtPath           string
+	HttpPort          int
+	MetricsPort       int
+	P2PPort           int
+	QuicPort          int
+	// ExternalIp is the public address the client advertises in its enr,
+	// left to the client's own discovery when empty
+	ExternalIp string
+	// BuilderEndpoint is the mev-boost url payloads are requested from,
+	// blocks are only built locally when empty
+	BuilderEndpoint string
+	// BuilderFallbackConsecutive and BuilderFallbackEpoch are the missed
+	// slots, in a row and in the last epoch, after which the client stops
+	// asking the builder and builds locally until the chain recovers.
+	// Clients without such settings keep their own circuit breaker.
+	BuilderFallbackConsecutive int
+	BuilderFallbackEpoch       int
+}
+
+// Port is a single container port exposed by a client
+type Port struct {
+	Name     string
+	Port     int
+	Protocol string
+}
+
+const (
+	Lighthouse = "lighthouse"
+	Prysm      = "prysm"
+	Teku       = "teku"
+	Nimbus     = "nimbus"
+	Lodestar   = "lodestar"
+)
+
+// JwtPath is where the execution jwt secret is mounted in every consensus client
+const JwtPath = "/secrets/jwt.hex"
+
+var clients = map[string]Client{
+	Lighthouse: lighthouse{},
+	Prysm:      prysm{},
+	Teku:       teku{},
+	Nimbus:     nimbus{},
+	Lodestar:   lodestar{},
+}
+
+// New returns the consensus client registered under name
+func New(name string) (Client, error) {
+	client, ok := clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported consensus client %q, expected one of lighthouse, prysm, teku, nimbus or lodestar", name)
+	}
+	return client, nil
+}
+
+// ConfigDir is the directory the client's config map is mounted at
+func ConfigDir(client Client) string {
+	return fmt.Sprintf("/etc/%s", client.Name())
+}
+
+// tcpAndUdp returns the same port number as a tcp and a udp port
+func tcpAndUdp(port int) []Port {
+	return []Port{
+		{Name: "p2p-tcp", Port: port, Protocol: "TCP"},
+		{Name: "p2p-udp", Port: port, Protocol: "UDP"},
+	}
+}
@@ -0,0 +1,53 @@
+package consensusClient
+
+import (
+	"fmt"
+	"path"
+)
+
+type lighthouse struct{}
+
+func (lighthouse) Name() string  { return Lighthouse }
+func (lighthouse) Image() string { return "sigp/lighthouse:latest" }
+func (lighthouse) DataDir(network string) string {
+	return fmt.Sprintf("/root/.local/share/lighthouse/%s", network)
+}
+func (lighthouse) ConfigFile() string        { return "lighthouse.toml" }
+func (lighthouse) InitCommand(Spec) []string { return nil }
+
+func (c lighthouse) Command(spec Spec) []string {
+	command := []string{
+		"lighthouse",
+		"bn",
+		"--config-file", path.Join(ConfigDir(c), c.ConfigFile()),
+		"--datadir", c.DataDir(spec.Network),
+		"--network", spec.Network,
+		"--checkpoint-sync-url", spec.CheckpointSyncUrl,
+		"--execution-jwt", spec.JwtPath,
+		"--port", fmt.Sprint(spec.P2PPort),
+		"--quic-port", fmt.Sprint(spec.QuicPort),
+		"--http",
+		"--http-address", "0.0.0.0",
+		"--http-port", fmt.Sprint(spec.HttpPort),
+		"--execution-endpoint", spec.EngineEndpoint,
+		"--disable-deposit-contract-sync",
+		"--metrics",
+		"--metrics-address", "0.0.0.0",
+		"--metrics-port", fmt.Sprint(spec.MetricsPort),
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--enr-address", spec.ExternalIp)
+	}
+	if spec.BuilderEndpoint != "" {
+		command = append(command,
+			"--builder", spec.BuilderEndpoint,
+			"--builder-fallback-skips", fmt.Sprint(spec.BuilderFallbackConsecutive),
+			"--builder-fallback-skips-per-epoch", fmt.Sprint(spec.BuilderFallbackEpoch),
+		)
+	}
+	return command
+}
+
+func (lighthouse) P2PPorts(spec Spec) []Port {
+	return append(tcpAndUdp(spec.P2PPort), Port{Name: "quic", Port: spec.QuicPort, Protocol: "UDP"})
+}
@@ -0,0 +1,193 @@
+package consensusClient
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LighthouseConfig models the beacon node settings the node program manages.
+// It is rendered to the file lighthouse reads through `--config-file`, where
+// every key is a `lighthouse bn` flag name.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:lighthouseConfig:
+//	  targetPeers: 100
+//	  slasher:
+//	    enabled: true
+//	    historyLength: 4096
+//	  validatorMonitor:
+//	    auto: true
+type LighthouseConfig struct {
+	TargetPeers int `json:"targetPeers"`
+	// CheckpointSyncUrl overrides the network's default checkpoint sync endpoint
+	CheckpointSyncUrl        string                           `json:"checkpointSyncUrl"`
+	CheckpointSyncUrlTimeout int                              `json:"checkpointSyncUrlTimeout"`
+	BlobPruning              LighthouseBlobPruningConfig      `json:"blobPruning"`
+	Slasher                  LighthouseSlasherConfig          `json:"slasher"`
+	Builder                  LighthouseBuilderConfig          `json:"builder"`
+	ValidatorMonitor         LighthouseValidatorMonitorConfig `json:"validatorMonitor"`
+}
+
+type LighthouseBlobPruningConfig struct {
+	// Disabled keeps every blob sidecar, lighthouse prunes them by default
+	Disabled           bool `json:"disabled"`
+	MarginEpochs       int  `json:"marginEpochs"`
+	EpochsPerBlobPrune int  `json:"epochsPerBlobPrune"`
+}
+
+type LighthouseSlasherConfig struct {
+	Enabled       bool `json:"enabled"`
+	HistoryLength int  `json:"historyLength"`
+	// MaxDbSize is the maximum size of the slasher database in GB
+	MaxDbSize int    `json:"maxDbSize"`
+	Backend   string `json:"backend"`
+}
+
+type LighthouseBuilderConfig struct {
+	// Url is the builder api endpoint, e.g. a mev-boost service
+	Url                             string `json:"url"`
+	FallbackSkips                   int    `json:"fallbackSkips"`
+	FallbackSkipsPerEpoch           int    `json:"fallbackSkipsPerEpoch"`
+	FallbackEpochsSinceFinalization int    `json:"fallbackEpochsSinceFinalization"`
+	FallbackDisableChecks           bool   `json:"fallbackDisableChecks"`
+}
+
+type LighthouseValidatorMonitorConfig struct {
+	Auto                        bool     `json:"auto"`
+	Pubkeys                     []string `json:"pubkeys"`
+	IndividualTrackingThreshold int      `json:"individualTrackingThreshold"`
+}
+
+// validatorPubkey matches a 0x prefixed, 48 byte BLS public key
+var validatorPubkey = regexp.MustCompile(`^0x[0-9a-fA-F]{96}$`)
+
+var slasherBackends = map[string]bool{"lmdb": true, "mdbx": true, "redb": true}
+
+func validateUrl(name, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%s %q must be an http or https url", name, value)
+	}
+	return nil
+}
+
+// Validate checks the config for combinations lighthouse would reject or
+// that would silently not do what was asked for
+func (c *LighthouseConfig) Validate() error {
+	if c.TargetPeers < 0 {
+		return fmt.Errorf("targetPeers cannot be negative")
+	}
+	if c.CheckpointSyncUrl != "" {
+		if err := validateUrl("checkpointSyncUrl", c.CheckpointSyncUrl); err != nil {
+			return err
+		}
+	}
+	if c.CheckpointSyncUrlTimeout < 0 {
+		return fmt.Errorf("checkpointSyncUrlTimeout cannot be negative")
+	}
+
+	if c.BlobPruning.Disabled && (c.BlobPruning.MarginEpochs > 0 || c.BlobPruning.EpochsPerBlobPrune > 0) {
+		return fmt.Errorf("blobPruning margin and frequency are set but blob pruning is disabled")
+	}
+
+	if !c.Slasher.Enabled && (c.Slasher.HistoryLength > 0 || c.Slasher.MaxDbSize > 0 || c.Slasher.Backend != "") {
+		return fmt.Errorf("slasher settings are set but the slasher is not enabled")
+	}
+	if c.Slasher.HistoryLength < 0 || c.Slasher.MaxDbSize < 0 {
+		return fmt.Errorf("slasher history length and max db size cannot be negative")
+	}
+	if c.Slasher.Backend != "" && !slasherBackends[c.Slasher.Backend] {
+		return fmt.Errorf("unknown slasher backend %q, expected lmdb, mdbx or redb", c.Slasher.Backend)
+	}
+
+	builder := c.Builder
+	if builder.Url != "" {
+		if err := validateUrl("builder url", builder.Url); err != nil {
+			return err
+		}
+	} else if builder.FallbackSkips > 0 || builder.FallbackSkipsPerEpoch > 0 ||
+		builder.FallbackEpochsSinceFinalization > 0 || builder.FallbackDisableChecks {
+		return fmt.Errorf("builder fallback settings are set without a builder url")
+	}
+
+	for _, pubkey := range c.ValidatorMonitor.Pubkeys {
+		if !validatorPubkey.MatchString(pubkey) {
+			return fmt.Errorf("validator monitor pubkey %q is not a 0x prefixed 48 byte public key", pubkey)
+		}
+	}
+	if c.ValidatorMonitor.IndividualTrackingThreshold > 0 && !c.ValidatorMonitor.Auto && len(c.ValidatorMonitor.Pubkeys) == 0 {
+		return fmt.Errorf("validator monitor tracking threshold is set but no validators are monitored")
+	}
+	return nil
+}
+
+// flags maps the config onto lighthouse bn flag names. Values lighthouse
+// expects as flag arguments are written as strings, switches as booleans.
+func (c *LighthouseConfig) flags() map[string]interface{} {
+	flags := map[string]interface{}{}
+	setInt := func(flag string, value int) {
+		if value > 0 {
+			flags[flag] = fmt.Sprint(value)
+		}
+	}
+
+	setInt("target-peers", c.TargetPeers)
+	setInt("checkpoint-sync-url-timeout", c.CheckpointSyncUrlTimeout)
+
+	if c.BlobPruning.Disabled {
+		flags["prune-blobs"] = "false"
+	}
+	setInt("blob-prune-margin-epochs", c.BlobPruning.MarginEpochs)
+	setInt("epochs-per-blob-prune", c.BlobPruning.EpochsPerBlobPrune)
+
+	if c.Slasher.Enabled {
+		flags["slasher"] = true
+		setInt("slasher-history-length", c.Slasher.HistoryLength)
+		setInt("slasher-max-db-size", c.Slasher.MaxDbSize)
+		if c.Slasher.Backend != "" {
+			flags["slasher-backend"] = c.Slasher.Backend
+		}
+	}
+
+	if c.Builder.Url != "" {
+		flags["builder"] = c.Builder.Url
+		setInt("builder-fallback-skips", c.Builder.FallbackSkips)
+		setInt("builder-fallback-skips-per-epoch", c.Builder.FallbackSkipsPerEpoch)
+		setInt("builder-fallback-epochs-since-finalization", c.Builder.FallbackEpochsSinceFinalization)
+		if c.Builder.FallbackDisableChecks {
+			flags["builder-fallback-disable-checks"] = true
+		}
+	}
+
+	if c.ValidatorMonitor.Auto {
+		flags["validator-monitor-auto"] = true
+	}
+	if len(c.ValidatorMonitor.Pubkeys) > 0 {
+		flags["validator-monitor-pubkeys"] = strings.Join(c.ValidatorMonitor.Pubkeys, ",")
+	}
+	setInt("validator-monitor-individual-tracking-threshold", c.ValidatorMonitor.IndividualTrackingThreshold)
+	return flags
+}
+
+// Render validates the config and encodes it as the toml file passed to
+// `--config-file`. The checkpoint sync url is not part of the file, it is
+// passed on the command line through the client Spec instead.
+func (c *LighthouseConfig) Render() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(c.flags()); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
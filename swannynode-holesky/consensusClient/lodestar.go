@@ -0,0 +1,49 @@
+package consensusClient
+
+import "fmt"
+
+type lodestar struct{}
+
+func (lodestar) Name() string                  { return Lodestar }
+func (lodestar) Image() string                 { return "chainsafe/lodestar:latest" }
+func (lodestar) DataDir(network string) string { return fmt.Sprintf("/data/lodestar/%s", network) }
+func (lodestar) ConfigFile() string            { return "" }
+func (lodestar) InitCommand(Spec) []string     { return nil }
+
+func (c lodestar) Command(spec Spec) []string {
+	command := []string{
+		"node",
+		"./packages/cli/bin/lodestar",
+		"beacon",
+		"--network", spec.Network,
+		"--dataDir", c.DataDir(spec.Network),
+		"--checkpointSyncUrl", spec.CheckpointSyncUrl,
+		"--execution.urls", spec.EngineEndpoint,
+		"--jwt-secret", spec.JwtPath,
+		"--rest",
+		"--rest.address", "0.0.0.0",
+		"--rest.port", fmt.Sprint(spec.HttpPort),
+		"--metrics",
+		"--metrics.address", "0.0.0.0",
+		"--metrics.port", fmt.Sprint(spec.MetricsPort),
+		"--port", fmt.Sprint(spec.P2PPort),
+		"--discoveryPort", fmt.Sprint(spec.P2PPort),
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--enr.ip", spec.ExternalIp)
+	}
+	if spec.BuilderEndpoint != "" {
+		// lodestar counts the missed slots of a sliding window of one epoch
+		command = append(command,
+			"--builder",
+			"--builder.urls", spec.BuilderEndpoint,
+			"--builder.faultInspectionWindow", "32",
+			"--builder.allowedFaults", fmt.Sprint(spec.BuilderFallbackEpoch),
+		)
+	}
+	return command
+}
+
+func (lodestar) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
@@ -0,0 +1,55 @@
+package consensusClient
+
+import "fmt"
+
+type nimbus struct{}
+
+const nimbusBinary = "/home/user/nimbus-eth2/build/nimbus_beacon_node"
+
+func (nimbus) Name() string                  { return Nimbus }
+func (nimbus) Image() string                 { return "statusim/nimbus-eth2:multiarch-latest" }
+func (nimbus) DataDir(network string) string { return fmt.Sprintf("/data/nimbus/%s", network) }
+func (nimbus) ConfigFile() string            { return "" }
+
+// InitCommand checkpoint syncs an empty database, nimbus does this as a
+// separate trustedNodeSync step rather than at beacon node start up
+func (c nimbus) InitCommand(spec Spec) []string {
+	return []string{
+		nimbusBinary,
+		"trustedNodeSync",
+		fmt.Sprintf("--network=%s", spec.Network),
+		fmt.Sprintf("--data-dir=%s", c.DataDir(spec.Network)),
+		fmt.Sprintf("--trusted-node-url=%s", spec.CheckpointSyncUrl),
+		"--backfill=false",
+	}
+}
+
+func (c nimbus) Command(spec Spec) []string {
+	command := []string{
+		nimbusBinary,
+		"--non-interactive",
+		fmt.Sprintf("--network=%s", spec.Network),
+		fmt.Sprintf("--data-dir=%s", c.DataDir(spec.Network)),
+		fmt.Sprintf("--el=%s", spec.EngineEndpoint),
+		fmt.Sprintf("--jwt-secret=%s", spec.JwtPath),
+		"--rest",
+		"--rest-address=0.0.0.0",
+		fmt.Sprintf("--rest-port=%d", spec.HttpPort),
+		"--metrics",
+		"--metrics-address=0.0.0.0",
+		fmt.Sprintf("--metrics-port=%d", spec.MetricsPort),
+		fmt.Sprintf("--tcp-port=%d", spec.P2PPort),
+		fmt.Sprintf("--udp-port=%d", spec.P2PPort),
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, fmt.Sprintf("--nat=extip:%s", spec.ExternalIp))
+	}
+	if spec.BuilderEndpoint != "" {
+		command = append(command, "--payload-builder=true", fmt.Sprintf("--payload-builder-url=%s", spec.BuilderEndpoint))
+	}
+	return command
+}
+
+func (nimbus) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
@@ -0,0 +1,46 @@
+package consensusClient
+
+import "fmt"
+
+type prysm struct{}
+
+func (prysm) Name() string                  { return Prysm }
+func (prysm) Image() string                 { return "gcr.io/prysmaticlabs/prysm/beacon-chain:stable" }
+func (prysm) DataDir(network string) string { return fmt.Sprintf("/data/prysm/%s", network) }
+func (prysm) ConfigFile() string            { return "" }
+func (prysm) InitCommand(Spec) []string     { return nil }
+
+func (c prysm) Command(spec Spec) []string {
+	command := []string{
+		"/app/cmd/beacon-chain/beacon-chain",
+		"--accept-terms-of-use",
+		fmt.Sprintf("--%s", spec.Network),
+		"--datadir", c.DataDir(spec.Network),
+		"--checkpoint-sync-url", spec.CheckpointSyncUrl,
+		"--genesis-beacon-api-url", spec.CheckpointSyncUrl,
+		"--execution-endpoint", spec.EngineEndpoint,
+		"--jwt-secret", spec.JwtPath,
+		"--http-host", "0.0.0.0",
+		"--http-port", fmt.Sprint(spec.HttpPort),
+		"--monitoring-host", "0.0.0.0",
+		"--monitoring-port", fmt.Sprint(spec.MetricsPort),
+		"--p2p-tcp-port", fmt.Sprint(spec.P2PPort),
+		"--p2p-udp-port", fmt.Sprint(spec.P2PPort),
+		"--p2p-quic-port", fmt.Sprint(spec.QuicPort),
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--p2p-host-ip", spec.ExternalIp)
+	}
+	if spec.BuilderEndpoint != "" {
+		command = append(command,
+			"--http-mev-relay", spec.BuilderEndpoint,
+			"--max-builder-consecutive-missed-slots", fmt.Sprint(spec.BuilderFallbackConsecutive),
+			"--max-builder-epoch-missed-slots", fmt.Sprint(spec.BuilderFallbackEpoch),
+		)
+	}
+	return command
+}
+
+func (prysm) P2PPorts(spec Spec) []Port {
+	return append(tcpAndUdp(spec.P2PPort), Port{Name: "quic", Port: spec.QuicPort, Protocol: "UDP"})
+}
@@ -0,0 +1,42 @@
+package consensusClient
+
+import "fmt"
+
+type teku struct{}
+
+func (teku) Name() string                  { return Teku }
+func (teku) Image() string                 { return "consensys/teku:latest" }
+func (teku) DataDir(network string) string { return fmt.Sprintf("/data/teku/%s", network) }
+func (teku) ConfigFile() string            { return "" }
+func (teku) InitCommand(Spec) []string     { return nil }
+
+func (c teku) Command(spec Spec) []string {
+	command := []string{
+		"/opt/teku/bin/teku",
+		fmt.Sprintf("--network=%s", spec.Network),
+		fmt.Sprintf("--data-path=%s", c.DataDir(spec.Network)),
+		fmt.Sprintf("--checkpoint-sync-url=%s", spec.CheckpointSyncUrl),
+		fmt.Sprintf("--ee-endpoint=%s", spec.EngineEndpoint),
+		fmt.Sprintf("--ee-jwt-secret-file=%s", spec.JwtPath),
+		"--rest-api-enabled",
+		"--rest-api-interface=0.0.0.0",
+		"--rest-api-host-allowlist=*",
+		fmt.Sprintf("--rest-api-port=%d", spec.HttpPort),
+		"--metrics-enabled",
+		"--metrics-interface=0.0.0.0",
+		"--metrics-host-allowlist=*",
+		fmt.Sprintf("--metrics-port=%d", spec.MetricsPort),
+		fmt.Sprintf("--p2p-port=%d", spec.P2PPort),
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, fmt.Sprintf("--p2p-advertised-ip=%s", spec.ExternalIp))
+	}
+	if spec.BuilderEndpoint != "" {
+		command = append(command, fmt.Sprintf("--builder-endpoint=%s", spec.BuilderEndpoint))
+	}
+	return command
+}
+
+func (teku) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
@@ -0,0 +1,303 @@
+package ethereumNode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// VolumeAutoscalerConfig deploys the pvc-autoscaler, growing the data
+// volumes of the node by Step whenever their filesystem is more than
+// ThresholdPercent full, up to the ceiling of each client. A client without
+// a ceiling keeps its volume size. The storage class has to allow volume
+// expansion, and ebs only expands a volume once every six hours.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:volumeAutoscaler:
+//	  image: <registry>/pvc-autoscaler:v0.1.0
+//	  thresholdPercent: 80
+//	  step: 50Gi
+//	  executionCeiling: 2Ti
+//	  consensusCeiling: 500Gi
+type VolumeAutoscalerConfig struct {
+	// Image is built from the pvc-autoscaler directory of this repo
+	Image            string `json:"image"`
+	ThresholdPercent int    `json:"thresholdPercent"`
+	// Step is how much a volume grows at a time, a kubernetes quantity
+	Step string `json:"step"`
+	// ExecutionCeiling and ConsensusCeiling cap the volumes of each client
+	ExecutionCeiling string `json:"executionCeiling"`
+	ConsensusCeiling string `json:"consensusCeiling"`
+	// IntervalSeconds is how often volume usage is checked
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+const (
+	defaultAutoscalerThresholdPercent = 80
+	defaultAutoscalerStep             = "50Gi"
+	defaultAutoscalerInterval         = 60
+	autoscalerMetricsPort             = 9103
+	autoscalerConfigDir               = "/etc/pvc-autoscaler"
+	autoscalerConfigFile              = "autoscaler.json"
+)
+
+func (c *VolumeAutoscalerConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("volumeAutoscaler image is required")
+	}
+	if c.ExecutionCeiling == "" && c.ConsensusCeiling == "" {
+		return fmt.Errorf("volumeAutoscaler needs an executionCeiling or a consensusCeiling")
+	}
+	if c.ThresholdPercent == 0 {
+		c.ThresholdPercent = defaultAutoscalerThresholdPercent
+	}
+	if c.ThresholdPercent < 1 || c.ThresholdPercent > 99 {
+		return fmt.Errorf("volumeAutoscaler thresholdPercent must be between 1 and 99")
+	}
+	if c.Step == "" {
+		c.Step = defaultAutoscalerStep
+	}
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = defaultAutoscalerInterval
+	}
+	if c.IntervalSeconds < 0 {
+		return fmt.Errorf("volumeAutoscaler intervalSeconds cannot be negative")
+	}
+	return nil
+}
+
+// ceiling returns the ceiling of the volumes of kind, empty when they are not expanded
+func (c *VolumeAutoscalerConfig) ceiling(kind string) string {
+	if kind == consensusKind {
+		return c.ConsensusCeiling
+	}
+	return c.ExecutionCeiling
+}
+
+// autoscalerFile is the config file the pvc-autoscaler reads
+type autoscalerFile struct {
+	Namespace       string             `json:"namespace"`
+	IntervalSeconds int                `json:"intervalSeconds"`
+	MetricsListen   string             `json:"metricsListen"`
+	Volumes         []autoscalerVolume `json:"volumes"`
+}
+
+type autoscalerVolume struct {
+	Claim            string `json:"claim"`
+	ThresholdPercent int    `json:"thresholdPercent"`
+	Step             string `json:"step"`
+	Ceiling          string `json:"ceiling"`
+}
+
+// render returns the autoscaler config covering the data volume of every replica of sets
+func (c *VolumeAutoscalerConfig) render(args *EthereumNodeComponentArgs, sets []nodeSet) (string, error) {
+	file := autoscalerFile{
+		Namespace:       args.Namespace,
+		IntervalSeconds: c.IntervalSeconds,
+		MetricsListen:   fmt.Sprintf(":%d", autoscalerMetricsPort),
+	}
+	for _, set := range sets {
+		for ordinal := 0; ordinal <= lastOrdinal(args); ordinal++ {
+			for _, part := range set.parts {
+				if c.ceiling(part.kind) == "" {
+					continue
+				}
+				file.Volumes = append(file.Volumes, autoscalerVolume{
+					Claim:            claimName(part, set, ordinal),
+					ThresholdPercent: c.ThresholdPercent,
+					Step:             c.Step,
+					Ceiling:          c.ceiling(part.kind),
+				})
+			}
+		}
+	}
+	rendered, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// newVolumeAutoscaler deploys the pvc-autoscaler for the data volumes of sets
+func newVolumeAutoscaler(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, sets []nodeSet) error {
+	autoscaler := args.VolumeAutoscaler
+	name := fmt.Sprintf("%s-pvc-autoscaler", pairName(args))
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(name)}
+
+	config, err := autoscaler.render(args, sets)
+	if err != nil {
+		return err
+	}
+
+	// Create a ConfigMap with the claims to watch and their limits
+	configMap, err := corev1.NewConfigMap(ctx, fmt.Sprintf("%s-config", name), &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Data: pulumi.StringMap{
+			autoscalerConfigFile: pulumi.String(config),
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+
+	// Create a service account allowed to expand the claims and record events on them
+	serviceAccount, err := newNodeServiceAccount(ctx, component, args, name, rbacv1.PolicyRuleArray{
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("persistentvolumeclaims")},
+			Verbs:     pulumi.ToStringArray([]string{"get", "patch"}),
+		},
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("events")},
+			Verbs:     pulumi.StringArray{pulumi.String("create")},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The volume stats come from the kubelets, which are cluster scoped. The
+	// autoscaler lists the nodes for their addresses and calls each kubelet
+	// directly, nodes/stats only lets it read the kubelet stats endpoints,
+	// where nodes/proxy would open the whole kubelet api of every node.
+	clusterName := fmt.Sprintf("%s-%s", args.Namespace, name)
+	clusterRole, err := rbacv1.NewClusterRole(ctx, clusterName, &rbacv1.ClusterRoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(clusterName),
+		},
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.StringArray{pulumi.String("nodes")},
+				Verbs:     pulumi.StringArray{pulumi.String("list")},
+			},
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.StringArray{pulumi.String("nodes/stats")},
+				Verbs:     pulumi.StringArray{pulumi.String("get")},
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+	_, err = rbacv1.NewClusterRoleBinding(ctx, clusterName, &rbacv1.ClusterRoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(clusterName),
+		},
+		RoleRef: &rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+
+	// Create a deployment for the autoscaler, one replica so claims are only expanded once
+	_, err = appsv1.NewDeployment(ctx, name, &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(1),
+			Strategy: &appsv1.DeploymentStrategyArgs{
+				Type: pulumi.String("Recreate"),
+			},
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+					// roll the pod when the claims or limits change
+					Annotations: pulumi.StringMap{
+						"swannynode/config-hash": pulumi.String(configHash(config)),
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount,
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String(name),
+							Image: pulumi.String(autoscaler.Image),
+							Args: pulumi.StringArray{
+								pulumi.String("-config"),
+								pulumi.Sprintf("%s/%s", autoscalerConfigDir, autoscalerConfigFile),
+							},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("metrics"),
+									ContainerPort: pulumi.Int(autoscalerMetricsPort),
+								},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("config"),
+									MountPath: pulumi.String(autoscalerConfigDir),
+								},
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("10m"),
+									"memory": pulumi.String("32Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("128Mi"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("config"),
+							ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+								Name: configMap.Metadata.Name(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+
+	// Create a service for prometheus to scrape the autoscaler metrics
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-metrics", name), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: labels,
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(autoscalerMetricsPort),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-metrics", name),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	return err
+}
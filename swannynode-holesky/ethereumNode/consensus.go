@@ -0,0 +1,215 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"swannynode-mainnet/consensusClient"
+)
+
+// consensusResources are the pieces of the consensus client the rest of the node is wired to
+type consensusResources struct {
+	client       consensusClient.Client
+	labels       pulumi.StringMap
+	beaconApiUrl pulumi.StringOutput
+	// pod holds the containers and volumes of the client, run by a statefulset of their own or next to the execution client
+	pod *podParts
+}
+
+// newConsensusClient creates the config and services for the configured consensus client and
+// the pod parts its statefulset is built from
+func newConsensusClient(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, engineEndpoint string) (*consensusResources, error) {
+	cl, err := consensusClient.New(args.ConsensusClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := args.Ports
+	network := string(args.Network)
+	namespace := pulumi.String(args.Namespace)
+	clName := cl.Name()
+	clLabels := nodeLabels(args, clName)
+	clDataVolumeName := fmt.Sprintf("%s-data", clName)
+	clSpec := consensusClient.Spec{
+		Network:           network,
+		CheckpointSyncUrl: args.CheckpointSyncUrl,
+		EngineEndpoint:    engineEndpoint,
+		JwtPath:           consensusClient.JwtPath,
+		HttpPort:          ports.ConsensusHttp,
+		MetricsPort:       ports.ConsensusMetrics,
+		P2PPort:           ports.ConsensusP2P,
+		QuicPort:          ports.ConsensusQuic,
+	}
+	if args.MevBoost != nil {
+		clSpec.BuilderEndpoint = mevBoostEndpoint(args)
+		clSpec.BuilderFallbackConsecutive = args.MevBoost.FallbackConsecutiveMissed
+		clSpec.BuilderFallbackEpoch = args.MevBoost.FallbackEpochMissed
+	}
+	var clExternalIpMounts corev1.VolumeMountArray
+	clSpec.ExternalIp, clExternalIpMounts = externalIp(args)
+
+	pod := &podParts{
+		client:            clName,
+		kind:              consensusKind,
+		dataVolume:        clDataVolumeName,
+		dataClaimResource: clDataVolumeName,
+		storageSize:       args.ConsensusStorageSize,
+	}
+
+	clVolumeMounts := corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String(clDataVolumeName),
+			MountPath: pulumi.String(cl.DataDir(network)),
+		},
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String("execution-jwt"),
+			MountPath: pulumi.String(path.Dir(clSpec.JwtPath)),
+		},
+	}
+	clVolumes := corev1.VolumeArray{}
+	clVolumeMounts = append(clVolumeMounts, clExternalIpMounts...)
+
+	// Create a ConfigMap with the consensus client's config file, for clients that read one
+	if cl.ConfigFile() != "" {
+		configMap, err := corev1.NewConfigMap(ctx, fmt.Sprintf("%s-config", clName), &corev1.ConfigMapArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			Data: pulumi.StringMap{
+				cl.ConfigFile(): pulumi.String(args.ConsensusConfig),
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+		clVolumeMounts = append(clVolumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.Sprintf("%s-config", clName),
+			MountPath: pulumi.String(consensusClient.ConfigDir(cl)),
+		})
+		clVolumes = append(clVolumes, corev1.VolumeArgs{
+			Name: pulumi.Sprintf("%s-config", clName),
+			ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+				Name: configMap.Metadata.Name(),
+			},
+		})
+	}
+
+	clContainerPorts := corev1.ContainerPortArray{
+		corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(ports.ConsensusMetrics),
+		},
+		corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(ports.ConsensusHttp),
+		},
+	}
+	pod.addPort("metrics", ports.ConsensusMetrics, "")
+	pod.addPort("http", ports.ConsensusHttp, "")
+	clP2PServicePorts := corev1.ServicePortArray{}
+	for _, port := range cl.P2PPorts(clSpec) {
+		clContainerPorts = append(clContainerPorts, corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(port.Port),
+			HostPort:      p2pHostPort(args, port.Port),
+			Protocol:      pulumi.String(port.Protocol),
+		})
+		pod.addPort(port.Name, port.Port, port.Protocol)
+		pod.p2pPorts = append(pod.p2pPorts, fmt.Sprintf("%d/%s", port.Port, port.Protocol))
+		clP2PServicePorts = append(clP2PServicePorts, corev1.ServicePortArgs{
+			Port:     pulumi.Int(port.Port),
+			Protocol: pulumi.String(port.Protocol),
+			Name:     pulumi.String(port.Name),
+		})
+		pod.p2pServicePorts = append(pod.p2pServicePorts, corev1.ServicePortArgs{
+			Port:     pulumi.Int(port.Port),
+			Protocol: pulumi.String(port.Protocol),
+			Name:     pulumi.Sprintf("%s-%s", pod.kind, port.Name),
+		})
+	}
+
+	// a restored snapshot has to be in place before any checkpoint sync looks at the database
+	clInitContainers := corev1.ContainerArray{}
+	if args.SnapshotRestore != nil && args.SnapshotRestore.Consensus != nil {
+		clInitContainers = append(clInitContainers, snapshotRestoreContainer(args, clName, args.SnapshotRestore.Consensus, clDataVolumeName, cl.DataDir(network)))
+	}
+
+	// some clients checkpoint sync in a separate step before the beacon node starts
+	if initCommand := cl.InitCommand(clSpec); initCommand != nil {
+		clInitContainers = append(clInitContainers, corev1.ContainerArgs{
+			Name:         pulumi.Sprintf("%s-init", clName),
+			Image:        pulumi.String(args.ConsensusClientImage),
+			Command:      pulumi.ToStringArray(initCommand),
+			VolumeMounts: clVolumeMounts,
+		})
+	}
+
+	clStartupProbe, clLivenessProbe, clReadinessProbe := consensusProbes(args)
+
+	pod.initContainers = clInitContainers
+	pod.containers = corev1.ContainerArray{
+		corev1.ContainerArgs{
+			Name:           pulumi.String(clName),
+			Image:          pulumi.String(args.ConsensusClientImage),
+			Command:        pulumi.ToStringArray(advertisingCommand(args, cl.Command(clSpec))),
+			Ports:          clContainerPorts,
+			VolumeMounts:   clVolumeMounts,
+			StartupProbe:   clStartupProbe,
+			LivenessProbe:  clLivenessProbe,
+			ReadinessProbe: clReadinessProbe,
+		},
+	}
+	pod.volumes = clVolumes
+
+	// Create a service for the consensus client p2p traffic, peers must reach the client while it is still syncing.
+	// With p2p configured peers dial the node directly on the advertised address instead.
+	if args.P2p == nil {
+		_, err = corev1.NewService(ctx, fmt.Sprintf("%s-p2p-service", clName), &corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector:                 clLabels,
+				Type:                     pulumi.String("NodePort"),
+				Ports:                    clP2PServicePorts,
+				PublishNotReadyAddresses: pulumi.Bool(true),
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create a service for the beacon api and metrics
+	internalService, err := corev1.NewService(ctx, fmt.Sprintf("%s-internal-service", clName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: clLabels,
+			Type:     pulumi.String("ClusterIP"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(ports.ConsensusHttp),
+					Name: pulumi.String("http"),
+				},
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(ports.ConsensusMetrics),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-internal-service", clName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consensusResources{
+		client:       cl,
+		labels:       clLabels,
+		pod:          pod,
+		beaconApiUrl: pulumi.Sprintf("http://%s.%s:%d", internalService.Metadata.Name().Elem(), args.Namespace, ports.ConsensusHttp),
+	}, nil
+}
@@ -0,0 +1,505 @@
+package ethereumNode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"swannynode-mainnet/consensusClient"
+	"swannynode-mainnet/executionClient"
+)
+
+type EthereumNodeComponent struct {
+	pulumi.ResourceState
+
+	// RpcHostname is the public hostname serving the execution client json-rpc api
+	RpcHostname pulumi.StringOutput
+	// LoadBalancerHostname is the hostname of the ALB created for the ingress
+	LoadBalancerHostname pulumi.StringOutput
+	// BeaconApiUrl is the in-cluster url of the consensus client's beacon api
+	BeaconApiUrl pulumi.StringOutput
+	// WsUrl is the public websocket json-rpc url, empty when websockets are disabled
+	WsUrl pulumi.StringOutput
+	// ExecutionJwt is the engine api jwt shared by both clients, a pulumi secret
+	ExecutionJwt pulumi.StringOutput
+	// P2pAddresses are the addresses each pod advertises to its peers, by pod
+	// name, when they are reached through p2p load balancers
+	P2pAddresses pulumi.StringMapOutput
+	// P2pPorts are the ports peers reach the clients on as port/protocol,
+	// for the cluster program to open on the node security group
+	P2pPorts pulumi.StringArrayOutput
+}
+
+// Ports holds the container and service ports used by the execution and
+// consensus clients. Zero values are replaced with the client defaults.
+type Ports struct {
+	ExecutionP2P     int `json:"executionP2P"`
+	ExecutionRpc     int `json:"executionRpc"`
+	ExecutionWs      int `json:"executionWs"`
+	ExecutionEngine  int `json:"executionEngine"`
+	ExecutionMetrics int `json:"executionMetrics"`
+	ConsensusP2P     int `json:"consensusP2P"`
+	ConsensusQuic    int `json:"consensusQuic"`
+	ConsensusHttp    int `json:"consensusHttp"`
+	ConsensusMetrics int `json:"consensusMetrics"`
+}
+
+type EthereumNodeComponentArgs struct {
+	Network   Network
+	Namespace string
+	// ExecutionClient is one of reth, geth, nethermind, besu or erigon, defaults to reth
+	ExecutionClient      string
+	ExecutionClientImage string
+	// ExecutionHttpApis are the json-rpc namespaces served on the rpc port
+	ExecutionHttpApis []string
+	// ConsensusClient is one of lighthouse, prysm, teku, nimbus or lodestar, defaults to lighthouse
+	ConsensusClient      string
+	ConsensusClientImage string
+	ExecutionStorageSize string
+	ConsensusStorageSize string
+	StorageClass         string
+	// CoLocated runs the execution and consensus clients as sibling containers
+	// of one pod, talking over the engine api on localhost, with a volume claim
+	// per client and ordinal
+	CoLocated bool
+	// SnapshotRestore fills empty data volumes from datadir archives when set
+	SnapshotRestore *SnapshotRestoreConfig
+	// SnapshotProducer publishes datadir archives of the node on a schedule when set
+	SnapshotProducer *SnapshotProducerConfig
+	// VolumeSnapshots takes csi snapshots of the data volumes on a schedule when set
+	VolumeSnapshots *VolumeSnapshotConfig
+	// VolumeSnapshotRestore creates new data volumes from named VolumeSnapshots when set
+	VolumeSnapshotRestore *VolumeSnapshotSources
+	// VolumeAutoscaler grows the data volumes as they fill up when set
+	VolumeAutoscaler *VolumeAutoscalerConfig
+	// P2p binds the p2p ports on the node and advertises its public address when set
+	P2p *P2pConfig
+	// MevBoost runs mev-boost and points the consensus client's builder api at it when set
+	MevBoost *MevBoostConfig
+	// Replicas is the number of node pairs in co-located mode, defaults to 1.
+	// The rpc gateway balances requests over the pairs that are close to the best head.
+	Replicas int
+	// ExecutionConfig and ConsensusConfig are the raw contents of each client's
+	// config file, they are ignored for clients configured with flags only
+	ExecutionConfig string
+	// RethConfig is rendered to reth.toml in place of ExecutionConfig when set
+	RethConfig      *executionClient.RethConfig
+	ConsensusConfig string
+	// LighthouseConfig is rendered to lighthouse.toml in place of ConsensusConfig when set
+	LighthouseConfig *consensusClient.LighthouseConfig
+	// ExecutionJwt overrides the generated engine api jwt when set
+	ExecutionJwt pulumi.StringInput
+	// JwtRotation is kept with the generated jwt, changing it generates a new
+	// jwt and restarts both clients together
+	JwtRotation string
+	// KubectlImage runs the job stopping both clients before a new jwt rolls
+	// out, required outside co-located mode
+	KubectlImage      string
+	CheckpointSyncUrl string
+	Ports             Ports
+	// Probes tunes the readiness, liveness and startup probes of both clients
+	Probes ProbeConfig
+	// RpcGateway routes the public ingress through the json-rpc allowlist gateway when set
+	RpcGateway *RpcGatewayConfig
+	// Ws enables websocket json-rpc behind its own ingress and hostname when set
+	Ws             *WsConfig
+	PublicHostname string
+	TlsCertArn     string
+	ZoneId         string
+}
+
+const (
+	defaultNamespace    = "default"
+	defaultStorageClass = "aws-gp3"
+)
+
+// DefaultPorts returns the ports reth and lighthouse listen on out of the box.
+func DefaultPorts() Ports {
+	return Ports{
+		ExecutionP2P:     30303,
+		ExecutionRpc:     8545,
+		ExecutionWs:      8546,
+		ExecutionEngine:  8551,
+		ExecutionMetrics: 9001,
+		ConsensusP2P:     9000,
+		ConsensusQuic:    9001,
+		ConsensusHttp:    5052,
+		ConsensusMetrics: 5054,
+	}
+}
+
+// withDefaults fills any unset port with its default value
+func (p Ports) withDefaults() Ports {
+	defaults := DefaultPorts()
+	fill := func(port *int, fallback int) {
+		if *port == 0 {
+			*port = fallback
+		}
+	}
+	fill(&p.ExecutionP2P, defaults.ExecutionP2P)
+	fill(&p.ExecutionRpc, defaults.ExecutionRpc)
+	fill(&p.ExecutionWs, defaults.ExecutionWs)
+	fill(&p.ExecutionEngine, defaults.ExecutionEngine)
+	fill(&p.ExecutionMetrics, defaults.ExecutionMetrics)
+	fill(&p.ConsensusP2P, defaults.ConsensusP2P)
+	fill(&p.ConsensusQuic, defaults.ConsensusQuic)
+	fill(&p.ConsensusHttp, defaults.ConsensusHttp)
+	fill(&p.ConsensusMetrics, defaults.ConsensusMetrics)
+	return p
+}
+
+// validate checks the required arguments and applies defaults for everything else
+func (args *EthereumNodeComponentArgs) validate() error {
+	if _, err := ParseNetwork(string(args.Network)); err != nil {
+		return err
+	}
+	if args.ExecutionJwt != nil && args.JwtRotation != "" {
+		return fmt.Errorf("jwtRotation only applies to the generated jwt, rotate an overridden execution jwt by changing it")
+	}
+	if !args.CoLocated && args.KubectlImage == "" {
+		return fmt.Errorf("kubectlImage is required to rotate the jwt of clients in separate statefulsets")
+	}
+	if args.ExecutionStorageSize == "" || args.ConsensusStorageSize == "" {
+		return fmt.Errorf("execution and consensus storage sizes are required")
+	}
+	if args.PublicHostname == "" || args.TlsCertArn == "" || args.ZoneId == "" {
+		return fmt.Errorf("publicHostname, tlsCertArn and zoneId are required for the rpc ingress")
+	}
+	if args.Namespace == "" {
+		args.Namespace = defaultNamespace
+	}
+	if args.ExecutionClient == "" {
+		args.ExecutionClient = executionClient.Reth
+	}
+	el, err := executionClient.New(args.ExecutionClient)
+	if err != nil {
+		return err
+	}
+	if args.ExecutionClientImage == "" {
+		args.ExecutionClientImage = el.Image()
+	}
+	if args.RethConfig != nil {
+		if el.Name() != executionClient.Reth {
+			return fmt.Errorf("rethConfig is set but the execution client is %s", el.Name())
+		}
+		rendered, err := args.RethConfig.Render()
+		if err != nil {
+			return fmt.Errorf("invalid reth config: %w", err)
+		}
+		args.ExecutionConfig = rendered
+	}
+	if len(args.ExecutionHttpApis) == 0 {
+		args.ExecutionHttpApis = executionClient.DefaultHttpApis
+	}
+	if args.ConsensusClient == "" {
+		args.ConsensusClient = consensusClient.Lighthouse
+	}
+	cl, err := consensusClient.New(args.ConsensusClient)
+	if err != nil {
+		return err
+	}
+	if args.ConsensusClientImage == "" {
+		args.ConsensusClientImage = cl.Image()
+	}
+	if args.LighthouseConfig != nil {
+		if cl.Name() != consensusClient.Lighthouse {
+			return fmt.Errorf("lighthouseConfig is set but the consensus client is %s", cl.Name())
+		}
+		// lighthouse.toml and the --builder flag mev-boost adds would both
+		// set the builder, leaving which one wins to flag precedence
+		if args.LighthouseConfig.Builder.Url != "" && args.MevBoost != nil {
+			return fmt.Errorf("lighthouseConfig builder url and mevBoost are both set, drop the builder url to use mev-boost")
+		}
+		rendered, err := args.LighthouseConfig.Render()
+		if err != nil {
+			return fmt.Errorf("invalid lighthouse config: %w", err)
+		}
+		args.ConsensusConfig = rendered
+		if args.LighthouseConfig.CheckpointSyncUrl != "" {
+			args.CheckpointSyncUrl = args.LighthouseConfig.CheckpointSyncUrl
+		}
+	}
+	if args.StorageClass == "" {
+		args.StorageClass = defaultStorageClass
+	}
+	if args.CheckpointSyncUrl == "" {
+		args.CheckpointSyncUrl = args.Network.CheckpointSyncUrl()
+	}
+	if args.Replicas == 0 {
+		args.Replicas = 1
+	}
+	if args.Replicas < 1 {
+		return fmt.Errorf("replicas must be at least 1")
+	}
+	if args.Replicas > 1 && !args.CoLocated {
+		return fmt.Errorf("replicas above 1 need coLocated, every execution client needs a consensus client of its own")
+	}
+	if args.Replicas > 1 && args.RpcGateway == nil {
+		return fmt.Errorf("replicas above 1 need the rpcGateway, it keeps requests off replicas that fall behind")
+	}
+	args.Ports = args.Ports.withDefaults()
+	if args.RpcGateway != nil {
+		if err := args.RpcGateway.validate(); err != nil {
+			return err
+		}
+	}
+	if args.Ws != nil {
+		if !el.LimitsWsApis() {
+			return fmt.Errorf("ws is not supported with %s, it serves the full http api set over websockets", el.Name())
+		}
+		// the ws ingress goes straight to the execution client, past the
+		// gateway's allowlist, api keys and rate limits
+		if args.RpcGateway != nil {
+			return fmt.Errorf("ws cannot be enabled with the rpcGateway, websockets are not proxied by the gateway")
+		}
+		if err := args.Ws.validate(executionClient.DefaultWsApis); err != nil {
+			return err
+		}
+		if args.Ws.Hostname == args.PublicHostname {
+			return fmt.Errorf("ws hostname must differ from publicHostname, it is served by its own load balancer")
+		}
+	}
+	if args.SnapshotRestore != nil {
+		if err := args.SnapshotRestore.validate(); err != nil {
+			return err
+		}
+	}
+	if args.SnapshotProducer != nil {
+		if err := args.SnapshotProducer.validate(); err != nil {
+			return err
+		}
+	}
+	if args.VolumeSnapshots != nil {
+		if err := args.VolumeSnapshots.validate(); err != nil {
+			return err
+		}
+	}
+	if args.VolumeAutoscaler != nil {
+		if err := args.VolumeAutoscaler.validate(); err != nil {
+			return err
+		}
+	}
+	if args.P2p != nil {
+		if err := args.P2p.validate(args); err != nil {
+			return err
+		}
+	}
+	if args.MevBoost != nil {
+		if err := args.MevBoost.validate(args.Network); err != nil {
+			return err
+		}
+	}
+	args.Probes = args.Probes.withDefaults()
+	if args.Probes.MinPeers < 0 || args.Probes.StartupMinutes < 0 {
+		return fmt.Errorf("probe minPeers and startupMinutes cannot be negative")
+	}
+	return nil
+}
+
+// childOpts parents a resource to the component and aliases it to the
+// un-parented URN it had when the node program was a flat main.go, so
+// existing stacks adopt their resources in place instead of replacing them.
+func childOpts(component pulumi.Resource, previousName string, opts ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	alias := pulumi.Alias{NoParent: pulumi.Bool(true)}
+	if previousName != "" {
+		alias.Name = pulumi.String(previousName)
+	}
+	return append([]pulumi.ResourceOption{
+		pulumi.Parent(component),
+		pulumi.Aliases([]pulumi.Alias{alias}),
+	}, opts...)
+}
+
+// configHash is set as a pod template annotation so pods roll when a mounted config file changes
+func configHash(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dataVolumeClaimSpec is the spec of the persistent volume claim a client of
+// kind keeps its database on
+func dataVolumeClaimSpec(size, kind string, args *EthereumNodeComponentArgs) *corev1.PersistentVolumeClaimSpecArgs {
+	return &corev1.PersistentVolumeClaimSpecArgs{
+		AccessModes: pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+		DataSource:  snapshotDataSource(args, kind),
+		Resources: &corev1.VolumeResourceRequirementsArgs{
+			Requests: pulumi.StringMap{
+				"storage": pulumi.String(size),
+			},
+		},
+		StorageClassName: pulumi.String(args.StorageClass),
+	}
+}
+
+// newDataVolumeClaim creates the named persistent volume claim a client keeps
+// its database on outside co-located mode. A snapshot source only applies when
+// the claim is created, and the size is left to the volume autoscaler once it
+// runs, so neither change replaces or shrinks a claim holding a synced database.
+func newDataVolumeClaim(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, part *podParts) (*corev1.PersistentVolumeClaim, error) {
+	ignored := []string{"spec.dataSource", "spec.dataSourceRef"}
+	if args.VolumeAutoscaler != nil {
+		ignored = append(ignored, "spec.resources.requests")
+	}
+	return corev1.NewPersistentVolumeClaim(ctx, part.dataClaimResource, &corev1.PersistentVolumeClaimArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(part.dataVolume),
+			Namespace: pulumi.String(args.Namespace),
+		},
+		Spec: dataVolumeClaimSpec(part.storageSize, part.kind, args),
+	}, childOpts(component, "", pulumi.IgnoreChanges(ignored))...)
+}
+
+// ethereumNodeType is the component's type token, the same for every
+// network, which is an input rather than part of the type
+const ethereumNodeType = "swannynode:index:EthereumNode"
+
+// NewEthereumNodeComponent creates an execution client and a consensus client
+// for the requested network, along with their storage, services, public rpc
+// ingress and route53 record.
+//
+// Example usage:
+//
+//	node, err := ethereumNode.NewEthereumNodeComponent(ctx, "ethereumNode", &ethereumNode.EthereumNodeComponentArgs{
+//		Network:              ethereumNode.Mainnet,
+//		ExecutionClient:      "geth",
+//		ConsensusClient:      "teku",
+//		ExecutionStorageSize: "2Ti",
+//		ConsensusStorageSize: "300Gi",
+//		KubectlImage:         kubectlImage,
+//		PublicHostname:       cfg.Require("publicHostname"),
+//		TlsCertArn:           cfg.Require("rethIngressTlsCertArn"),
+//		ZoneId:               cfg.Require("zoneId"),
+//	})
+func NewEthereumNodeComponent(ctx *pulumi.Context, name string, args *EthereumNodeComponentArgs, opts ...pulumi.ResourceOption) (*EthereumNodeComponent, error) {
+	if args == nil {
+		args = &EthereumNodeComponentArgs{}
+	}
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+
+	// the network used to be part of the type, the alias keeps the urns of
+	// stacks created before it was fixed
+	opts = append([]pulumi.ResourceOption{pulumi.Aliases([]pulumi.Alias{
+		{Type: pulumi.String(fmt.Sprintf("custom:component:EthereumNode:%s", args.Network))},
+	})}, opts...)
+	component := &EthereumNodeComponent{}
+	err := ctx.RegisterComponentResource(ethereumNodeType, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	jwt, err := newEngineJwt(ctx, component, args)
+	if err != nil {
+		return nil, err
+	}
+
+	execution, err := newExecutionClient(ctx, component, args)
+	if err != nil {
+		return nil, err
+	}
+
+	consensus, err := newConsensusClient(ctx, component, args, execution.engineEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.MevBoost != nil {
+		if err := newMevBoost(ctx, component, args); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the statefulsets running the clients, with their snapshot jobs when configured
+	sets := nodeSets(args, execution, consensus)
+	if !args.CoLocated {
+		if jwt.rotation, err = newJwtRotation(ctx, component, args, sets, jwt); err != nil {
+			return nil, err
+		}
+	}
+	p2pAddresses := pulumi.StringMap{}
+	p2pPorts := pulumi.StringArray{}
+	for _, set := range sets {
+		for _, part := range set.parts {
+			for _, port := range part.p2pPorts {
+				p2pPorts = append(p2pPorts, pulumi.String(port))
+			}
+		}
+		if args.P2p != nil && args.P2p.Mode == P2pLoadBalancer {
+			addresses, err := newP2pLoadBalancers(ctx, component, args, set)
+			if err != nil {
+				return nil, err
+			}
+			for pod, address := range addresses {
+				p2pAddresses[pod] = address
+			}
+		}
+		if err := newNodeStatefulSet(ctx, component, args, set, jwt); err != nil {
+			return nil, err
+		}
+		if args.SnapshotProducer != nil {
+			if err := newSnapshotProducer(ctx, component, args, set); err != nil {
+				return nil, err
+			}
+		}
+		if args.VolumeSnapshots != nil {
+			if err := newVolumeSnapshots(ctx, component, args, set); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if args.VolumeAutoscaler != nil {
+		if err := newVolumeAutoscaler(ctx, component, args, sets); err != nil {
+			return nil, err
+		}
+	}
+
+	// the public ingress goes through the gateway when one is configured
+	publicService, publicPort := execution.rpcService, args.Ports.ExecutionRpc
+	if args.RpcGateway != nil {
+		publicService, err = newRpcGateway(ctx, component, args, execution)
+		if err != nil {
+			return nil, err
+		}
+		publicPort = gatewayPort
+	}
+
+	rpcDns, lbHostname, err := newRpcIngress(ctx, component, args, execution.client.Name(), publicService, publicPort)
+	if err != nil {
+		return nil, err
+	}
+
+	component.WsUrl = pulumi.String("").ToStringOutput()
+	if args.Ws != nil {
+		wsDns, err := newWsIngress(ctx, component, args, execution)
+		if err != nil {
+			return nil, err
+		}
+		component.WsUrl = pulumi.Sprintf("wss://%s%s", wsDns.Fqdn, strings.TrimSuffix(args.Ws.Path, "/"))
+	}
+
+	component.RpcHostname = rpcDns.Fqdn
+	component.LoadBalancerHostname = lbHostname
+	component.BeaconApiUrl = consensus.beaconApiUrl
+	component.ExecutionJwt = jwt.value
+	component.P2pAddresses = p2pAddresses.ToStringMapOutput()
+	component.P2pPorts = p2pPorts.ToStringArrayOutput()
+	if err := ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"rpcHostname":          component.RpcHostname,
+		"loadBalancerHostname": component.LoadBalancerHostname,
+		"beaconApiUrl":         component.BeaconApiUrl,
+		"wsUrl":                component.WsUrl,
+		"executionJwt":         component.ExecutionJwt,
+		"p2pAddresses":         component.P2pAddresses,
+		"p2pPorts":             component.P2pPorts,
+	}); err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
@@ -0,0 +1,284 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"swannynode-mainnet/executionClient"
+)
+
+// executionResources are the pieces of the execution client the rest of the node is wired to
+type executionResources struct {
+	client         executionClient.Client
+	labels         pulumi.StringMap
+	rpcService     *corev1.Service
+	engineEndpoint string
+	// pod holds the containers and volumes of the client, run by a statefulset of their own or next to the consensus client
+	pod *podParts
+	// wsService and wsPort serve websocket json-rpc, wsService is nil when websockets are disabled
+	wsService *corev1.Service
+	wsPort    int
+}
+
+// newExecutionClient creates the config and services for the configured execution client and
+// the pod parts its statefulset is built from
+func newExecutionClient(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs) (*executionResources, error) {
+	el, err := executionClient.New(args.ExecutionClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := args.Ports
+	namespace := pulumi.String(args.Namespace)
+	elName := el.Name()
+	elLabels := nodeLabels(args, elName)
+	elDataVolumeName := fmt.Sprintf("%s-config-data", elName)
+	elSpec := executionClient.Spec{
+		Network:     string(args.Network),
+		JwtPath:     executionClient.JwtPath(el),
+		EnginePort:  ports.ExecutionEngine,
+		RpcPort:     ports.ExecutionRpc,
+		MetricsPort: ports.ExecutionMetrics,
+		P2PPort:     ports.ExecutionP2P,
+		HttpApis:    args.ExecutionHttpApis,
+	}
+	if args.Ws != nil {
+		elSpec.WsPort = ports.ExecutionWs
+		elSpec.WsApis = args.Ws.Apis
+	}
+	var elExternalIpMounts corev1.VolumeMountArray
+	elSpec.ExternalIp, elExternalIpMounts = externalIp(args)
+
+	pod := &podParts{
+		client:            elName,
+		kind:              executionKind,
+		dataVolume:        elDataVolumeName,
+		dataClaimResource: fmt.Sprintf("%s-data", elName),
+		storageSize:       args.ExecutionStorageSize,
+	}
+
+	elVolumeMounts := corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String(elDataVolumeName),
+			MountPath: pulumi.String(el.DataMountPath()),
+		},
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String("execution-jwt"),
+			MountPath: pulumi.String(path.Dir(elSpec.JwtPath)),
+		},
+	}
+	elVolumes := corev1.VolumeArray{}
+	elVolumeMounts = append(elVolumeMounts, elExternalIpMounts...)
+
+	// Create a ConfigMap with the execution client's config file, for clients that read one
+	if el.ConfigFile() != "" {
+		configMap, err := corev1.NewConfigMap(ctx, fmt.Sprintf("%s-config", elName), &corev1.ConfigMapArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			Data: pulumi.StringMap{
+				el.ConfigFile(): pulumi.String(args.ExecutionConfig),
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+		elVolumeMounts = append(elVolumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.Sprintf("%s-config", elName),
+			MountPath: pulumi.String(executionClient.ConfigDir(el)),
+		})
+		elVolumes = append(elVolumes, corev1.VolumeArgs{
+			Name: pulumi.Sprintf("%s-config", elName),
+			ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+				Name: configMap.Metadata.Name(),
+			},
+		})
+	}
+
+	elContainerPorts := corev1.ContainerPortArray{
+		corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(ports.ExecutionMetrics),
+		},
+		corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(ports.ExecutionRpc),
+		},
+		corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(ports.ExecutionEngine),
+		},
+	}
+	pod.addPort("metrics", ports.ExecutionMetrics, "")
+	pod.addPort("rpc", ports.ExecutionRpc, "")
+	pod.addPort("engine", ports.ExecutionEngine, "")
+	wsPort := el.WsPort(elSpec)
+	if args.Ws != nil && wsPort != ports.ExecutionRpc {
+		elContainerPorts = append(elContainerPorts, corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(wsPort),
+		})
+		pod.addPort("ws", wsPort, "")
+	}
+	elP2PServicePorts := corev1.ServicePortArray{}
+	for _, port := range el.P2PPorts(elSpec) {
+		elContainerPorts = append(elContainerPorts, corev1.ContainerPortArgs{
+			ContainerPort: pulumi.Int(port.Port),
+			HostPort:      p2pHostPort(args, port.Port),
+			Protocol:      pulumi.String(port.Protocol),
+		})
+		pod.addPort(port.Name, port.Port, port.Protocol)
+		pod.p2pPorts = append(pod.p2pPorts, fmt.Sprintf("%d/%s", port.Port, port.Protocol))
+		elP2PServicePorts = append(elP2PServicePorts, corev1.ServicePortArgs{
+			Port:     pulumi.Int(port.Port),
+			Protocol: pulumi.String(port.Protocol),
+			Name:     pulumi.String(port.Name),
+		})
+		pod.p2pServicePorts = append(pod.p2pServicePorts, corev1.ServicePortArgs{
+			Port:     pulumi.Int(port.Port),
+			Protocol: pulumi.String(port.Protocol),
+			Name:     pulumi.Sprintf("%s-%s", pod.kind, port.Name),
+		})
+	}
+
+	elStartupProbe, elLivenessProbe := executionProbes(args)
+	elContainers := corev1.ContainerArray{
+		corev1.ContainerArgs{
+			Name:          pulumi.String(elName),
+			Image:         pulumi.String(args.ExecutionClientImage),
+			Command:       pulumi.ToStringArray(advertisingCommand(args, el.Command(elSpec))),
+			Ports:         elContainerPorts,
+			VolumeMounts:  elVolumeMounts,
+			StartupProbe:  elStartupProbe,
+			LivenessProbe: elLivenessProbe,
+		},
+	}
+	if !args.Probes.Disabled {
+		elContainers = append(elContainers, executionHealthSidecar(args))
+	}
+
+	pod.containers = elContainers
+	pod.volumes = elVolumes
+	if args.SnapshotRestore != nil && args.SnapshotRestore.Execution != nil {
+		pod.initContainers = corev1.ContainerArray{
+			snapshotRestoreContainer(args, elName, args.SnapshotRestore.Execution, elDataVolumeName, el.DataMountPath()),
+		}
+	}
+
+	// Create a Service for external ports, peers must reach the client while it is still syncing.
+	// With p2p configured peers dial the node directly on the advertised address instead.
+	if args.P2p == nil {
+		_, err = corev1.NewService(ctx, fmt.Sprintf("%s-p2pnet-service", elName), &corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Namespace: namespace,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector:                 elLabels,
+				Type:                     pulumi.String("NodePort"),
+				Ports:                    elP2PServicePorts,
+				PublishNotReadyAddresses: pulumi.Bool(true),
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create a service for internal ports. The consensus client drives the sync
+	// over the engine api, so it has to reach the execution client before it is ready.
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-internal-service", elName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:                 elLabels,
+			Type:                     pulumi.String("ClusterIP"),
+			PublishNotReadyAddresses: pulumi.Bool(true),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(ports.ExecutionMetrics),
+					Name: pulumi.String("metrics"),
+				},
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(ports.ExecutionEngine),
+					Name: pulumi.String("p2p"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-internal-service", elName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rpc service serves every configured http api, it stays internal when
+	// the gateway is the public entrypoint
+	rpcServiceType := "NodePort"
+	if args.RpcGateway != nil {
+		rpcServiceType = "ClusterIP"
+	}
+
+	// Create a service for the execution client rpc traffic, it only routes to synced pods
+	rpcService, err := corev1.NewService(ctx, fmt.Sprintf("%s-rpc-service", elName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: elLabels,
+			Type:     pulumi.String(rpcServiceType),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port:       pulumi.Int(ports.ExecutionRpc),
+					TargetPort: pulumi.Int(ports.ExecutionRpc),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-rpc-service", elName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var wsService *corev1.Service
+	if args.Ws != nil {
+		// Create a service for the execution client websocket traffic
+		wsService, err = corev1.NewService(ctx, fmt.Sprintf("%s-ws-service", elName), &corev1.ServiceArgs{
+			Spec: &corev1.ServiceSpecArgs{
+				Selector: elLabels,
+				Type:     pulumi.String("NodePort"),
+				Ports: corev1.ServicePortArray{
+					corev1.ServicePortArgs{
+						Port:       pulumi.Int(wsPort),
+						TargetPort: pulumi.Int(wsPort),
+					},
+				},
+			},
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.Sprintf("%s-ws-service", elName),
+				Namespace: namespace,
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &executionResources{
+		client:         el,
+		labels:         elLabels,
+		rpcService:     rpcService,
+		engineEndpoint: engineEndpoint(args, elName),
+		pod:            pod,
+		wsService:      wsService,
+		wsPort:         wsPort,
+	}, nil
+}
+
+// engineEndpoint is where the consensus client reaches the engine api, over
+// localhost when both clients share a pod so it can never pair with another node
+func engineEndpoint(args *EthereumNodeComponentArgs, elName string) string {
+	if args.CoLocated {
+		return fmt.Sprintf("http://127.0.0.1:%d", args.Ports.ExecutionEngine)
+	}
+	return fmt.Sprintf("http://%s-internal-service.%s:%d", elName, args.Namespace, args.Ports.ExecutionEngine)
+}
@@ -0,0 +1,480 @@
+package ethereumNode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// RpcGatewayConfig puts the rpc-gateway json-rpc proxy between the public
+// ingress and the execution client, so only allowlisted methods reach it.
+// The full set of http apis stays available on the internal rpc service.
+// The gateway spreads requests over the execution client replicas, skipping
+// any that fall more than MaxBlockLag blocks behind the best head.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:rpcGateway:
+//	  image: <registry>/rpc-gateway:v0.1.0
+//	  maxBatchSize: 50
+//	  routes:
+//	    - path: /
+//	      methods: ["eth_*", "net_version", "web3_clientVersion"]
+//	  methodClasses:
+//	    - name: heavy
+//	      methods: ["eth_getLogs", "eth_call", "eth_estimateGas"]
+//	  rateLimits:
+//	    default: {requestsPerSecond: 50, burst: 100}
+//	    heavy: {requestsPerSecond: 5, burst: 10}
+//
+// Api keys are a json list of {name, key, rateLimits} entries, either set
+// as the `rpcApiKeys` secret or stored under keys.json in an existing
+// kubernetes secret named by apiKeysSecret. Without keys the gateway is open
+// and every caller shares the anonymous rate limits.
+type RpcGatewayConfig struct {
+	// Image is built from the rpc-gateway directory of this repo
+	Image    string `json:"image"`
+	Replicas int    `json:"replicas"`
+	// MaxBodyBytes caps the size of a request body
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+	// MaxBatchSize caps the number of calls in a batch request
+	MaxBatchSize int `json:"maxBatchSize"`
+	// Routes map a request path to the methods it allows, defaults to the
+	// read and transaction methods on /
+	Routes []RpcRoute `json:"routes"`
+	// MethodClasses group methods that share a rate limit, methods in no
+	// class belong to the default class
+	MethodClasses []RpcMethodClass `json:"methodClasses"`
+	// RateLimits are the per key limits of each method class
+	RateLimits map[string]RpcRateLimit `json:"rateLimits"`
+	// ApiKeysSecret names an existing secret holding the api keys under keys.json
+	ApiKeysSecret string `json:"apiKeysSecret"`
+	// ApiKeys is the api keys json, it comes from a config secret rather than the gateway object
+	ApiKeys pulumi.StringInput `json:"-"`
+	// MaxBlockLag is how far behind the best head of the pool a replica may be and still get requests
+	MaxBlockLag int `json:"maxBlockLag"`
+	// PollIntervalSeconds is how often the gateway polls the head of every replica
+	PollIntervalSeconds int `json:"pollIntervalSeconds"`
+}
+
+// RpcRoute allows a set of methods on a path. A method ending in `*` allows
+// every method with that prefix, e.g. `eth_*`.
+type RpcRoute struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+}
+
+// RpcMethodClass names a group of methods using the same patterns as a route
+type RpcMethodClass struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+// RpcRateLimit is a token bucket refilled at RequestsPerSecond up to Burst
+// tokens, a zero rate leaves the class unlimited
+type RpcRateLimit struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// RpcApiKey is an entry of the api keys json. Name is the label of the
+// key's metrics, the key itself is never exported.
+type RpcApiKey struct {
+	Name       string                  `json:"name"`
+	Key        string                  `json:"key"`
+	RateLimits map[string]RpcRateLimit `json:"rateLimits"`
+}
+
+const (
+	defaultGatewayReplicas     = 2
+	defaultGatewayMaxBodyBytes = 1 << 20
+	defaultGatewayMaxBatchSize = 100
+	defaultGatewayMaxBlockLag  = 2
+	defaultGatewayPollInterval = 2
+	defaultRpcMethodClass      = "default"
+	gatewayPort                = 8545
+	gatewayMetricsPort         = 9102
+	gatewayConfigDir           = "/etc/rpc-gateway"
+	gatewayConfigFile          = "gateway.json"
+	gatewayKeysDir             = "/etc/rpc-gateway/keys"
+	gatewayKeysFile            = "keys.json"
+)
+
+// DefaultPublicMethods are the methods served on the public route when none
+// are configured, the debug, trace, txpool and admin namespaces are left out
+var DefaultPublicMethods = []string{"eth_*", "net_version", "net_listening", "web3_clientVersion", "web3_sha3"}
+
+func (c *RpcGatewayConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("rpcGateway image is required")
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultGatewayReplicas
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = defaultGatewayMaxBodyBytes
+	}
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = defaultGatewayMaxBatchSize
+	}
+	if c.MaxBlockLag == 0 {
+		c.MaxBlockLag = defaultGatewayMaxBlockLag
+	}
+	if c.PollIntervalSeconds == 0 {
+		c.PollIntervalSeconds = defaultGatewayPollInterval
+	}
+	if c.Replicas < 0 || c.MaxBodyBytes < 0 || c.MaxBatchSize < 0 || c.MaxBlockLag < 0 || c.PollIntervalSeconds < 0 {
+		return fmt.Errorf("rpcGateway replicas, maxBodyBytes, maxBatchSize, maxBlockLag and pollIntervalSeconds cannot be negative")
+	}
+	if len(c.Routes) == 0 {
+		c.Routes = []RpcRoute{{Path: "/", Methods: DefaultPublicMethods}}
+	}
+	paths := map[string]bool{}
+	for _, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("rpcGateway route path %q must start with /", route.Path)
+		}
+		if paths[route.Path] {
+			return fmt.Errorf("rpcGateway route path %q is defined more than once", route.Path)
+		}
+		paths[route.Path] = true
+		if len(route.Methods) == 0 {
+			return fmt.Errorf("rpcGateway route %s allows no methods", route.Path)
+		}
+	}
+	classes := map[string]bool{defaultRpcMethodClass: true}
+	for _, class := range c.MethodClasses {
+		if class.Name == "" || classes[class.Name] {
+			return fmt.Errorf("rpcGateway method class %q must be named and defined once, %s is reserved", class.Name, defaultRpcMethodClass)
+		}
+		if len(class.Methods) == 0 {
+			return fmt.Errorf("rpcGateway method class %s has no methods", class.Name)
+		}
+		classes[class.Name] = true
+	}
+	if err := validateRateLimits(c.RateLimits, classes); err != nil {
+		return err
+	}
+	if c.ApiKeys != nil && c.ApiKeysSecret != "" {
+		return fmt.Errorf("rpcGateway api keys can come from the rpcApiKeys secret or apiKeysSecret, not both")
+	}
+	return nil
+}
+
+func validateRateLimits(limits map[string]RpcRateLimit, classes map[string]bool) error {
+	for class, limit := range limits {
+		if !classes[class] {
+			return fmt.Errorf("rpcGateway rate limit for unknown method class %q", class)
+		}
+		if limit.RequestsPerSecond < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rpcGateway rate limit for %s cannot be negative", class)
+		}
+	}
+	return nil
+}
+
+// classNames returns the method classes rate limits can refer to
+func (c *RpcGatewayConfig) classNames() map[string]bool {
+	classes := map[string]bool{defaultRpcMethodClass: true}
+	for _, class := range c.MethodClasses {
+		classes[class.Name] = true
+	}
+	return classes
+}
+
+// validateApiKeys checks the api keys json before it is handed to the gateway,
+// which would otherwise refuse to start
+func (c *RpcGatewayConfig) validateApiKeys(raw string) error {
+	var keys []RpcApiKey
+	if err := json.Unmarshal([]byte(raw), &keys); err != nil {
+		return fmt.Errorf("rpcApiKeys must be a json list of api keys: %w", err)
+	}
+	names := map[string]bool{}
+	values := map[string]bool{}
+	for _, key := range keys {
+		if key.Name == "" || key.Key == "" {
+			return fmt.Errorf("every rpc api key needs a name and a key")
+		}
+		if names[key.Name] || values[key.Key] {
+			return fmt.Errorf("rpc api key %q is defined more than once", key.Name)
+		}
+		names[key.Name] = true
+		values[key.Key] = true
+		if err := validateRateLimits(key.RateLimits, c.classNames()); err != nil {
+			return fmt.Errorf("rpc api key %s: %w", key.Name, err)
+		}
+	}
+	return nil
+}
+
+// hasApiKeys reports whether callers must authenticate
+func (c *RpcGatewayConfig) hasApiKeys() bool {
+	return c.ApiKeys != nil || c.ApiKeysSecret != ""
+}
+
+// gatewayPool is the pool section of the gateway config file
+type gatewayPool struct {
+	Host                string `json:"host"`
+	Port                int    `json:"port"`
+	MaxBlockLag         int    `json:"maxBlockLag"`
+	PollIntervalSeconds int    `json:"pollIntervalSeconds"`
+}
+
+// gatewayFile is the config file read by the rpc-gateway binary
+type gatewayFile struct {
+	Listen        string                  `json:"listen"`
+	Pool          gatewayPool             `json:"pool"`
+	MaxBodyBytes  int64                   `json:"maxBodyBytes"`
+	MaxBatchSize  int                     `json:"maxBatchSize"`
+	Routes        []RpcRoute              `json:"routes"`
+	KeysFile      string                  `json:"keysFile,omitempty"`
+	MetricsListen string                  `json:"metricsListen"`
+	MethodClasses []RpcMethodClass        `json:"methodClasses,omitempty"`
+	RateLimits    map[string]RpcRateLimit `json:"rateLimits,omitempty"`
+}
+
+// render encodes the gateway config file forwarding to the replicas behind
+// the headless service at host
+func (c *RpcGatewayConfig) render(host string, port int) (string, error) {
+	file := gatewayFile{
+		Listen: fmt.Sprintf(":%d", gatewayPort),
+		Pool: gatewayPool{
+			Host:                host,
+			Port:                port,
+			MaxBlockLag:         c.MaxBlockLag,
+			PollIntervalSeconds: c.PollIntervalSeconds,
+		},
+		MaxBodyBytes:  c.MaxBodyBytes,
+		MaxBatchSize:  c.MaxBatchSize,
+		Routes:        c.Routes,
+		MetricsListen: fmt.Sprintf(":%d", gatewayMetricsPort),
+		MethodClasses: c.MethodClasses,
+		RateLimits:    c.RateLimits,
+	}
+	if c.hasApiKeys() {
+		file.KeysFile = fmt.Sprintf("%s/%s", gatewayKeysDir, gatewayKeysFile)
+	}
+	rendered, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// newRpcGateway deploys the rpc-gateway in front of the execution client rpc
+// service and returns the service the public ingress should route to
+func newRpcGateway(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, execution *executionResources) (*corev1.Service, error) {
+	gateway := args.RpcGateway
+	elName := execution.client.Name()
+	name := fmt.Sprintf("%s-gateway", elName)
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(name)}
+
+	// Create a headless service resolving to every synced execution client
+	// replica, the gateway polls each one for its head
+	_, err := corev1.NewService(ctx, fmt.Sprintf("%s-pool-service", elName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:  execution.labels,
+			ClusterIP: pulumi.String("None"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(args.Ports.ExecutionRpc),
+					Name: pulumi.String("rpc"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-pool-service", elName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	poolHost := fmt.Sprintf("%s-pool-service.%s.svc.cluster.local", elName, args.Namespace)
+	config, err := gateway.render(poolHost, args.Ports.ExecutionRpc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a ConfigMap with the gateway allowlist and limits
+	configMap, err := corev1.NewConfigMap(ctx, fmt.Sprintf("%s-config", name), &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: namespace,
+		},
+		Data: pulumi.StringMap{
+			gatewayConfigFile: pulumi.String(config),
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeMounts := corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String("config"),
+			MountPath: pulumi.String(gatewayConfigDir),
+		},
+	}
+	volumes := corev1.VolumeArray{
+		corev1.VolumeArgs{
+			Name: pulumi.String("config"),
+			ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+				Name: configMap.Metadata.Name(),
+			},
+		},
+	}
+
+	// The gateway reloads the keys when the mounted secret changes, so
+	// issuing or revoking a key does not restart it
+	if gateway.hasApiKeys() {
+		keysSecretName := pulumi.String(gateway.ApiKeysSecret).ToStringOutput()
+		if gateway.ApiKeys != nil {
+			keys := gateway.ApiKeys.ToStringOutput().ApplyT(func(raw string) (string, error) {
+				return raw, gateway.validateApiKeys(raw)
+			}).(pulumi.StringOutput)
+
+			// Create a secret with the api keys from the stack config
+			keysSecret, err := corev1.NewSecret(ctx, fmt.Sprintf("%s-keys", name), &corev1.SecretArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Namespace: namespace,
+				},
+				StringData: pulumi.StringMap{
+					gatewayKeysFile: pulumi.ToSecret(keys).(pulumi.StringOutput),
+				},
+			}, childOpts(component, "")...)
+			if err != nil {
+				return nil, err
+			}
+			keysSecretName = keysSecret.Metadata.Name().Elem()
+		}
+		volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String("keys"),
+			MountPath: pulumi.String(gatewayKeysDir),
+			ReadOnly:  pulumi.Bool(true),
+		})
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String("keys"),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName: keysSecretName,
+			},
+		})
+	}
+
+	health := &corev1.HTTPGetActionArgs{
+		Path: pulumi.String("/healthz"),
+		Port: pulumi.Int(gatewayPort),
+	}
+
+	// Create a deployment for the gateway, it is stateless so it can run several replicas
+	_, err = appsv1.NewDeployment(ctx, name, &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(gateway.Replicas),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+					// roll the pods when the allowlist changes
+					Annotations: pulumi.StringMap{
+						"swannynode/config-hash": pulumi.String(configHash(config)),
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String(name),
+							Image: pulumi.String(gateway.Image),
+							Args: pulumi.StringArray{
+								pulumi.String("-config"),
+								pulumi.Sprintf("%s/%s", gatewayConfigDir, gatewayConfigFile),
+							},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(gatewayPort),
+								},
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("metrics"),
+									ContainerPort: pulumi.Int(gatewayMetricsPort),
+								},
+							},
+							VolumeMounts: volumeMounts,
+							ReadinessProbe: &corev1.ProbeArgs{
+								HttpGet:       health,
+								PeriodSeconds: pulumi.Int(5),
+							},
+							LivenessProbe: &corev1.ProbeArgs{
+								HttpGet:       health,
+								PeriodSeconds: pulumi.Int(15),
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("50m"),
+									"memory": pulumi.String("64Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("256Mi"),
+								},
+							},
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a headless service so prometheus discovers and scrapes every
+	// gateway replica, each one keeps its own per key counters
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-metrics", name), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:  labels,
+			ClusterIP: pulumi.String("None"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(gatewayMetricsPort),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-metrics", name),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a service for the public ingress to route to the gateway
+	return corev1.NewService(ctx, fmt.Sprintf("%s-service", name), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: labels,
+			Type:     pulumi.String("NodePort"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port:       pulumi.Int(gatewayPort),
+					TargetPort: pulumi.Int(gatewayPort),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-service", name),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+}
@@ -0,0 +1,103 @@
+package ethereumNode
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// newRpcIngress exposes the public rpc service, either the execution client
+// or the gateway in front of it, through an ALB and points the public
+// hostname at it. It returns the dns record and the ALB hostname.
+func newRpcIngress(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, elName string, service *corev1.Service, port int) (*route53.Record, pulumi.StringOutput, error) {
+
+	annotations := pulumi.StringMap{
+		"kubernetes.io/ingress.class":                    pulumi.String("alb"),
+		"alb.ingress.kubernetes.io/scheme":               pulumi.String("internet-facing"),
+		"alb.ingress.kubernetes.io/target-type":          pulumi.String("instance"),
+		"alb.ingress.kubernetes.io/certificate-arn":      pulumi.String(args.TlsCertArn),
+		"alb.ingress.kubernetes.io/listen-ports":         pulumi.String(`[{"HTTP": 80}, {"HTTPS":443}]`),
+		"alb.ingress.kubernetes.io/actions.ssl-redirect": pulumi.String(`{"Type": "redirect", "RedirectConfig": { "Protocol": "HTTPS", "Port": "443", "StatusCode": "HTTP_301"}}`),
+	}
+	// the gateway only answers POST on its routes, so the ALB's default GET /
+	// health check gets a 405 and marks every target unhealthy. Check the
+	// gateway's /healthz on the port traffic is forwarded to, which is the
+	// node port of the gateway service with instance targets.
+	if args.RpcGateway != nil {
+		annotations["alb.ingress.kubernetes.io/healthcheck-path"] = pulumi.String("/healthz")
+		annotations["alb.ingress.kubernetes.io/healthcheck-port"] = pulumi.String("traffic-port")
+		annotations["alb.ingress.kubernetes.io/success-codes"] = pulumi.String("200")
+	}
+
+	// Create an ingress for the rpc service
+	rpcIngress, err := networkingv1.NewIngress(ctx, fmt.Sprintf("%s-ingress", elName), &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:        pulumi.Sprintf("%s-%s-ingress", elName, args.Network),
+			Namespace:   pulumi.String(args.Namespace),
+			Annotations: annotations,
+		},
+		Spec: &networkingv1.IngressSpecArgs{
+			Rules: &networkingv1.IngressRuleArray{
+				&networkingv1.IngressRuleArgs{
+					Host: pulumi.String(args.PublicHostname),
+					Http: &networkingv1.HTTPIngressRuleValueArgs{
+						Paths: &networkingv1.HTTPIngressPathArray{
+							&networkingv1.HTTPIngressPathArgs{
+								Path:     pulumi.String("/"),
+								PathType: pulumi.String("Prefix"),
+								Backend: &networkingv1.IngressBackendArgs{
+									Service: &networkingv1.IngressServiceBackendArgs{
+										Name: service.Metadata.Name().Elem(),
+										Port: &networkingv1.ServiceBackendPortArgs{
+											Number: pulumi.Int(port),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "grafana-ingress", pulumi.DependsOn([]pulumi.Resource{service}))...)
+	if err != nil {
+		return nil, pulumi.StringOutput{}, err
+	}
+
+	lbHostname := ingressHostname(rpcIngress)
+
+	// create route53 record for the rpc endpoint
+	rpcDns, err := route53.NewRecord(ctx, fmt.Sprintf("%s-dns", elName), &route53.RecordArgs{
+		ZoneId: pulumi.String(args.ZoneId),
+		Name:   pulumi.String(args.PublicHostname),
+		Records: pulumi.StringArray{
+			lbHostname,
+		},
+		Ttl:  pulumi.Int(300),
+		Type: pulumi.String("CNAME"),
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, pulumi.StringOutput{}, err
+	}
+
+	return rpcDns, lbHostname, nil
+}
+
+// ingressHostname is the hostname of the ALB the load balancer controller created for ingress
+func ingressHostname(ingress *networkingv1.Ingress) pulumi.StringOutput {
+	return ingress.Status.ApplyT(func(status *networkingv1.IngressStatus) (string, error) {
+		// status.LoadBalancer could be nil or have an empty Ingress slice.
+		if status.LoadBalancer == nil || len(status.LoadBalancer.Ingress) == 0 {
+			return "", fmt.Errorf("no ingress load balancer information found")
+		}
+		// Return the hostname of the load balancer, make sure to handle possible nil values.
+		if status.LoadBalancer.Ingress[0].Hostname != nil {
+			return *status.LoadBalancer.Ingress[0].Hostname, nil
+		}
+		return "", fmt.Errorf("load balancer ingress hostname is nil")
+	}).(pulumi.StringOutput)
+}
@@ -0,0 +1,161 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// jwtHashAnnotation is set on the pod templates of both clients, so a new
+// jwt replaces the execution and consensus pods in the same update. In
+// co-located mode both clients share the pod and restart together. Otherwise
+// the two statefulsets roll independently, so the jwt rotation job stops both
+// before either rolls out and the engine api never runs with one client on
+// the old secret.
+const jwtHashAnnotation = "swannynode/jwt-hash"
+
+// jwtRotationScript scales down every statefulset whose pods still run with
+// another jwt and waits for their pods to be gone. The statefulset updates
+// that follow scale them back up on the new jwt. A statefulset already on the
+// jwt, or not created yet, is left alone.
+const jwtRotationScript = `set -eu
+stopped=""
+for set in $SETS; do
+	current=$(kubectl get statefulset "$set" --ignore-not-found -o jsonpath='{.spec.template.metadata.annotations.swannynode/jwt-hash}')
+	if [ -n "$current" ] && [ "$current" != "$JWT_HASH" ]; then
+		echo "stopping $set for the jwt rotation"
+		kubectl scale statefulset "$set" --replicas=0
+		stopped="$stopped $set"
+	fi
+done
+for set in $stopped; do
+	while [ -n "$(kubectl get pods -l app="$set" -o name)" ]; do
+		sleep 2
+	done
+	echo "$set stopped"
+done
+`
+
+// engineJwt is the secret shared by the execution and consensus clients to
+// authenticate the engine api
+type engineJwt struct {
+	secret *corev1.Secret
+	// value is the hex encoded jwt, always a pulumi secret
+	value pulumi.StringOutput
+	// hash identifies the jwt without revealing it, for pod annotations
+	hash pulumi.StringOutput
+	// rotation is the job stopping both clients before a new jwt rolls out,
+	// nil in co-located mode
+	rotation pulumi.Resource
+}
+
+// newEngineJwt creates the kubernetes secret holding the engine api jwt. The
+// jwt is generated as 32 random bytes unless args.ExecutionJwt overrides it,
+// changing args.JwtRotation generates a new one.
+func newEngineJwt(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs) (*engineJwt, error) {
+	var value pulumi.StringOutput
+	if args.ExecutionJwt != nil {
+		value = pulumi.ToSecret(args.ExecutionJwt).(pulumi.StringOutput)
+	} else {
+		generated, err := random.NewRandomId(ctx, "execution-jwt", &random.RandomIdArgs{
+			ByteLength: pulumi.Int(32),
+			Keepers: pulumi.Map{
+				"rotation": pulumi.String(args.JwtRotation),
+			},
+		}, pulumi.Parent(component), pulumi.AdditionalSecretOutputs([]string{"hex", "b64Std", "b64Url", "dec"}))
+		if err != nil {
+			return nil, err
+		}
+		value = pulumi.ToSecret(generated.Hex).(pulumi.StringOutput)
+	}
+
+	// Create a secret for the execution jwt
+	secret, err := corev1.NewSecret(ctx, "execution-jwt", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Namespace: pulumi.String(args.Namespace),
+		},
+		StringData: pulumi.StringMap{
+			"jwt.hex": value,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := pulumi.Unsecret(value.ApplyT(func(jwt string) string {
+		return configHash(jwt)
+	})).(pulumi.StringOutput)
+
+	return &engineJwt{secret: secret, value: value, hash: hash}, nil
+}
+
+// newJwtRotation creates the job stopping the statefulsets of sets before
+// they roll out a new jwt. The job is replaced whenever the jwt changes, and
+// the statefulsets depend on it. Its scale down is undone by the statefulset
+// updates, which force their replica count back.
+func newJwtRotation(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, sets []nodeSet, jwt *engineJwt) (pulumi.Resource, error) {
+	names := make([]string, 0, len(sets))
+	for _, set := range sets {
+		names = append(names, set.name)
+	}
+	name := fmt.Sprintf("%s-jwt-rotation", pairName(args))
+	serviceAccount, err := newNodeServiceAccount(ctx, component, args, name, rbacv1.PolicyRuleArray{
+		rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("apps")},
+			Resources:     pulumi.StringArray{pulumi.String("statefulsets"), pulumi.String("statefulsets/scale")},
+			ResourceNames: pulumi.ToStringArray(names),
+			Verbs:         pulumi.ToStringArray([]string{"get", "patch"}),
+		},
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("pods")},
+			Verbs:     pulumi.ToStringArray([]string{"list"}),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := childOpts(component, "",
+		pulumi.ReplaceOnChanges([]string{"spec.template"}),
+		pulumi.DeleteBeforeReplace(true),
+	)
+	return batchv1.NewJob(ctx, name, &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: pulumi.String(args.Namespace),
+		},
+		Spec: &batchv1.JobSpecArgs{
+			BackoffLimit:          pulumi.Int(3),
+			ActiveDeadlineSeconds: pulumi.Int(900),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Annotations: pulumi.StringMap{
+						jwtHashAnnotation: jwt.hash,
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount,
+					RestartPolicy:      pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("stop-clients"),
+							Image:   pulumi.String(args.KubectlImage),
+							Command: pulumi.ToStringArray([]string{"/bin/sh", "-c", jwtRotationScript}),
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{Name: pulumi.String("SETS"), Value: pulumi.String(strings.Join(names, " "))},
+								corev1.EnvVarArgs{Name: pulumi.String("JWT_HASH"), Value: jwt.hash},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, opts...)
+}
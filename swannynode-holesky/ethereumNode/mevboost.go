@@ -0,0 +1,199 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// MevBoostConfig runs mev-boost next to the node and points the consensus
+// client's builder api at it, so attached validators can propose blocks
+// built by the relays of the node's network. The consensus client falls back
+// to building blocks locally after too many missed slots, and whenever
+// mev-boost or the relays have nothing to offer.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:mevBoost:
+//	  relays:
+//	    holesky:
+//	      - https://0xafa4c6985aa049fb79dd37010438cfebeb0f2bd42b115b89dd678dab0670c1de38da0c4e9138c9290a398ecd9a0b3110@boost-relay-holesky.flashbots.net
+//	    mainnet:
+//	      - https://0xac6e77dfe25ecd6110b8e780608cce0dab71fdd5ebea22a16c0205200f2f8e2e3ad3b71d3499c54ad14d6c21b41a37ae@boost-relay.flashbots.net
+//	  minBid: "0.01"
+type MevBoostConfig struct {
+	// Image is the mev-boost image, main sets it from the image manifest
+	Image    string `json:"image"`
+	Replicas int    `json:"replicas"`
+	// Relays are the relay urls of each network, with the relay public key as the user
+	Relays map[Network][]string `json:"relays"`
+	// MinBid is the smallest bid in eth taken from a relay, lower bids build the block locally
+	MinBid string `json:"minBid"`
+	// FallbackConsecutiveMissed and FallbackEpochMissed are the missed slots,
+	// in a row and in the last epoch, after which the consensus client stops
+	// asking the builder until the chain recovers
+	FallbackConsecutiveMissed int `json:"fallbackConsecutiveMissed"`
+	FallbackEpochMissed       int `json:"fallbackEpochMissed"`
+}
+
+const (
+	mevBoostName                     = "mev-boost"
+	mevBoostPort                     = 18550
+	mevBoostMetricsPort              = 18551
+	defaultMevBoostReplicas          = 1
+	defaultMevBoostConsecutiveMissed = 3
+	defaultMevBoostEpochMissed       = 8
+)
+
+func (c *MevBoostConfig) validate(network Network) error {
+	if c.Image == "" {
+		return fmt.Errorf("mevBoost image is required")
+	}
+	if len(c.Relays[network]) == 0 {
+		return fmt.Errorf("mevBoost has no relays for %s", network)
+	}
+	for _, relay := range c.Relays[network] {
+		if !strings.HasPrefix(relay, "https://0x") || !strings.Contains(relay, "@") {
+			return fmt.Errorf("mevBoost relay %q must be https://<relay public key>@<host>", relay)
+		}
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultMevBoostReplicas
+	}
+	if c.FallbackConsecutiveMissed == 0 {
+		c.FallbackConsecutiveMissed = defaultMevBoostConsecutiveMissed
+	}
+	if c.FallbackEpochMissed == 0 {
+		c.FallbackEpochMissed = defaultMevBoostEpochMissed
+	}
+	if c.Replicas < 0 || c.FallbackConsecutiveMissed < 0 || c.FallbackEpochMissed < 0 {
+		return fmt.Errorf("mevBoost replicas, fallbackConsecutiveMissed and fallbackEpochMissed cannot be negative")
+	}
+	return nil
+}
+
+// mevBoostEndpoint is where the consensus client reaches the builder api of mev-boost
+func mevBoostEndpoint(args *EthereumNodeComponentArgs) string {
+	return fmt.Sprintf("http://%s.%s:%d", mevBoostName, args.Namespace, mevBoostPort)
+}
+
+// newMevBoost creates the mev-boost deployment relaying builder api calls
+// of the consensus client to the relays of the node's network
+func newMevBoost(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs) error {
+	mevBoost := args.MevBoost
+	namespace := pulumi.String(args.Namespace)
+	labels := pulumi.StringMap{"app": pulumi.String(mevBoostName)}
+
+	// the image entrypoint is mev-boost itself
+	flags := []string{
+		fmt.Sprintf("-%s", args.Network),
+		"-addr", fmt.Sprintf("0.0.0.0:%d", mevBoostPort),
+		"-relays", strings.Join(mevBoost.Relays[args.Network], ","),
+		"-metrics",
+		"-metrics-addr", fmt.Sprintf("0.0.0.0:%d", mevBoostMetricsPort),
+	}
+	if mevBoost.MinBid != "" {
+		flags = append(flags, "-min-bid", mevBoost.MinBid)
+	}
+
+	// Create a deployment for mev-boost, it is stateless so it can run several replicas
+	_, err := appsv1.NewDeployment(ctx, mevBoostName, &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(mevBoostName),
+			Namespace: namespace,
+		},
+		Spec: &appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(mevBoost.Replicas),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String(mevBoostName),
+							Image: pulumi.String(mevBoost.Image),
+							Args:  pulumi.ToStringArray(flags),
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(mevBoostPort),
+								},
+								corev1.ContainerPortArgs{
+									Name:          pulumi.String("metrics"),
+									ContainerPort: pulumi.Int(mevBoostMetricsPort),
+								},
+							},
+							// the status is only ok while at least one relay answers
+							ReadinessProbe: &corev1.ProbeArgs{
+								HttpGet: &corev1.HTTPGetActionArgs{
+									Path: pulumi.String("/eth/v1/builder/status"),
+									Port: pulumi.Int(mevBoostPort),
+								},
+								PeriodSeconds: pulumi.Int(15),
+							},
+							Resources: &corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{
+									"cpu":    pulumi.String("50m"),
+									"memory": pulumi.String("64Mi"),
+								},
+								Limits: pulumi.StringMap{
+									"memory": pulumi.String("256Mi"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+
+	// Create a service for the consensus client to reach the builder api
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-service", mevBoostName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector: labels,
+			Type:     pulumi.String("ClusterIP"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(mevBoostPort),
+					Name: pulumi.String("builder"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(mevBoostName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return err
+	}
+
+	// Create a headless service so prometheus discovers and scrapes every mev-boost replica
+	_, err = corev1.NewService(ctx, fmt.Sprintf("%s-metrics", mevBoostName), &corev1.ServiceArgs{
+		Spec: &corev1.ServiceSpecArgs{
+			Selector:  labels,
+			ClusterIP: pulumi.String("None"),
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					Port: pulumi.Int(mevBoostMetricsPort),
+					Name: pulumi.String("metrics"),
+				},
+			},
+		},
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-metrics", mevBoostName),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	return err
+}
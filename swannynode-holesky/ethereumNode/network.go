@@ -0,0 +1,38 @@
+package ethereumNode
+
+import "fmt"
+
+// Network is the ethereum network a node joins. Its value is passed verbatim
+// to the clients (`--chain` for reth, `--network` for lighthouse) and is used
+// as the datadir suffix.
+type Network string
+
+const (
+	Mainnet Network = "mainnet"
+	Sepolia Network = "sepolia"
+	Holesky Network = "holesky"
+	Hoodi   Network = "hoodi"
+)
+
+// default checkpoint sync endpoints for each supported network
+var checkpointSyncUrls = map[Network]string{
+	Mainnet: "https://mainnet.checkpoint.sigp.io/",
+	Sepolia: "https://checkpoint-sync.sepolia.ethpandaops.io/",
+	Holesky: "https://holesky.checkpoint.sigp.io/",
+	Hoodi:   "https://checkpoint-sync.hoodi.ethpandaops.io/",
+}
+
+// ParseNetwork converts a stack config value into a Network, returning an
+// error for networks the node program does not know how to deploy.
+func ParseNetwork(name string) (Network, error) {
+	network := Network(name)
+	if _, ok := checkpointSyncUrls[network]; !ok {
+		return "", fmt.Errorf("unsupported network %q, expected one of mainnet, sepolia, holesky or hoodi", name)
+	}
+	return network, nil
+}
+
+// CheckpointSyncUrl returns the default checkpoint sync endpoint for the network.
+func (n Network) CheckpointSyncUrl() string {
+	return checkpointSyncUrls[n]
+}
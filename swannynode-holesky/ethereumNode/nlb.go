@@ -0,0 +1,116 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// p2pAddressesName names the ConfigMap holding the advertised address of every replica of set
+func p2pAddressesName(set nodeSet) string {
+	return fmt.Sprintf("%s-p2p-addresses", set.name)
+}
+
+// newP2pLoadBalancers gives every replica of set a network load balancer of
+// its own for the p2p ports of its clients, through the aws load balancer
+// controller installed by the cluster program. Each load balancer holds an
+// elastic ip per subnet, created here so they survive the load balancer being
+// replaced. It returns the address each pod advertises, by pod name.
+func newP2pLoadBalancers(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, set nodeSet) (pulumi.StringMap, error) {
+	p2p := args.P2p
+	namespace := pulumi.String(args.Namespace)
+
+	ports := corev1.ServicePortArray{}
+	for _, part := range set.parts {
+		ports = append(ports, part.p2pServicePorts...)
+	}
+	// every target group is health checked on a tcp port that is up while the
+	// node syncs, udp only listeners have nothing to check of their own
+	healthCheckPort := args.Ports.ExecutionP2P
+	if set.parts[0].kind == consensusKind {
+		healthCheckPort = args.Ports.ConsensusP2P
+	}
+
+	addresses := pulumi.StringMap{}
+	for ordinal := 0; ordinal <= lastOrdinal(args); ordinal++ {
+		pod := fmt.Sprintf("%s-%d", set.name, ordinal)
+		name := fmt.Sprintf("%s-p2p", pod)
+
+		// Create an elastic ip in each subnet of the load balancer
+		allocationIds := pulumi.Array{}
+		for i, subnet := range p2p.Subnets {
+			eip, err := ec2.NewEip(ctx, fmt.Sprintf("%s-%d", name, i), &ec2.EipArgs{
+				Domain: pulumi.String("vpc"),
+				Tags: pulumi.StringMap{
+					"Name":   pulumi.String(name),
+					"Subnet": pulumi.String(subnet),
+				},
+			}, childOpts(component, "")...)
+			if err != nil {
+				return nil, err
+			}
+			allocationIds = append(allocationIds, eip.AllocationId)
+			// peers are told about the first address, cross zone balancing
+			// gets them to the pod whichever zone it runs in
+			if i == 0 {
+				addresses[pod] = eip.PublicIp
+			}
+		}
+		eipAllocations := allocationIds.ToArrayOutput().ApplyT(func(ids []interface{}) string {
+			joined := make([]string, len(ids))
+			for i, id := range ids {
+				joined[i] = id.(string)
+			}
+			return strings.Join(joined, ",")
+		}).(pulumi.StringOutput)
+
+		// Create a load balancer service for the p2p ports of the replica, peers
+		// must reach the clients while they are still syncing
+		_, err := corev1.NewService(ctx, name, &corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(name),
+				Namespace: namespace,
+				Annotations: pulumi.StringMap{
+					"service.beta.kubernetes.io/aws-load-balancer-type":                    pulumi.String("external"),
+					"service.beta.kubernetes.io/aws-load-balancer-nlb-target-type":         pulumi.String("ip"),
+					"service.beta.kubernetes.io/aws-load-balancer-scheme":                  pulumi.String("internet-facing"),
+					"service.beta.kubernetes.io/aws-load-balancer-subnets":                 pulumi.String(strings.Join(p2p.Subnets, ",")),
+					"service.beta.kubernetes.io/aws-load-balancer-eip-allocations":         eipAllocations,
+					"service.beta.kubernetes.io/aws-load-balancer-attributes":              pulumi.String("load_balancing.cross_zone.enabled=true"),
+					"service.beta.kubernetes.io/aws-load-balancer-target-group-attributes": pulumi.String("preserve_client_ip.enabled=true"),
+					"service.beta.kubernetes.io/aws-load-balancer-healthcheck-protocol":    pulumi.String("TCP"),
+					"service.beta.kubernetes.io/aws-load-balancer-healthcheck-port":        pulumi.Sprintf("%d", healthCheckPort),
+				},
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Type: pulumi.String("LoadBalancer"),
+				Selector: pulumi.StringMap{
+					"statefulset.kubernetes.io/pod-name": pulumi.String(pod),
+				},
+				Ports:                    ports,
+				PublishNotReadyAddresses: pulumi.Bool(true),
+			},
+		}, childOpts(component, "")...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create a ConfigMap with the address of every replica, the external-ip
+	// init container reads the one of its pod from it
+	_, err := corev1.NewConfigMap(ctx, p2pAddressesName(set), &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(p2pAddressesName(set)),
+			Namespace: namespace,
+		},
+		Data: addresses,
+	}, childOpts(component, "")...)
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
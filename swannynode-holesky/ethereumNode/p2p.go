@@ -0,0 +1,301 @@
+package ethereumNode
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"swannynode-mainnet/consensusClient"
+)
+
+// P2pConfig makes the clients reachable by their peers. The p2p ports are
+// bound on the node itself, as host ports or with the host network, and both
+// clients advertise the node's public address in their enode and enr
+// records. The node security group has to allow the p2p ports in.
+//
+// The public address is discovered per pod when it starts, either from the
+// ExternalIP in the node status or from the instance metadata service, and
+// written to a file shared with the clients, whose commands are wrapped in a
+// shell reading it. Prysm's image has no shell, it needs a fixed ExternalIp.
+// Reaching the metadata service from the pod network needs a hop limit of 2.
+//
+// In loadBalancer mode every replica is reached through a network load
+// balancer of its own instead, holding an elastic ip in each of Subnets, and
+// advertises the first of them. The addresses outlive the pods, so the enode
+// and enr stay the same wherever a replica is rescheduled.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:p2p:
+//	  mode: hostPort
+//	  ipSource: node
+//
+//	swannynode-mainnet:p2p:
+//	  mode: loadBalancer
+//	  subnets: [subnet-0a1b2c, subnet-3d4e5f]
+type P2pConfig struct {
+	// Mode is hostPort, binding only the p2p ports on the node, hostNetwork or loadBalancer
+	Mode string `json:"mode"`
+	// IpSource is node or imds, it is ignored when ExternalIp is set
+	IpSource string `json:"ipSource"`
+	// Subnets are the public subnets of the load balancers in loadBalancer mode
+	Subnets []string `json:"subnets"`
+	// ExternalIp is a fixed public address to advertise, e.g. an elastic ip of the node the clients are pinned to
+	ExternalIp string `json:"externalIp"`
+	// Image runs the address discovery, it needs sh and curl. main sets it to the pinned curl image.
+	Image string `json:"image"`
+}
+
+// Modes of binding the p2p ports on the node
+const (
+	P2pHostPort     = "hostPort"
+	P2pHostNetwork  = "hostNetwork"
+	P2pLoadBalancer = "loadBalancer"
+)
+
+// Sources of the public address of a node
+const (
+	P2pIpFromNode         = "node"
+	P2pIpFromImds         = "imds"
+	P2pIpFromLoadBalancer = "loadBalancer"
+)
+
+const (
+	p2pAddressesDir  = "/etc/swannynode/p2p-addresses"
+	externalIpVolume = "external-ip"
+	externalIpDir    = "/etc/swannynode/external-ip"
+	externalIpFile   = externalIpDir + "/address"
+	// externalIpPlaceholder stands in for the discovered address in the
+	// client commands until the wrapper replaces it
+	externalIpPlaceholder = "@EXTERNAL_IP@"
+)
+
+func (c *P2pConfig) validate(args *EthereumNodeComponentArgs) error {
+	if c.Mode == "" {
+		c.Mode = P2pHostPort
+	}
+	if c.Mode != P2pHostPort && c.Mode != P2pHostNetwork && c.Mode != P2pLoadBalancer {
+		return fmt.Errorf("p2p mode %q must be %s, %s or %s", c.Mode, P2pHostPort, P2pHostNetwork, P2pLoadBalancer)
+	}
+	if c.Mode == P2pLoadBalancer {
+		if len(c.Subnets) == 0 {
+			return fmt.Errorf("p2p subnets are required for the load balancers")
+		}
+		if c.ExternalIp != "" || (c.IpSource != "" && c.IpSource != P2pIpFromLoadBalancer) {
+			return fmt.Errorf("p2p loadBalancer mode advertises the load balancer addresses, unset externalIp and ipSource")
+		}
+		c.IpSource = P2pIpFromLoadBalancer
+	} else if len(c.Subnets) > 0 {
+		return fmt.Errorf("p2p subnets only apply to loadBalancer mode")
+	}
+	if c.ExternalIp != "" {
+		if args.Replicas > 1 {
+			return fmt.Errorf("p2p externalIp can only be advertised by a single replica, use an ipSource instead")
+		}
+		return nil
+	}
+	if args.ConsensusClient == consensusClient.Prysm {
+		return fmt.Errorf("prysm cannot read a discovered public address, its image has no shell, set p2p externalIp")
+	}
+	if c.IpSource == "" {
+		c.IpSource = P2pIpFromNode
+	}
+	if c.Mode != P2pLoadBalancer && c.IpSource != P2pIpFromNode && c.IpSource != P2pIpFromImds {
+		return fmt.Errorf("p2p ipSource %q must be %s or %s", c.IpSource, P2pIpFromNode, P2pIpFromImds)
+	}
+	if c.Image == "" {
+		return fmt.Errorf("p2p image is required to discover the public address")
+	}
+	return nil
+}
+
+// discovers is true when the public address is discovered at runtime rather than configured
+func (c *P2pConfig) discovers() bool {
+	return c.ExternalIp == ""
+}
+
+// externalIp returns the address a client advertises and the volume mounts
+// it is read from, nothing when p2p is not configured. A discovered address
+// is a placeholder the command wrapper fills in.
+func externalIp(args *EthereumNodeComponentArgs) (string, corev1.VolumeMountArray) {
+	p2p := args.P2p
+	if p2p == nil {
+		return "", nil
+	}
+	if !p2p.discovers() {
+		return p2p.ExternalIp, nil
+	}
+	return externalIpPlaceholder, corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String(externalIpVolume),
+			MountPath: pulumi.String(externalIpDir),
+			ReadOnly:  pulumi.Bool(true),
+		},
+	}
+}
+
+// externalIpWrapper reads the discovered address and replaces the
+// placeholder ending any argument of the client command with it. Every
+// client takes the address at the end of a flag, e.g. extip:<ip>.
+const externalIpWrapper = `set -eu
+ip="$(cat "` + externalIpFile + `")"
+for arg do
+  shift
+  case "$arg" in
+  *` + externalIpPlaceholder + `) arg="${arg%` + externalIpPlaceholder + `}$ip" ;;
+  esac
+  set -- "$@" "$arg"
+done
+exec "$@"
+`
+
+// advertisingCommand wraps command in the shell filling in the discovered
+// address, commands of nodes with a fixed address or without p2p run as is
+func advertisingCommand(args *EthereumNodeComponentArgs, command []string) []string {
+	if args.P2p == nil || !args.P2p.discovers() {
+		return command
+	}
+	return append([]string{"sh", "-c", externalIpWrapper, "sh"}, command...)
+}
+
+// p2pHostPort returns the host port a p2p container port is bound to, in host port mode
+func p2pHostPort(args *EthereumNodeComponentArgs, port int) pulumi.IntPtrInput {
+	if args.P2p == nil || args.P2p.Mode != P2pHostPort {
+		return nil
+	}
+	return pulumi.Int(port)
+}
+
+// externalIpScript finds the public address of the node the pod runs on and
+// writes it to the file the clients read it from. The clients only start
+// after it succeeds, a node without a public address fails the pod rather
+// than advertising nothing.
+const externalIpScript = `set -eu
+sa=/var/run/secrets/kubernetes.io/serviceaccount
+api() {
+  curl -sf -m 10 --cacert "$sa/ca.crt" -H "Authorization: Bearer $(cat "$sa/token")" \
+    "https://kubernetes.default.svc$1"
+}
+case "$IP_SOURCE" in
+loadBalancer)
+  ip="$(cat "$ADDRESSES/$POD_NAME" 2>/dev/null || true)"
+  ;;
+imds)
+  token="$(curl -sf -m 5 -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token)"
+  ip="$(curl -sf -m 5 -H "X-aws-ec2-metadata-token: $token" http://169.254.169.254/latest/meta-data/public-ipv4 || true)"
+  ;;
+*)
+  ip="$(api "/api/v1/nodes/$NODE_NAME" | tr -d ' \n' |
+    sed -n 's/.*{"type":"ExternalIP","address":"\([^"]*\)"}.*/\1/p')"
+  ;;
+esac
+if [ -z "$ip" ]; then
+  echo "no public address for $POD_NAME on node $NODE_NAME from $IP_SOURCE" >&2
+  exit 1
+fi
+# written whole before the rename, the clients never see a partial address
+echo "$ip" > "$EXTERNAL_IP_FILE.tmp"
+mv "$EXTERNAL_IP_FILE.tmp" "$EXTERNAL_IP_FILE"
+echo "advertising $ip"
+`
+
+// externalIpContainer is the init container writing the public address of the pod
+func externalIpContainer(args *EthereumNodeComponentArgs) corev1.ContainerArgs {
+	volumeMounts := corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String(externalIpVolume),
+			MountPath: pulumi.String(externalIpDir),
+		},
+	}
+	if args.P2p.Mode == P2pLoadBalancer {
+		volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String("p2p-addresses"),
+			MountPath: pulumi.String(p2pAddressesDir),
+		})
+	}
+	fieldEnv := func(name, fieldPath string) corev1.EnvVarArgs {
+		return corev1.EnvVarArgs{
+			Name: pulumi.String(name),
+			ValueFrom: &corev1.EnvVarSourceArgs{
+				FieldRef: &corev1.ObjectFieldSelectorArgs{FieldPath: pulumi.String(fieldPath)},
+			},
+		}
+	}
+	return corev1.ContainerArgs{
+		Name:    pulumi.String("external-ip"),
+		Image:   pulumi.String(args.P2p.Image),
+		Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String(externalIpScript)},
+		Env: corev1.EnvVarArray{
+			corev1.EnvVarArgs{Name: pulumi.String("IP_SOURCE"), Value: pulumi.String(args.P2p.IpSource)},
+			corev1.EnvVarArgs{Name: pulumi.String("ADDRESSES"), Value: pulumi.String(p2pAddressesDir)},
+			corev1.EnvVarArgs{Name: pulumi.String("EXTERNAL_IP_FILE"), Value: pulumi.String(externalIpFile)},
+			fieldEnv("NODE_NAME", "spec.nodeName"),
+			fieldEnv("POD_NAME", "metadata.name"),
+		},
+		VolumeMounts: volumeMounts,
+	}
+}
+
+// readsNode is true when the public address is read from the node status,
+// the only source needing api access
+func (c *P2pConfig) readsNode() bool {
+	return c.discovers() && c.IpSource == P2pIpFromNode
+}
+
+// newP2pServiceAccount creates the service account of the pods of set,
+// allowed to read the node they run on
+func newP2pServiceAccount(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, set nodeSet) (pulumi.StringOutput, error) {
+	name := fmt.Sprintf("%s-p2p", set.name)
+	account, err := corev1.NewServiceAccount(ctx, name, &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: pulumi.String(args.Namespace),
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	serviceAccount := account.Metadata.Name().Elem()
+
+	// nodes are cluster scoped
+	clusterName := fmt.Sprintf("%s-%s", args.Namespace, name)
+	clusterRole, err := rbacv1.NewClusterRole(ctx, clusterName, &rbacv1.ClusterRoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(clusterName),
+		},
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.StringArray{pulumi.String("nodes")},
+				Verbs:     pulumi.StringArray{pulumi.String("get")},
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	_, err = rbacv1.NewClusterRoleBinding(ctx, clusterName, &rbacv1.ClusterRoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(clusterName),
+		},
+		RoleRef: &rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount,
+				Namespace: pulumi.String(args.Namespace),
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	return serviceAccount, nil
+}
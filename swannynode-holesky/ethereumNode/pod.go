@@ -0,0 +1,274 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// podParts are the containers and volumes one client contributes to a pod.
+// The jwt volume and data volume claim are added by the statefulset they end up in.
+type podParts struct {
+	// client is the client name and kind is execution or consensus
+	client         string
+	kind           string
+	initContainers corev1.ContainerArray
+	containers     corev1.ContainerArray
+	volumes        corev1.VolumeArray
+	// dataVolume is the name of the volume holding the client database, and
+	// of its claim or claim template. dataClaimResource is the pulumi name of
+	// the named claim outside co-located mode.
+	dataVolume        string
+	dataClaimResource string
+	storageSize       string
+	// ports are the container ports the client listens on, by what they serve
+	ports []containerPort
+	// p2pServicePorts are the ports peers reach the client on, named after
+	// the kind of client so the ports of both clients of a pod fit one service
+	p2pServicePorts corev1.ServicePortArray
+	// p2pPorts are the same ports as port/protocol
+	p2pPorts []string
+}
+
+// containerPort is a port a client listens on, as port/protocol, and what it
+// serves, like rpc or metrics
+type containerPort struct {
+	name string
+	port string
+}
+
+// addPort records a container port so port clashes between sibling containers are caught
+func (p *podParts) addPort(name string, port int, protocol string) {
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	p.ports = append(p.ports, containerPort{name: name, port: fmt.Sprintf("%d/%s", port, protocol)})
+}
+
+// Kinds of client a pod part runs
+const (
+	executionKind = "execution"
+	consensusKind = "consensus"
+)
+
+// nodeSet is a statefulset and the clients it runs
+type nodeSet struct {
+	name   string
+	labels pulumi.StringMap
+	parts  []*podParts
+	// claimTemplates creates a data volume claim per ordinal, otherwise the
+	// single replica mounts the named claim of each client
+	claimTemplates bool
+}
+
+// nodeSets returns the statefulsets running the clients, one per client or one for the pair
+func nodeSets(args *EthereumNodeComponentArgs, execution *executionResources, consensus *consensusResources) []nodeSet {
+	if args.CoLocated {
+		return []nodeSet{{name: pairName(args), labels: execution.labels, parts: []*podParts{execution.pod, consensus.pod}, claimTemplates: true}}
+	}
+	return []nodeSet{
+		{name: execution.client.Name(), labels: execution.labels, parts: []*podParts{execution.pod}},
+		{name: consensus.client.Name(), labels: consensus.labels, parts: []*podParts{consensus.pod}},
+	}
+}
+
+// lastOrdinal is the ordinal of the statefulset replica that is removed first when scaling down
+func lastOrdinal(args *EthereumNodeComponentArgs) int {
+	if args.CoLocated {
+		return args.Replicas - 1
+	}
+	return 0
+}
+
+// claimName is the volume claim holding the data volume of part at ordinal,
+// <template>-<statefulset>-<ordinal> when set creates it from a template
+func claimName(part *podParts, set nodeSet, ordinal int) string {
+	if !set.claimTemplates {
+		return part.dataVolume
+	}
+	return fmt.Sprintf("%s-%s-%d", part.dataVolume, set.name, ordinal)
+}
+
+// nodeLabels returns the pod labels of a client, both clients share the
+// labels of their pod in co-located mode so every service selects the pair
+func nodeLabels(args *EthereumNodeComponentArgs, clientName string) pulumi.StringMap {
+	if args.CoLocated {
+		return pulumi.StringMap{"app": pulumi.String(pairName(args))}
+	}
+	return pulumi.StringMap{"app": pulumi.String(clientName)}
+}
+
+// pairName names the statefulset running both clients in co-located mode
+func pairName(args *EthereumNodeComponentArgs) string {
+	return fmt.Sprintf("%s-%s", args.ExecutionClient, args.ConsensusClient)
+}
+
+// checkPortClashes fails when two ports of the same pod, of one client or
+// of sibling clients, are the same, naming the clients and ports that clash
+func checkPortClashes(parts []*podParts) error {
+	owners := map[string]string{}
+	var clashes []string
+	for _, part := range parts {
+		for _, port := range part.ports {
+			owner := fmt.Sprintf("%s %s", part.client, port.name)
+			if first, ok := owners[port.port]; ok {
+				clashes = append(clashes, fmt.Sprintf("%s and %s both listen on %s", first, owner, port.port))
+				continue
+			}
+			owners[port.port] = owner
+		}
+	}
+	if len(clashes) > 0 {
+		return fmt.Errorf("%s, change one of them in ports", strings.Join(clashes, "; "))
+	}
+	return nil
+}
+
+// newNodeStatefulSet runs the clients of set in one statefulset. Each client
+// keeps its database on a volume claim, created per ordinal from a claim
+// template in co-located mode so replicas never share a database, and as a
+// single named claim otherwise.
+//
+// Claim templates are immutable. Changing the storage sizes or
+// volumeSnapshotRestore of a co-located node only applies to claims created
+// afterwards: existing claims keep their size and are grown by the volume
+// autoscaler or by editing the claim.
+func newNodeStatefulSet(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, set nodeSet, jwt *engineJwt) error {
+	if err := checkPortClashes(set.parts); err != nil {
+		return err
+	}
+
+	var initContainers corev1.ContainerArrayInput
+	allInitContainers := corev1.ContainerArray{}
+	containers := corev1.ContainerArray{}
+	// both clients read the engine api jwt from the same secret
+	volumes := corev1.VolumeArray{
+		corev1.VolumeArgs{
+			Name: pulumi.String("execution-jwt"),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName: jwt.secret.Metadata.Name(),
+			},
+		},
+	}
+	// the public address has to be known before either client starts advertising it
+	if args.P2p != nil && args.P2p.discovers() {
+		allInitContainers = append(allInitContainers, externalIpContainer(args))
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name:     pulumi.String(externalIpVolume),
+			EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+		})
+	}
+	if args.P2p != nil && args.P2p.Mode == P2pLoadBalancer {
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String("p2p-addresses"),
+			ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+				Name: pulumi.String(p2pAddressesName(set)),
+			},
+		})
+	}
+	var claimTemplates corev1.PersistentVolumeClaimTypeArrayInput
+	templates := corev1.PersistentVolumeClaimTypeArray{}
+	for _, part := range set.parts {
+		allInitContainers = append(allInitContainers, part.initContainers...)
+		containers = append(containers, part.containers...)
+		volumes = append(volumes, part.volumes...)
+		if set.claimTemplates {
+			templates = append(templates, corev1.PersistentVolumeClaimTypeArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name: pulumi.String(part.dataVolume),
+				},
+				Spec: dataVolumeClaimSpec(part.storageSize, part.kind, args),
+			})
+			continue
+		}
+		// Define the PersistentVolumeClaim for the client database
+		if _, err := newDataVolumeClaim(ctx, component, args, part); err != nil {
+			return err
+		}
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String(part.dataVolume),
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSourceArgs{
+				ClaimName: pulumi.String(part.dataVolume),
+			},
+		})
+	}
+	if len(allInitContainers) > 0 {
+		initContainers = allInitContainers
+	}
+	if len(templates) > 0 {
+		claimTemplates = templates
+	}
+
+	var serviceAccount pulumi.StringPtrInput
+	if args.P2p != nil && args.P2p.readsNode() {
+		p2pServiceAccount, err := newP2pServiceAccount(ctx, component, args, set)
+		if err != nil {
+			return err
+		}
+		serviceAccount = p2pServiceAccount
+	}
+	// pods on the host network still resolve cluster services
+	dnsPolicy := "ClusterFirst"
+	var hostNetwork pulumi.BoolPtrInput
+	if args.P2p != nil && args.P2p.Mode == P2pHostNetwork {
+		dnsPolicy = "ClusterFirstWithHostNet"
+		hostNetwork = pulumi.Bool(true)
+	}
+
+	opts := childOpts(component, "")
+	// pulumi.com/patchForce takes the replica count back from the jwt rotation's scale down
+	annotations := pulumi.StringMap{}
+	if jwt.rotation != nil {
+		opts = append(opts, pulumi.DependsOn([]pulumi.Resource{jwt.rotation}))
+		annotations["pulumi.com/patchForce"] = pulumi.String("true")
+	}
+	if set.claimTemplates {
+		// a template change would replace the statefulset and still leave the
+		// existing claims as they are, so template changes are not applied
+		opts = append(opts, pulumi.IgnoreChanges([]string{"spec.volumeClaimTemplates"}))
+	}
+	_, err := appsv1.NewStatefulSet(ctx, fmt.Sprintf("%s-set", set.name), &appsv1.StatefulSetArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:        pulumi.String(set.name),
+			Namespace:   pulumi.String(args.Namespace),
+			Annotations: annotations,
+		},
+		Spec: &appsv1.StatefulSetSpecArgs{
+			Replicas: pulumi.Int(lastOrdinal(args) + 1),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: set.labels,
+			},
+			// pairs are independent nodes, there is no reason to start them one at a time
+			PodManagementPolicy:  podManagementPolicy(args),
+			VolumeClaimTemplates: claimTemplates,
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: set.labels,
+					Annotations: pulumi.StringMap{
+						jwtHashAnnotation: jwt.hash,
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount,
+					InitContainers:     initContainers,
+					Containers:         containers,
+					HostNetwork:        hostNetwork,
+					DnsPolicy:          pulumi.String(dnsPolicy),
+					Volumes:            volumes,
+				},
+			},
+		},
+	}, opts...)
+	return err
+}
+
+func podManagementPolicy(args *EthereumNodeComponentArgs) pulumi.StringPtrInput {
+	if args.CoLocated {
+		return pulumi.String("Parallel")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package ethereumNode
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ProbeConfig tunes the sync-aware health checks of both clients
+type ProbeConfig struct {
+	// Disabled removes every probe and the execution health sidecar
+	Disabled bool `json:"disabled"`
+	// MinPeers is the peer count below which the execution client is not ready
+	MinPeers int `json:"minPeers"`
+	// StartupMinutes is how long a client may take to start serving its api
+	// before kubernetes restarts it, covering database migrations and
+	// checkpoint sync downloads
+	StartupMinutes int `json:"startupMinutes"`
+	// HealthCheckImage runs the execution client json-rpc readiness check, it needs sh and curl
+	HealthCheckImage string `json:"healthCheckImage"`
+}
+
+const (
+	defaultMinPeers         = 1
+	defaultStartupMinutes   = 60
+	defaultHealthCheckImage = "curlimages/curl:8.10.1"
+	probePeriodSeconds      = 10
+)
+
+func (p ProbeConfig) withDefaults() ProbeConfig {
+	if p.MinPeers == 0 {
+		p.MinPeers = defaultMinPeers
+	}
+	if p.StartupMinutes == 0 {
+		p.StartupMinutes = defaultStartupMinutes
+	}
+	if p.HealthCheckImage == "" {
+		p.HealthCheckImage = defaultHealthCheckImage
+	}
+	return p
+}
+
+// startupFailureThreshold converts the startup window into probe attempts
+func (p ProbeConfig) startupFailureThreshold() int {
+	return p.StartupMinutes * 60 / probePeriodSeconds
+}
+
+// executionReadinessScript is ready only when the execution client reports
+// it is not syncing and has at least minPeers peers
+func executionReadinessScript(rpcPort, minPeers int) string {
+	return fmt.Sprintf(`set -e
+rpc() {
+  curl -sf -m 5 -H 'Content-Type: application/json' \
+    -d "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"$1\",\"params\":[]}" \
+    http://127.0.0.1:%d
+}
+rpc eth_syncing | grep -q '"result":false'
+peers=$(rpc net_peerCount | sed -E 's/.*"result":"(0x[0-9a-fA-F]+)".*/\1/')
+[ "$((peers))" -ge %d ]
+`, rpcPort, minPeers)
+}
+
+// executionProbes returns the startup and liveness probes for the execution
+// client container. They only check the rpc port accepts connections, so a
+// syncing client is never restarted for being behind.
+func executionProbes(args *EthereumNodeComponentArgs) (startup, liveness corev1.ProbePtrInput) {
+	if args.Probes.Disabled {
+		return nil, nil
+	}
+	tcp := &corev1.TCPSocketActionArgs{
+		Port: pulumi.Int(args.Ports.ExecutionRpc),
+	}
+	startup = &corev1.ProbeArgs{
+		TcpSocket:        tcp,
+		PeriodSeconds:    pulumi.Int(probePeriodSeconds),
+		FailureThreshold: pulumi.Int(args.Probes.startupFailureThreshold()),
+	}
+	liveness = &corev1.ProbeArgs{
+		TcpSocket:        tcp,
+		PeriodSeconds:    pulumi.Int(probePeriodSeconds * 3),
+		TimeoutSeconds:   pulumi.Int(5),
+		FailureThreshold: pulumi.Int(5),
+	}
+	return startup, liveness
+}
+
+// executionHealthSidecar runs the json-rpc readiness check against the
+// execution client over localhost. Its readiness gates the whole pod, so the
+// rpc service only routes to execution clients that are in sync.
+func executionHealthSidecar(args *EthereumNodeComponentArgs) corev1.ContainerArgs {
+	return corev1.ContainerArgs{
+		Name:    pulumi.String("health"),
+		Image:   pulumi.String(args.Probes.HealthCheckImage),
+		Command: pulumi.StringArray{pulumi.String("sh"), pulumi.String("-c"), pulumi.String("trap 'exit 0' TERM; sleep infinity & wait")},
+		ReadinessProbe: &corev1.ProbeArgs{
+			Exec: &corev1.ExecActionArgs{
+				Command: pulumi.StringArray{
+					pulumi.String("sh"),
+					pulumi.String("-c"),
+					pulumi.String(executionReadinessScript(args.Ports.ExecutionRpc, args.Probes.MinPeers)),
+				},
+			},
+			PeriodSeconds:    pulumi.Int(probePeriodSeconds),
+			TimeoutSeconds:   pulumi.Int(10),
+			FailureThreshold: pulumi.Int(3),
+		},
+		Resources: &corev1.ResourceRequirementsArgs{
+			Requests: pulumi.StringMap{
+				"cpu":    pulumi.String("10m"),
+				"memory": pulumi.String("16Mi"),
+			},
+			Limits: pulumi.StringMap{
+				"memory": pulumi.String("64Mi"),
+			},
+		},
+	}
+}
+
+// consensusProbes uses the standard beacon api so it works for every
+// consensus client. The node is ready only once /eth/v1/node/health reports
+// it is synced, while startup and liveness only need /eth/v1/node/syncing to answer.
+func consensusProbes(args *EthereumNodeComponentArgs) (startup, liveness, readiness corev1.ProbePtrInput) {
+	if args.Probes.Disabled {
+		return nil, nil, nil
+	}
+	syncing := &corev1.HTTPGetActionArgs{
+		Path: pulumi.String("/eth/v1/node/syncing"),
+		Port: pulumi.Int(args.Ports.ConsensusHttp),
+	}
+	startup = &corev1.ProbeArgs{
+		HttpGet:          syncing,
+		PeriodSeconds:    pulumi.Int(probePeriodSeconds),
+		FailureThreshold: pulumi.Int(args.Probes.startupFailureThreshold()),
+	}
+	liveness = &corev1.ProbeArgs{
+		HttpGet:          syncing,
+		PeriodSeconds:    pulumi.Int(probePeriodSeconds * 3),
+		TimeoutSeconds:   pulumi.Int(5),
+		FailureThreshold: pulumi.Int(5),
+	}
+	readiness = &corev1.ProbeArgs{
+		HttpGet: &corev1.HTTPGetActionArgs{
+			// report syncing as unavailable rather than the default 206
+			Path: pulumi.String("/eth/v1/node/health?syncing_status=503"),
+			Port: pulumi.Int(args.Ports.ConsensusHttp),
+		},
+		PeriodSeconds:    pulumi.Int(probePeriodSeconds),
+		TimeoutSeconds:   pulumi.Int(5),
+		FailureThreshold: pulumi.Int(3),
+	}
+	return startup, liveness, readiness
+}
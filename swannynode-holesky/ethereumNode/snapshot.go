@@ -0,0 +1,332 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ObjectStoreConfig is the s3 compatible object store snapshots live in
+type ObjectStoreConfig struct {
+	// Endpoint is the object store url, e.g. a local MinIO, leave it empty for aws s3
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region"`
+	// CredentialsSecret names a secret with AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY, without it the pod's own aws identity is used
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// env returns the environment the aws cli needs to reach the object store
+func (c ObjectStoreConfig) env() (corev1.EnvVarArray, corev1.EnvFromSourceArrayInput) {
+	env := corev1.EnvVarArray{}
+	if c.Endpoint != "" {
+		env = append(env, corev1.EnvVarArgs{Name: pulumi.String("AWS_ENDPOINT_URL"), Value: pulumi.String(c.Endpoint)})
+	}
+	if c.Region != "" {
+		env = append(env, corev1.EnvVarArgs{Name: pulumi.String("AWS_REGION"), Value: pulumi.String(c.Region)})
+	}
+	if c.CredentialsSecret == "" {
+		return env, nil
+	}
+	return env, corev1.EnvFromSourceArray{
+		corev1.EnvFromSourceArgs{
+			SecretRef: &corev1.SecretEnvSourceArgs{
+				Name: pulumi.String(c.CredentialsSecret),
+			},
+		},
+	}
+}
+
+// SnapshotRestoreConfig bootstraps empty data volumes from zstd compressed
+// datadir archives in an s3 compatible bucket, so a new node does not sync
+// from genesis. Volumes that already hold data are left alone.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:snapshotRestore:
+//	  image: <registry>/snapshot:v0.1.0
+//	  endpoint: http://minio.minio:9000
+//	  credentialsSecret: snapshot-credentials
+//	  execution:
+//	    url: s3://snapshots/holesky/reth/latest.json
+//	  consensus:
+//	    url: s3://snapshots/holesky/lighthouse/latest.tar.zst
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+type SnapshotRestoreConfig struct {
+	// Image is built from the snapshot directory of this repo
+	Image string `json:"image"`
+	ObjectStoreConfig
+	// Execution and Consensus are the archives of each client, either may be left out
+	Execution *SnapshotSource `json:"execution"`
+	Consensus *SnapshotSource `json:"consensus"`
+}
+
+// SnapshotSource is a datadir archive, or the json manifest the snapshot
+// producer publishes next to it. Sha256 is read from the manifest, or from
+// the object next to the archive with a .sha256 suffix, when it is not set.
+type SnapshotSource struct {
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256"`
+}
+
+func (c *SnapshotRestoreConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("snapshotRestore image is required")
+	}
+	if c.Execution == nil && c.Consensus == nil {
+		return fmt.Errorf("snapshotRestore needs an execution or a consensus archive")
+	}
+	for _, source := range []*SnapshotSource{c.Execution, c.Consensus} {
+		if source == nil {
+			continue
+		}
+		if !strings.HasPrefix(source.Url, "s3://") {
+			return fmt.Errorf("snapshot url %q must be an s3:// url", source.Url)
+		}
+		if source.Sha256 != "" && len(source.Sha256) != 64 {
+			return fmt.Errorf("snapshot sha256 for %s must be 64 hex characters", source.Url)
+		}
+	}
+	return nil
+}
+
+// snapshotRestoreContainer is the init container filling the client's data
+// volume from source before the client starts
+func snapshotRestoreContainer(args *EthereumNodeComponentArgs, clientName string, source *SnapshotSource, dataVolume, dataDir string) corev1.ContainerArgs {
+	restore := args.SnapshotRestore
+	env, envFrom := restore.env()
+	env = append(env,
+		corev1.EnvVarArgs{Name: pulumi.String("SNAPSHOT_URL"), Value: pulumi.String(source.Url)},
+		corev1.EnvVarArgs{Name: pulumi.String("SNAPSHOT_SHA256"), Value: pulumi.String(source.Sha256)},
+		corev1.EnvVarArgs{Name: pulumi.String("DATA_DIR"), Value: pulumi.String(dataDir)},
+	)
+	return corev1.ContainerArgs{
+		Name:    pulumi.Sprintf("%s-snapshot-restore", clientName),
+		Image:   pulumi.String(restore.Image),
+		Command: pulumi.StringArray{pulumi.String("restore")},
+		Env:     env,
+		EnvFrom: envFrom,
+		VolumeMounts: corev1.VolumeMountArray{
+			corev1.VolumeMountArgs{
+				Name:      pulumi.String(dataVolume),
+				MountPath: pulumi.String(dataDir),
+			},
+		},
+	}
+}
+
+// SnapshotProducerConfig publishes datadir archives of the node on a
+// schedule, in the layout snapshot restore reads. Each run stops the last
+// replica of every node statefulset while its volumes are archived, so point
+// it at a node whose downtime is acceptable, or run co-located pairs with a
+// spare replica.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:snapshotProducer:
+//	  image: <registry>/snapshot:v0.1.0
+//	  bucket: s3://snapshots
+//	  schedule: "0 3 * * 0"
+//	  retain: 4
+type SnapshotProducerConfig struct {
+	// Image is built from the snapshot directory of this repo
+	Image string `json:"image"`
+	ObjectStoreConfig
+	// Bucket is the s3://bucket/prefix archives are published under, per network and client
+	Bucket string `json:"bucket"`
+	// Schedule is the cron schedule of the producer, defaults to weekly
+	Schedule string `json:"schedule"`
+	// Retain is how many snapshots of each client are kept
+	Retain int `json:"retain"`
+}
+
+const (
+	defaultSnapshotSchedule = "0 3 * * 0"
+	defaultSnapshotRetain   = 3
+	snapshotMountDir        = "/snapshot"
+)
+
+func (c *SnapshotProducerConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("snapshotProducer image is required")
+	}
+	if !strings.HasPrefix(c.Bucket, "s3://") {
+		return fmt.Errorf("snapshotProducer bucket %q must be an s3:// url", c.Bucket)
+	}
+	c.Bucket = strings.TrimSuffix(c.Bucket, "/")
+	if c.Schedule == "" {
+		c.Schedule = defaultSnapshotSchedule
+	}
+	if c.Retain == 0 {
+		c.Retain = defaultSnapshotRetain
+	}
+	if c.Retain < 1 {
+		return fmt.Errorf("snapshotProducer retain must be at least 1")
+	}
+	return nil
+}
+
+// newSnapshotProducer creates the cron job archiving the last replica of set
+// and the service account allowing it to stop and start that replica
+func newSnapshotProducer(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, set nodeSet) error {
+	producer := args.SnapshotProducer
+	name := fmt.Sprintf("%s-snapshot", set.name)
+	namespace := pulumi.String(args.Namespace)
+	pod := fmt.Sprintf("%s-%d", set.name, lastOrdinal(args))
+
+	// Create a service account allowed to scale the statefulset and wait for its pod
+	serviceAccount, err := newNodeServiceAccount(ctx, component, args, name, rbacv1.PolicyRuleArray{
+		rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("apps")},
+			Resources:     pulumi.StringArray{pulumi.String("statefulsets"), pulumi.String("statefulsets/scale")},
+			ResourceNames: pulumi.StringArray{pulumi.String(set.name)},
+			Verbs:         pulumi.ToStringArray([]string{"get", "patch", "update"}),
+		},
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("pods")},
+			Verbs:     pulumi.ToStringArray([]string{"get", "list", "watch"}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// mount the replica's own claims
+	var archives []string
+	volumes := corev1.VolumeArray{}
+	volumeMounts := corev1.VolumeMountArray{}
+	for _, part := range set.parts {
+		mountPath := fmt.Sprintf("%s/%s", snapshotMountDir, part.client)
+		archives = append(archives, fmt.Sprintf("%s:%s:%s", part.client, part.kind, mountPath))
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String(part.dataVolume),
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSourceArgs{
+				ClaimName: pulumi.String(claimName(part, set, lastOrdinal(args))),
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String(part.dataVolume),
+			MountPath: pulumi.String(mountPath),
+		})
+	}
+
+	env, envFrom := producer.env()
+	env = append(env,
+		corev1.EnvVarArgs{Name: pulumi.String("NAMESPACE"), Value: namespace},
+		corev1.EnvVarArgs{Name: pulumi.String("STATEFULSET"), Value: pulumi.String(set.name)},
+		corev1.EnvVarArgs{Name: pulumi.String("POD"), Value: pulumi.String(pod)},
+		corev1.EnvVarArgs{Name: pulumi.String("ARCHIVES"), Value: pulumi.String(strings.Join(archives, " "))},
+		corev1.EnvVarArgs{Name: pulumi.String("SNAPSHOT_PREFIX"), Value: pulumi.Sprintf("%s/%s", producer.Bucket, args.Network)},
+		corev1.EnvVarArgs{Name: pulumi.String("RETAIN"), Value: pulumi.Sprintf("%d", producer.Retain)},
+		corev1.EnvVarArgs{Name: pulumi.String("EXECUTION_RPC_PORT"), Value: pulumi.Sprintf("%d", args.Ports.ExecutionRpc)},
+		corev1.EnvVarArgs{Name: pulumi.String("CONSENSUS_HTTP_PORT"), Value: pulumi.Sprintf("%d", args.Ports.ConsensusHttp)},
+	)
+
+	// Create a cron job archiving the replica. It is scheduled next to the
+	// replica because a ReadWriteOnce volume can be mounted by every pod on
+	// the node it is attached to, so the job holds on to the volumes while
+	// the replica is stopped.
+	_, err = batchv1.NewCronJob(ctx, name, &batchv1.CronJobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+		Spec: &batchv1.CronJobSpecArgs{
+			Schedule:                   pulumi.String(producer.Schedule),
+			ConcurrencyPolicy:          pulumi.String("Forbid"),
+			SuccessfulJobsHistoryLimit: pulumi.Int(1),
+			FailedJobsHistoryLimit:     pulumi.Int(2),
+			JobTemplate: &batchv1.JobTemplateSpecArgs{
+				Spec: &batchv1.JobSpecArgs{
+					BackoffLimit: pulumi.Int(0),
+					Template: &corev1.PodTemplateSpecArgs{
+						Spec: &corev1.PodSpecArgs{
+							ServiceAccountName: serviceAccount,
+							RestartPolicy:      pulumi.String("Never"),
+							Affinity: &corev1.AffinityArgs{
+								PodAffinity: &corev1.PodAffinityArgs{
+									RequiredDuringSchedulingIgnoredDuringExecution: corev1.PodAffinityTermArray{
+										corev1.PodAffinityTermArgs{
+											LabelSelector: &metav1.LabelSelectorArgs{
+												MatchLabels: pulumi.StringMap{
+													"statefulset.kubernetes.io/pod-name": pulumi.String(pod),
+												},
+											},
+											TopologyKey: pulumi.String("kubernetes.io/hostname"),
+										},
+									},
+								},
+							},
+							Containers: corev1.ContainerArray{
+								corev1.ContainerArgs{
+									Name:         pulumi.String(name),
+									Image:        pulumi.String(producer.Image),
+									Command:      pulumi.StringArray{pulumi.String("produce")},
+									Env:          env,
+									EnvFrom:      envFrom,
+									VolumeMounts: volumeMounts,
+								},
+							},
+							Volumes: volumes,
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "")...)
+	return err
+}
+
+// newNodeServiceAccount creates a service account for the node's jobs and
+// controllers, bound to a role granting rules in the node namespace, and
+// returns its name
+func newNodeServiceAccount(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, name string, rules rbacv1.PolicyRuleArray) (pulumi.StringOutput, error) {
+	namespace := pulumi.String(args.Namespace)
+	serviceAccount, err := corev1.NewServiceAccount(ctx, name, &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	role, err := rbacv1.NewRole(ctx, name, &rbacv1.RoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+		Rules: rules,
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	_, err = rbacv1.NewRoleBinding(ctx, name, &rbacv1.RoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace,
+		},
+		RoleRef: &rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("Role"),
+			Name:     role.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount.Metadata.Name().Elem(),
+				Namespace: namespace,
+			},
+		},
+	}, childOpts(component, "")...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	return serviceAccount.Metadata.Name().Elem(), nil
+}
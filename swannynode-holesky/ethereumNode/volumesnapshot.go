@@ -0,0 +1,160 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// VolumeSnapshotConfig takes csi VolumeSnapshots of every data volume of the
+// node on a schedule, keeping the newest Retain of each. The snapshot class
+// is installed by the cluster program.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:volumeSnapshots:
+//	  image: <registry>/snapshot:v0.1.0
+//	  schedule: "0 4 * * *"
+//	  retain: 7
+type VolumeSnapshotConfig struct {
+	// Image is built from the snapshot directory of this repo
+	Image string `json:"image"`
+	// Class is the VolumeSnapshotClass, defaults to the one the cluster program creates
+	Class string `json:"class"`
+	// Schedule is the cron schedule of the snapshots, defaults to daily
+	Schedule string `json:"schedule"`
+	// Retain is how many snapshots of each volume are kept
+	Retain int `json:"retain"`
+}
+
+// VolumeSnapshotSources names the VolumeSnapshots new data volumes are
+// created from, to restore a node to a snapshot or clone another node. Only
+// claims that do not exist yet are created from them, delete the claims of a
+// node and refresh the stack to restore it in place.
+//
+//	swannynode-mainnet:volumeSnapshotRestore:
+//	  execution: reth-config-data-20261017040000
+//	  consensus: lighthouse-data-20261017040000
+type VolumeSnapshotSources struct {
+	Execution string `json:"execution"`
+	Consensus string `json:"consensus"`
+}
+
+const (
+	defaultVolumeSnapshotClass    = "aws-ebs-snapshot"
+	defaultVolumeSnapshotSchedule = "0 4 * * *"
+	defaultVolumeSnapshotRetain   = 7
+)
+
+func (c *VolumeSnapshotConfig) validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("volumeSnapshots image is required")
+	}
+	if c.Class == "" {
+		c.Class = defaultVolumeSnapshotClass
+	}
+	if c.Schedule == "" {
+		c.Schedule = defaultVolumeSnapshotSchedule
+	}
+	if c.Retain == 0 {
+		c.Retain = defaultVolumeSnapshotRetain
+	}
+	if c.Retain < 1 {
+		return fmt.Errorf("volumeSnapshots retain must be at least 1")
+	}
+	return nil
+}
+
+// snapshotDataSource returns the VolumeSnapshot a data volume of kind is created from, if any
+func snapshotDataSource(args *EthereumNodeComponentArgs, kind string) corev1.TypedLocalObjectReferencePtrInput {
+	sources := args.VolumeSnapshotRestore
+	if sources == nil {
+		return nil
+	}
+	name := sources.Execution
+	if kind == consensusKind {
+		name = sources.Consensus
+	}
+	if name == "" {
+		return nil
+	}
+	return &corev1.TypedLocalObjectReferenceArgs{
+		ApiGroup: pulumi.String("snapshot.storage.k8s.io"),
+		Kind:     pulumi.String("VolumeSnapshot"),
+		Name:     pulumi.String(name),
+	}
+}
+
+// newVolumeSnapshots creates the cron job snapshotting the data volumes of every replica of set
+func newVolumeSnapshots(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, set nodeSet) error {
+	snapshots := args.VolumeSnapshots
+	name := fmt.Sprintf("%s-volume-snapshot", set.name)
+
+	var claims []string
+	for ordinal := 0; ordinal <= lastOrdinal(args); ordinal++ {
+		for _, part := range set.parts {
+			claims = append(claims, claimName(part, set, ordinal))
+		}
+	}
+
+	// Create a service account allowed to manage the snapshots
+	serviceAccount, err := newNodeServiceAccount(ctx, component, args, name, rbacv1.PolicyRuleArray{
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("snapshot.storage.k8s.io")},
+			Resources: pulumi.StringArray{pulumi.String("volumesnapshots")},
+			Verbs:     pulumi.ToStringArray([]string{"get", "list", "watch", "create", "patch", "delete"}),
+		},
+		rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("persistentvolumeclaims")},
+			Verbs:     pulumi.StringArray{pulumi.String("get")},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Create a cron job taking the snapshots
+	_, err = batchv1.NewCronJob(ctx, name, &batchv1.CronJobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: pulumi.String(args.Namespace),
+		},
+		Spec: &batchv1.CronJobSpecArgs{
+			Schedule:                   pulumi.String(snapshots.Schedule),
+			ConcurrencyPolicy:          pulumi.String("Forbid"),
+			SuccessfulJobsHistoryLimit: pulumi.Int(1),
+			FailedJobsHistoryLimit:     pulumi.Int(2),
+			JobTemplate: &batchv1.JobTemplateSpecArgs{
+				Spec: &batchv1.JobSpecArgs{
+					BackoffLimit: pulumi.Int(0),
+					Template: &corev1.PodTemplateSpecArgs{
+						Spec: &corev1.PodSpecArgs{
+							ServiceAccountName: serviceAccount,
+							RestartPolicy:      pulumi.String("Never"),
+							Containers: corev1.ContainerArray{
+								corev1.ContainerArgs{
+									Name:    pulumi.String(name),
+									Image:   pulumi.String(snapshots.Image),
+									Command: pulumi.StringArray{pulumi.String("volume-snapshot")},
+									Env: corev1.EnvVarArray{
+										corev1.EnvVarArgs{Name: pulumi.String("NAMESPACE"), Value: pulumi.String(args.Namespace)},
+										corev1.EnvVarArgs{Name: pulumi.String("CLAIMS"), Value: pulumi.String(strings.Join(claims, " "))},
+										corev1.EnvVarArgs{Name: pulumi.String("CLASS"), Value: pulumi.String(snapshots.Class)},
+										corev1.EnvVarArgs{Name: pulumi.String("RETAIN"), Value: pulumi.Sprintf("%d", snapshots.Retain)},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "")...)
+	return err
+}
@@ -0,0 +1,135 @@
+package ethereumNode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// WsConfig enables websocket json-rpc on the execution client, served by
+// its own ALB and hostname so long lived connections get their own timeouts.
+// The rpc gateway does not proxy websockets, so ws cannot be combined with
+// it, and it is only available on clients that limit websockets to Apis,
+// which should stay limited to what eth_subscribe needs.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:ws:
+//	  hostname: ws.holesky.example.com
+//	  idleTimeoutSeconds: 3600
+type WsConfig struct {
+	// Hostname is the public hostname of the websocket endpoint, it gets its own route53 record
+	Hostname string `json:"hostname"`
+	// Path is the ingress path websocket connections are accepted on, defaults to /
+	Path string `json:"path"`
+	// Apis are the json-rpc namespaces served over websockets
+	Apis []string `json:"apis"`
+	// IdleTimeoutSeconds is how long the ALB keeps an idle connection open
+	IdleTimeoutSeconds int `json:"idleTimeoutSeconds"`
+	// StickinessSeconds is how long a client sticks to the same target on reconnects
+	StickinessSeconds int `json:"stickinessSeconds"`
+}
+
+const (
+	defaultWsPath               = "/"
+	defaultWsIdleTimeoutSeconds = 3600
+	defaultWsStickinessSeconds  = 86400
+	// the ALB accepts idle timeouts of up to 4000 seconds
+	maxWsIdleTimeoutSeconds = 4000
+)
+
+func (c *WsConfig) validate(defaultApis []string) error {
+	if c.Hostname == "" {
+		return fmt.Errorf("ws hostname is required")
+	}
+	if c.Path == "" {
+		c.Path = defaultWsPath
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("ws path %q must start with /", c.Path)
+	}
+	if len(c.Apis) == 0 {
+		c.Apis = defaultApis
+	}
+	if c.IdleTimeoutSeconds == 0 {
+		c.IdleTimeoutSeconds = defaultWsIdleTimeoutSeconds
+	}
+	if c.IdleTimeoutSeconds < 1 || c.IdleTimeoutSeconds > maxWsIdleTimeoutSeconds {
+		return fmt.Errorf("ws idleTimeoutSeconds must be between 1 and %d", maxWsIdleTimeoutSeconds)
+	}
+	if c.StickinessSeconds == 0 {
+		c.StickinessSeconds = defaultWsStickinessSeconds
+	}
+	if c.StickinessSeconds < 1 || c.StickinessSeconds > 604800 {
+		return fmt.Errorf("ws stickinessSeconds must be between 1 and 604800")
+	}
+	return nil
+}
+
+// newWsIngress exposes the execution client websocket service through its
+// own ALB and points the websocket hostname at it
+func newWsIngress(ctx *pulumi.Context, component pulumi.Resource, args *EthereumNodeComponentArgs, execution *executionResources) (*route53.Record, error) {
+	ws := args.Ws
+	elName := execution.client.Name()
+
+	// Create an ingress for the websocket service
+	wsIngress, err := networkingv1.NewIngress(ctx, fmt.Sprintf("%s-ws-ingress", elName), &networkingv1.IngressArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.Sprintf("%s-%s-ws-ingress", elName, args.Network),
+			Namespace: pulumi.String(args.Namespace),
+			Annotations: pulumi.StringMap{
+				"kubernetes.io/ingress.class":                        pulumi.String("alb"),
+				"alb.ingress.kubernetes.io/scheme":                   pulumi.String("internet-facing"),
+				"alb.ingress.kubernetes.io/target-type":              pulumi.String("instance"),
+				"alb.ingress.kubernetes.io/certificate-arn":          pulumi.String(args.TlsCertArn),
+				"alb.ingress.kubernetes.io/listen-ports":             pulumi.String(`[{"HTTPS":443}]`),
+				"alb.ingress.kubernetes.io/load-balancer-attributes": pulumi.Sprintf("idle_timeout.timeout_seconds=%d", ws.IdleTimeoutSeconds),
+				// keep reconnecting subscribers on the same node so they see a consistent head
+				"alb.ingress.kubernetes.io/target-group-attributes": pulumi.Sprintf("stickiness.enabled=true,stickiness.type=lb_cookie,stickiness.lb_cookie.duration_seconds=%d", ws.StickinessSeconds),
+				// a plain GET without an upgrade is answered with a 4xx by the websocket server
+				"alb.ingress.kubernetes.io/success-codes": pulumi.String("200-499"),
+			},
+		},
+		Spec: &networkingv1.IngressSpecArgs{
+			Rules: &networkingv1.IngressRuleArray{
+				&networkingv1.IngressRuleArgs{
+					Host: pulumi.String(ws.Hostname),
+					Http: &networkingv1.HTTPIngressRuleValueArgs{
+						Paths: &networkingv1.HTTPIngressPathArray{
+							&networkingv1.HTTPIngressPathArgs{
+								Path:     pulumi.String(ws.Path),
+								PathType: pulumi.String("Prefix"),
+								Backend: &networkingv1.IngressBackendArgs{
+									Service: &networkingv1.IngressServiceBackendArgs{
+										Name: execution.wsService.Metadata.Name().Elem(),
+										Port: &networkingv1.ServiceBackendPortArgs{
+											Number: pulumi.Int(execution.wsPort),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, childOpts(component, "", pulumi.DependsOn([]pulumi.Resource{execution.wsService}))...)
+	if err != nil {
+		return nil, err
+	}
+
+	// create route53 record for the websocket endpoint
+	return route53.NewRecord(ctx, fmt.Sprintf("%s-ws-dns", elName), &route53.RecordArgs{
+		ZoneId: pulumi.String(args.ZoneId),
+		Name:   pulumi.String(ws.Hostname),
+		Records: pulumi.StringArray{
+			ingressHostname(wsIngress),
+		},
+		Ttl:  pulumi.Int(300),
+		Type: pulumi.String("CNAME"),
+	}, childOpts(component, "")...)
+}
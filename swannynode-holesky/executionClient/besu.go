@@ -0,0 +1,59 @@
+package executionClient
+
+import (
+	"fmt"
+	"strings"
+)
+
+type besu struct{}
+
+func (besu) Name() string          { return Besu }
+func (besu) Image() string         { return "hyperledger/besu:latest" }
+func (besu) DataMountPath() string { return "/opt/besu/data" }
+func (besu) ConfigFile() string    { return "" }
+
+func (c besu) Command(spec Spec) []string {
+	command := []string{
+		"besu",
+		fmt.Sprintf("--network=%s", spec.Network),
+		fmt.Sprintf("--data-path=%s", dataDir(c, spec.Network)),
+		"--sync-mode=SNAP",
+		fmt.Sprintf("--engine-jwt-secret=%s", spec.JwtPath),
+		fmt.Sprintf("--engine-rpc-port=%d", spec.EnginePort),
+		"--engine-host-allowlist=*",
+		"--rpc-http-enabled",
+		"--rpc-http-host=0.0.0.0",
+		fmt.Sprintf("--rpc-http-port=%d", spec.RpcPort),
+		// besu namespaces are upper case, e.g. ETH,NET,WEB3
+		fmt.Sprintf("--rpc-http-api=%s", strings.ToUpper(joinApis(spec.HttpApis))),
+		"--host-allowlist=*",
+		"--metrics-enabled",
+		"--metrics-host=0.0.0.0",
+		fmt.Sprintf("--metrics-port=%d", spec.MetricsPort),
+		fmt.Sprintf("--p2p-port=%d", spec.P2PPort),
+	}
+	if spec.WsPort != 0 {
+		command = append(command,
+			"--rpc-ws-enabled",
+			"--rpc-ws-host=0.0.0.0",
+			fmt.Sprintf("--rpc-ws-port=%d", spec.WsPort),
+			fmt.Sprintf("--rpc-ws-api=%s", strings.ToUpper(joinApis(spec.WsApis))),
+		)
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, fmt.Sprintf("--p2p-host=%s", spec.ExternalIp), "--nat-method=NONE")
+	}
+	return command
+}
+
+func (besu) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
+
+func (besu) WsPort(spec Spec) int {
+	return spec.WsPort
+}
+
+func (besu) LimitsWsApis() bool {
+	return true
+}
@@ -0,0 +1,59 @@
+package executionClient
+
+import "fmt"
+
+type erigon struct{}
+
+// erigon's torrent client port for downloading snapshot segments
+const erigonTorrentPort = 42069
+
+func (erigon) Name() string          { return Erigon }
+func (erigon) Image() string         { return "erigontech/erigon:latest" }
+func (erigon) DataMountPath() string { return "/home/erigon/.local/share/erigon" }
+func (erigon) ConfigFile() string    { return "" }
+
+func (c erigon) Command(spec Spec) []string {
+	command := []string{
+		"erigon",
+		"--chain", spec.Network,
+		"--datadir", dataDir(c, spec.Network),
+		"--externalcl",
+		"--authrpc.jwtsecret", spec.JwtPath,
+		"--authrpc.addr", "0.0.0.0",
+		"--authrpc.port", fmt.Sprint(spec.EnginePort),
+		"--authrpc.vhosts", "*",
+		"--metrics",
+		"--metrics.addr", "0.0.0.0",
+		"--metrics.port", fmt.Sprint(spec.MetricsPort),
+		"--port", fmt.Sprint(spec.P2PPort),
+		"--torrent.port", fmt.Sprint(erigonTorrentPort),
+		"--http",
+		"--http.addr", "0.0.0.0",
+		"--http.port", fmt.Sprint(spec.RpcPort),
+		"--http.vhosts", "*",
+		"--http.api", joinApis(spec.HttpApis),
+	}
+	// erigon serves websockets on the http port with the http apis
+	if spec.WsPort != 0 {
+		command = append(command, "--ws")
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--nat", fmt.Sprintf("extip:%s", spec.ExternalIp))
+	}
+	return command
+}
+
+func (erigon) P2PPorts(spec Spec) []Port {
+	return append(tcpAndUdp(spec.P2PPort),
+		Port{Name: "torrent-tcp", Port: erigonTorrentPort, Protocol: "TCP"},
+		Port{Name: "torrent-udp", Port: erigonTorrentPort, Protocol: "UDP"},
+	)
+}
+
+func (erigon) WsPort(spec Spec) int {
+	return spec.RpcPort
+}
+
+func (erigon) LimitsWsApis() bool {
+	return false
+}
@@ -0,0 +1,117 @@
+package executionClient
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Client describes how to run an execution client in a kubernetes pod: the
+// image to pull, where its data lives and how the common Spec maps onto its
+// command line flags.
+type Client interface {
+	// Name is the client name, also used for kubernetes resource names and labels
+	Name() string
+	// Image is the default container image for the client
+	Image() string
+	// DataMountPath is where the data persistent volume is mounted in the container
+	DataMountPath() string
+	// ConfigFile is the name of the client's config file inside ConfigDir,
+	// or an empty string when the client is configured with flags only
+	ConfigFile() string
+	// Command returns the container command that starts the client
+	Command(spec Spec) []string
+	// P2PPorts returns the peer to peer ports the client listens on
+	P2PPorts(spec Spec) []Port
+	// WsPort returns the port websocket json-rpc is served on when it is
+	// enabled, some clients serve it on the http rpc port
+	WsPort(spec Spec) int
+	// LimitsWsApis reports whether websocket json-rpc serves only WsApis,
+	// clients that reuse the http api set over websockets cannot keep debug
+	// and trace off a public ws endpoint
+	LimitsWsApis() bool
+}
+
+// Spec is the client-agnostic description of an execution client that every
+// implementation translates into its own flags.
+type Spec struct {
+	Network     string
+	JwtPath     string
+	EnginePort  int
+	RpcPort     int
+	MetricsPort int
+	P2PPort     int
+	HttpApis    []string
+	// WsPort enables websocket json-rpc serving WsApis when it is set
+	WsPort int
+	WsApis []string
+	// ExternalIp is the public address the client advertises in its enode
+	// record, left to the client's own nat detection when empty
+	ExternalIp string
+}
+
+// Port is a single container port exposed by a client
+type Port struct {
+	Name     string
+	Port     int
+	Protocol string
+}
+
+const (
+	Reth       = "reth"
+	Geth       = "geth"
+	Nethermind = "nethermind"
+	Besu       = "besu"
+	Erigon     = "erigon"
+)
+
+var clients = map[string]Client{
+	Reth:       reth{},
+	Geth:       geth{},
+	Nethermind: nethermind{},
+	Besu:       besu{},
+	Erigon:     erigon{},
+}
+
+// DefaultHttpApis is the set of json-rpc namespaces enabled when none are configured
+var DefaultHttpApis = []string{"eth", "net", "trace", "txpool", "web3", "rpc", "debug"}
+
+// DefaultWsApis is the set of websocket json-rpc namespaces enabled when none
+// are configured, enough for eth_subscribe
+var DefaultWsApis = []string{"eth", "net", "web3"}
+
+// New returns the execution client registered under name
+func New(name string) (Client, error) {
+	client, ok := clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported execution client %q, expected one of reth, geth, nethermind, besu or erigon", name)
+	}
+	return client, nil
+}
+
+// ConfigDir is the directory the client's config map is mounted at
+func ConfigDir(client Client) string {
+	return fmt.Sprintf("/etc/%s", client.Name())
+}
+
+// JwtPath is the path the execution jwt secret is mounted at for the client
+func JwtPath(client Client) string {
+	return path.Join(ConfigDir(client), "execution-jwt", "jwt.hex")
+}
+
+// dataDir is the per-network directory inside the data volume
+func dataDir(client Client, network string) string {
+	return path.Join(client.DataMountPath(), network)
+}
+
+// tcpAndUdp returns the same port number as a tcp and a udp port
+func tcpAndUdp(port int) []Port {
+	return []Port{
+		{Name: "p2p-tcp", Port: port, Protocol: "TCP"},
+		{Name: "p2p-udp", Port: port, Protocol: "UDP"},
+	}
+}
+
+func joinApis(apis []string) string {
+	return strings.Join(apis, ",")
+}
@@ -0,0 +1,56 @@
+package executionClient
+
+import "fmt"
+
+type geth struct{}
+
+func (geth) Name() string          { return Geth }
+func (geth) Image() string         { return "ethereum/client-go:stable" }
+func (geth) DataMountPath() string { return "/root/.ethereum" }
+func (geth) ConfigFile() string    { return "" }
+
+func (c geth) Command(spec Spec) []string {
+	command := []string{
+		"geth",
+		fmt.Sprintf("--%s", spec.Network),
+		"--datadir", dataDir(c, spec.Network),
+		"--authrpc.jwtsecret", spec.JwtPath,
+		"--authrpc.addr", "0.0.0.0",
+		"--authrpc.port", fmt.Sprint(spec.EnginePort),
+		"--authrpc.vhosts", "*",
+		"--metrics",
+		"--metrics.addr", "0.0.0.0",
+		"--metrics.port", fmt.Sprint(spec.MetricsPort),
+		"--port", fmt.Sprint(spec.P2PPort),
+		"--http",
+		"--http.addr", "0.0.0.0",
+		"--http.port", fmt.Sprint(spec.RpcPort),
+		"--http.vhosts", "*",
+		"--http.api", joinApis(spec.HttpApis),
+	}
+	if spec.WsPort != 0 {
+		command = append(command,
+			"--ws",
+			"--ws.addr", "0.0.0.0",
+			"--ws.port", fmt.Sprint(spec.WsPort),
+			"--ws.api", joinApis(spec.WsApis),
+			"--ws.origins", "*",
+		)
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--nat", fmt.Sprintf("extip:%s", spec.ExternalIp))
+	}
+	return command
+}
+
+func (geth) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
+
+func (geth) WsPort(spec Spec) int {
+	return spec.WsPort
+}
+
+func (geth) LimitsWsApis() bool {
+	return true
+}
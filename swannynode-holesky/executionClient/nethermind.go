@@ -0,0 +1,52 @@
+package executionClient
+
+import "fmt"
+
+type nethermind struct{}
+
+func (nethermind) Name() string          { return Nethermind }
+func (nethermind) Image() string         { return "nethermind/nethermind:latest" }
+func (nethermind) DataMountPath() string { return "/nethermind/data" }
+func (nethermind) ConfigFile() string    { return "" }
+
+func (c nethermind) Command(spec Spec) []string {
+	command := []string{
+		"/nethermind/nethermind",
+		"--config", spec.Network,
+		"--datadir", dataDir(c, spec.Network),
+		"--JsonRpc.Enabled", "true",
+		"--JsonRpc.Host", "0.0.0.0",
+		"--JsonRpc.Port", fmt.Sprint(spec.RpcPort),
+		"--JsonRpc.EnabledModules", joinApis(spec.HttpApis),
+		"--JsonRpc.EngineHost", "0.0.0.0",
+		"--JsonRpc.EnginePort", fmt.Sprint(spec.EnginePort),
+		"--JsonRpc.JwtSecretFile", spec.JwtPath,
+		"--Metrics.Enabled", "true",
+		"--Metrics.ExposePort", fmt.Sprint(spec.MetricsPort),
+		"--Network.P2PPort", fmt.Sprint(spec.P2PPort),
+		"--Network.DiscoveryPort", fmt.Sprint(spec.P2PPort),
+	}
+	// nethermind serves the http modules over websockets too
+	if spec.WsPort != 0 {
+		command = append(command,
+			"--Init.WebSocketsEnabled", "true",
+			"--JsonRpc.WebSocketsPort", fmt.Sprint(spec.WsPort),
+		)
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--Network.ExternalIp", spec.ExternalIp)
+	}
+	return command
+}
+
+func (nethermind) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
+
+func (nethermind) WsPort(spec Spec) int {
+	return spec.WsPort
+}
+
+func (nethermind) LimitsWsApis() bool {
+	return false
+}
@@ -0,0 +1,57 @@
+package executionClient
+
+import (
+	"fmt"
+	"path"
+)
+
+type reth struct{}
+
+func (reth) Name() string          { return Reth }
+func (reth) Image() string         { return "ghcr.io/paradigmxyz/reth:latest" }
+func (reth) DataMountPath() string { return "/root/.local/share/reth" }
+func (reth) ConfigFile() string    { return "reth.toml" }
+
+func (c reth) Command(spec Spec) []string {
+	command := []string{
+		"reth",
+		"node",
+		"--chain", spec.Network,
+		"--authrpc.jwtsecret", spec.JwtPath,
+		"--authrpc.addr", "0.0.0.0",
+		"--authrpc.port", fmt.Sprint(spec.EnginePort),
+		"--datadir", dataDir(c, spec.Network),
+		"--metrics", fmt.Sprintf("0.0.0.0:%d", spec.MetricsPort),
+		"--port", fmt.Sprint(spec.P2PPort),
+		"--http",
+		"--http.addr", "0.0.0.0",
+		"--http.port", fmt.Sprint(spec.RpcPort),
+		"--http.api", joinApis(spec.HttpApis),
+		"--config", path.Join(ConfigDir(c), c.ConfigFile()),
+	}
+	if spec.WsPort != 0 {
+		command = append(command,
+			"--ws",
+			"--ws.addr", "0.0.0.0",
+			"--ws.port", fmt.Sprint(spec.WsPort),
+			"--ws.api", joinApis(spec.WsApis),
+			"--ws.origins", "*",
+		)
+	}
+	if spec.ExternalIp != "" {
+		command = append(command, "--nat", fmt.Sprintf("extip:%s", spec.ExternalIp))
+	}
+	return command
+}
+
+func (reth) P2PPorts(spec Spec) []Port {
+	return tcpAndUdp(spec.P2PPort)
+}
+
+func (reth) WsPort(spec Spec) int {
+	return spec.WsPort
+}
+
+func (reth) LimitsWsApis() bool {
+	return true
+}
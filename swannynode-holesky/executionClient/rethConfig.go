@@ -0,0 +1,294 @@
+package executionClient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RethConfig models the sections of reth.toml the node program manages.
+// Fields left at their zero value are omitted from the rendered file so reth
+// falls back to its own defaults for them.
+//
+// Stack config uses the json names, e.g.
+//
+//	swannynode-mainnet:rethConfig:
+//	  prune:
+//	    blockInterval: 5
+//	    segments:
+//	      senderRecovery: full
+//	      receipts:
+//	        distance: 10064
+//	  peers:
+//	    connectionInfo:
+//	      maxOutbound: 100
+type RethConfig struct {
+	Stages   RethStages   `json:"stages" toml:"stages,omitempty"`
+	Prune    *RethPrune   `json:"prune" toml:"prune,omitempty"`
+	Peers    RethPeers    `json:"peers" toml:"peers,omitempty"`
+	Sessions RethSessions `json:"sessions" toml:"sessions,omitempty"`
+}
+
+type RethStages struct {
+	Headers   RethHeadersStage   `json:"headers" toml:"headers,omitempty"`
+	Bodies    RethBodiesStage    `json:"bodies" toml:"bodies,omitempty"`
+	Execution RethExecutionStage `json:"execution" toml:"execution,omitempty"`
+	Etl       RethEtlStage       `json:"etl" toml:"etl,omitempty"`
+}
+
+type RethHeadersStage struct {
+	DownloaderMaxConcurrentRequests uint64 `json:"downloaderMaxConcurrentRequests" toml:"downloader_max_concurrent_requests,omitzero"`
+	DownloaderMinConcurrentRequests uint64 `json:"downloaderMinConcurrentRequests" toml:"downloader_min_concurrent_requests,omitzero"`
+	DownloaderMaxBufferedResponses  uint64 `json:"downloaderMaxBufferedResponses" toml:"downloader_max_buffered_responses,omitzero"`
+	DownloaderRequestLimit          uint64 `json:"downloaderRequestLimit" toml:"downloader_request_limit,omitzero"`
+	CommitThreshold                 uint64 `json:"commitThreshold" toml:"commit_threshold,omitzero"`
+}
+
+type RethBodiesStage struct {
+	DownloaderRequestLimit               uint64 `json:"downloaderRequestLimit" toml:"downloader_request_limit,omitzero"`
+	DownloaderStreamBatchSize            uint64 `json:"downloaderStreamBatchSize" toml:"downloader_stream_batch_size,omitzero"`
+	DownloaderMaxBufferedBlocksSizeBytes uint64 `json:"downloaderMaxBufferedBlocksSizeBytes" toml:"downloader_max_buffered_blocks_size_bytes,omitzero"`
+	DownloaderMinConcurrentRequests      uint64 `json:"downloaderMinConcurrentRequests" toml:"downloader_min_concurrent_requests,omitzero"`
+	DownloaderMaxConcurrentRequests      uint64 `json:"downloaderMaxConcurrentRequests" toml:"downloader_max_concurrent_requests,omitzero"`
+}
+
+type RethExecutionStage struct {
+	MaxBlocks        uint64 `json:"maxBlocks" toml:"max_blocks,omitzero"`
+	MaxChanges       uint64 `json:"maxChanges" toml:"max_changes,omitzero"`
+	MaxCumulativeGas uint64 `json:"maxCumulativeGas" toml:"max_cumulative_gas,omitzero"`
+	// MaxDuration is a duration string such as "10m"
+	MaxDuration string `json:"maxDuration" toml:"max_duration,omitempty"`
+}
+
+type RethEtlStage struct {
+	FileSize uint64 `json:"fileSize" toml:"file_size,omitzero"`
+}
+
+type RethPrune struct {
+	BlockInterval uint64            `json:"blockInterval" toml:"block_interval,omitzero"`
+	Segments      RethPruneSegments `json:"segments" toml:"segments"`
+}
+
+type RethPruneSegments struct {
+	SenderRecovery    *PruneMode `json:"senderRecovery" toml:"sender_recovery,omitempty"`
+	TransactionLookup *PruneMode `json:"transactionLookup" toml:"transaction_lookup,omitempty"`
+	Receipts          *PruneMode `json:"receipts" toml:"receipts,omitempty"`
+	AccountHistory    *PruneMode `json:"accountHistory" toml:"account_history,omitempty"`
+	StorageHistory    *PruneMode `json:"storageHistory" toml:"storage_history,omitempty"`
+}
+
+// PruneMode is how much of a segment reth keeps: everything pruned ("full"),
+// only the last Distance blocks, or everything from block Before onwards.
+type PruneMode struct {
+	Full     bool
+	Distance uint64
+	Before   uint64
+}
+
+// MinimumPruningDistance is the smallest distance reth accepts for history
+// segments, enough to handle reorgs plus the unwind safety margin.
+const MinimumPruningDistance = 10064
+
+type RethPeers struct {
+	// RefillSlotsInterval and BanDuration are duration strings such as "5s" or "12h"
+	RefillSlotsInterval string             `json:"refillSlotsInterval" toml:"refill_slots_interval,omitempty"`
+	BanDuration         string             `json:"banDuration" toml:"ban_duration,omitempty"`
+	TrustedNodes        []string           `json:"trustedNodes" toml:"trusted_nodes,omitempty"`
+	TrustedNodesOnly    bool               `json:"trustedNodesOnly" toml:"trusted_nodes_only,omitempty"`
+	MaxBackoffCount     uint64             `json:"maxBackoffCount" toml:"max_backoff_count,omitzero"`
+	ConnectionInfo      RethConnectionInfo `json:"connectionInfo" toml:"connection_info,omitempty"`
+}
+
+type RethConnectionInfo struct {
+	MaxOutbound                uint64 `json:"maxOutbound" toml:"max_outbound,omitzero"`
+	MaxInbound                 uint64 `json:"maxInbound" toml:"max_inbound,omitzero"`
+	MaxConcurrentOutboundDials uint64 `json:"maxConcurrentOutboundDials" toml:"max_concurrent_outbound_dials,omitzero"`
+}
+
+type RethSessions struct {
+	SessionCommandBuffer          uint64            `json:"sessionCommandBuffer" toml:"session_command_buffer,omitzero"`
+	SessionEventBuffer            uint64            `json:"sessionEventBuffer" toml:"session_event_buffer,omitzero"`
+	Limits                        RethSessionLimits `json:"limits" toml:"limits,omitempty"`
+	InitialInternalRequestTimeout *RethDuration     `json:"initialInternalRequestTimeout" toml:"initial_internal_request_timeout,omitempty"`
+	ProtocolBreachRequestTimeout  *RethDuration     `json:"protocolBreachRequestTimeout" toml:"protocol_breach_request_timeout,omitempty"`
+	PendingSessionTimeout         *RethDuration     `json:"pendingSessionTimeout" toml:"pending_session_timeout,omitempty"`
+}
+
+type RethSessionLimits struct {
+	MaxPendingInbound      uint64 `json:"maxPendingInbound" toml:"max_pending_inbound,omitzero"`
+	MaxPendingOutbound     uint64 `json:"maxPendingOutbound" toml:"max_pending_outbound,omitzero"`
+	MaxEstablishedInbound  uint64 `json:"maxEstablishedInbound" toml:"max_established_inbound,omitzero"`
+	MaxEstablishedOutbound uint64 `json:"maxEstablishedOutbound" toml:"max_established_outbound,omitzero"`
+}
+
+// RethDuration is a duration reth serialises as a { secs, nanos } table,
+// it is written in stack config as a duration string such as "20s"
+type RethDuration struct {
+	time.Duration
+}
+
+// UnmarshalJSON accepts "full", {"distance": n} or {"before": n}
+func (m *PruneMode) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		if mode != "full" {
+			return fmt.Errorf("unknown prune mode %q, expected \"full\" or a distance or before block", mode)
+		}
+		*m = PruneMode{Full: true}
+		return nil
+	}
+	var fields struct {
+		Distance uint64 `json:"distance"`
+		Before   uint64 `json:"before"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*m = PruneMode{Distance: fields.Distance, Before: fields.Before}
+	return nil
+}
+
+// MarshalTOML writes the prune mode the way reth expects it
+func (m PruneMode) MarshalTOML() ([]byte, error) {
+	switch {
+	case m.Full:
+		return []byte(`"full"`), nil
+	case m.Distance > 0:
+		return []byte(fmt.Sprintf("{ distance = %d }", m.Distance)), nil
+	default:
+		return []byte(fmt.Sprintf("{ before = %d }", m.Before)), nil
+	}
+}
+
+func (m PruneMode) validate(segment string, allowFull bool) error {
+	set := 0
+	for _, isSet := range []bool{m.Full, m.Distance > 0, m.Before > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("prune segment %s must set exactly one of full, distance or before", segment)
+	}
+	if m.Full && !allowFull {
+		return fmt.Errorf("prune segment %s cannot be fully pruned, use a distance of at least %d", segment, MinimumPruningDistance)
+	}
+	if m.Distance > 0 && !allowFull && m.Distance < MinimumPruningDistance {
+		return fmt.Errorf("prune segment %s distance %d is below the minimum of %d", segment, m.Distance, MinimumPruningDistance)
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a duration string such as "20s"
+func (d *RethDuration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// MarshalTOML writes the duration as reth's { secs, nanos } table
+func (d RethDuration) MarshalTOML() ([]byte, error) {
+	secs := d.Duration / time.Second
+	nanos := d.Duration % time.Second
+	return []byte(fmt.Sprintf("{ secs = %d, nanos = %d }", secs, nanos)), nil
+}
+
+// Validate checks the config for combinations reth would reject at start up
+func (c *RethConfig) Validate() error {
+	headers := c.Stages.Headers
+	if headers.DownloaderMinConcurrentRequests > 0 && headers.DownloaderMaxConcurrentRequests > 0 &&
+		headers.DownloaderMinConcurrentRequests > headers.DownloaderMaxConcurrentRequests {
+		return fmt.Errorf("stages.headers downloader min concurrent requests is greater than the max")
+	}
+	bodies := c.Stages.Bodies
+	if bodies.DownloaderMinConcurrentRequests > 0 && bodies.DownloaderMaxConcurrentRequests > 0 &&
+		bodies.DownloaderMinConcurrentRequests > bodies.DownloaderMaxConcurrentRequests {
+		return fmt.Errorf("stages.bodies downloader min concurrent requests is greater than the max")
+	}
+	if c.Stages.Execution.MaxDuration != "" {
+		if _, err := time.ParseDuration(c.Stages.Execution.MaxDuration); err != nil {
+			return fmt.Errorf("stages.execution max duration: %w", err)
+		}
+	}
+
+	if c.Prune != nil {
+		segments := []struct {
+			name      string
+			mode      *PruneMode
+			allowFull bool
+		}{
+			{"sender_recovery", c.Prune.Segments.SenderRecovery, true},
+			{"transaction_lookup", c.Prune.Segments.TransactionLookup, true},
+			{"receipts", c.Prune.Segments.Receipts, false},
+			{"account_history", c.Prune.Segments.AccountHistory, false},
+			{"storage_history", c.Prune.Segments.StorageHistory, false},
+		}
+		for _, segment := range segments {
+			if segment.mode == nil {
+				continue
+			}
+			if err := segment.mode.validate(segment.name, segment.allowFull); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range map[string]string{
+		"peers refill slots interval": c.Peers.RefillSlotsInterval,
+		"peers ban duration":          c.Peers.BanDuration,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if c.Peers.TrustedNodesOnly && len(c.Peers.TrustedNodes) == 0 {
+		return fmt.Errorf("peers trusted nodes only is set without any trusted nodes")
+	}
+	for _, node := range c.Peers.TrustedNodes {
+		if !strings.HasPrefix(node, "enode://") {
+			return fmt.Errorf("trusted node %q is not an enode url", node)
+		}
+	}
+
+	limits := c.Sessions.Limits
+	if limits.MaxEstablishedInbound > 0 && c.Peers.ConnectionInfo.MaxInbound > limits.MaxEstablishedInbound {
+		return fmt.Errorf("peers max inbound %d exceeds sessions max established inbound %d", c.Peers.ConnectionInfo.MaxInbound, limits.MaxEstablishedInbound)
+	}
+	if limits.MaxEstablishedOutbound > 0 && c.Peers.ConnectionInfo.MaxOutbound > limits.MaxEstablishedOutbound {
+		return fmt.Errorf("peers max outbound %d exceeds sessions max established outbound %d", c.Peers.ConnectionInfo.MaxOutbound, limits.MaxEstablishedOutbound)
+	}
+	return nil
+}
+
+// Render validates the config and encodes it as reth.toml
+func (c *RethConfig) Render() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(c); err != nil {
+		return "", err
+	}
+	// make sure what we hand to reth is at least valid toml
+	var decoded map[string]interface{}
+	if _, err := toml.Decode(buf.String(), &decoded); err != nil {
+		return "", fmt.Errorf("rendered reth.toml is invalid: %w", err)
+	}
+	return buf.String(), nil
+}
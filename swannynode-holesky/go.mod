@@ -5,9 +5,12 @@ go 1.21
 toolchain go1.21.5
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/pulumi/pulumi-aws/sdk/v6 v6.27.0
 	github.com/pulumi/pulumi-kubernetes/sdk/v4 v4.10.0
+	github.com/pulumi/pulumi-random/sdk/v4 v4.8.2
 	github.com/pulumi/pulumi/sdk/v3 v3.116.0
+	images v0.0.0
 )
 
 require (
@@ -61,6 +64,7 @@ require (
 	github.com/pkg/term v1.1.0 // indirect
 	github.com/pulumi/appdash v0.0.0-20231130102222-75f619a67231 // indirect
 	github.com/pulumi/esc v0.6.2 // indirect
+	github.com/pulumi/pulumi-docker/sdk/v4 v4.5.3 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/rogpeppe/go-internal v1.11.0 // indirect
 	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06 // indirect
@@ -94,3 +98,5 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	lukechampine.com/frand v1.4.2 // indirect
 )
+
+replace images => ../images
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	storagev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/storage/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const dataStorageClass = "aws-gp3"
+
+// retainVolumesScript patches every volume of the storage class that still
+// has the Delete reclaim policy, the policy of a volume is fixed when it is
+// provisioned so a change to the class does not reach existing volumes
+const retainVolumesScript = `set -eu
+kubectl get pv -o jsonpath='{range .items[?(@.spec.storageClassName=="%[1]s")]}{.metadata.name} {.spec.persistentVolumeReclaimPolicy}{"\n"}{end}' > /tmp/volumes
+while read -r volume policy; do
+	if [ "$policy" = "Delete" ]; then
+		echo "retaining $volume"
+		kubectl patch pv "$volume" -p '{"spec":{"persistentVolumeReclaimPolicy":"Retain"}}'
+	fi
+done < /tmp/volumes
+`
+
+// newDataStorageClass creates the gp3 storage class the data volumes are
+// provisioned from, and a job moving the volumes provisioned before it
+// retained its volumes over to Retain.
+func newDataStorageClass(ctx *pulumi.Context, namespace, kubectlImage string) (*storagev1.StorageClass, error) {
+	// Create the gp3 storage class. Its reclaim policy cannot be changed in
+	// place, and the replacement keeps the fixed name so the old class has to
+	// go first. Claims only refer to the class by name, bound volumes are
+	// unaffected.
+	storageClass, err := storagev1.NewStorageClass(ctx, "gp3", &storagev1.StorageClassArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(dataStorageClass),
+		},
+		Provisioner:          pulumi.String("ebs.csi.aws.com"),
+		VolumeBindingMode:    pulumi.String("WaitForFirstConsumer"),
+		AllowVolumeExpansion: pulumi.Bool(true), // Allow volume expansion
+		// Keep the EBS volume when its PVC is deleted, so a destroy or a
+		// deleted claim does not throw away a synced database
+		ReclaimPolicy: pulumi.String("Retain"),
+		Parameters: pulumi.StringMap{
+			"type": pulumi.String("gp3"), // The type of EBS volume
+			"iops": pulumi.String("16000"),
+		},
+	}, pulumi.DeleteBeforeReplace(true))
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a service account allowed to patch the reclaim policy of the volumes
+	name := fmt.Sprintf("%s-retain-volumes", dataStorageClass)
+	serviceAccount, err := corev1.NewServiceAccount(ctx, name, &corev1.ServiceAccountArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: pulumi.String(namespace),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	clusterRole, err := rbacv1.NewClusterRole(ctx, name, &rbacv1.ClusterRoleArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(name),
+		},
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.StringArray{pulumi.String("persistentvolumes")},
+				Verbs:     pulumi.ToStringArray([]string{"list", "patch"}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	clusterRoleBinding, err := rbacv1.NewClusterRoleBinding(ctx, name, &rbacv1.ClusterRoleBindingArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(name),
+		},
+		RoleRef: &rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      serviceAccount.Metadata.Name().Elem(),
+				Namespace: pulumi.String(namespace),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a job retaining the existing volumes, pulumi waits for it to
+	// complete so a failed migration fails the update
+	_, err = batchv1.NewJob(ctx, name, &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: pulumi.String(namespace),
+		},
+		Spec: &batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(3),
+			Template: &corev1.PodTemplateSpecArgs{
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount.Metadata.Name().Elem(),
+					RestartPolicy:      pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("retain-volumes"),
+							Image:   pulumi.String(kubectlImage),
+							Command: pulumi.ToStringArray([]string{"/bin/sh", "-c", fmt.Sprintf(retainVolumesScript, dataStorageClass)}),
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{storageClass, clusterRoleBinding}))
+	if err != nil {
+		return nil, err
+	}
+	return storageClass, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials sign requests to aws. Expiration is zero for static keys.
+type awsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// awsCredentialSource finds credentials the way the aws sdks do, from the
+// environment, the web identity of an eks service account, or the instance
+// profile of an ec2 host, and caches them until shortly before they expire
+type awsCredentialSource struct {
+	region string
+	client *http.Client
+
+	mu     sync.Mutex
+	cached *awsCredentials
+}
+
+const imdsHost = "http://169.254.169.254"
+
+func newAwsCredentialSource(region string) *awsCredentialSource {
+	return &awsCredentialSource{region: region, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *awsCredentialSource) get(ctx context.Context) (*awsCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && (s.cached.Expiration.IsZero() || time.Until(s.cached.Expiration) > 5*time.Minute) {
+		return s.cached, nil
+	}
+
+	var credentials *awsCredentials
+	var err error
+	switch {
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		credentials = &awsCredentials{
+			AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		credentials, err = s.webIdentity(ctx)
+	default:
+		credentials, err = s.instanceProfile(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("aws credentials: %w", err)
+	}
+	s.cached = credentials
+	return credentials, nil
+}
+
+// webIdentity exchanges the projected service account token for credentials of the role it may assume
+func (s *awsCredentialSource) webIdentity(ctx context.Context) (*awsCredentials, error) {
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "validator-guard"
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/", s.region)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := s.send(request)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Credentials struct {
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+			Expiration      time.Time
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("parsing sts response: %w", err)
+	}
+	c := response.Credentials
+	return &awsCredentials{AccessKeyId: c.AccessKeyId, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expiration: c.Expiration}, nil
+}
+
+// instanceProfile reads the credentials of the ec2 instance role from imdsv2
+func (s *awsCredentialSource) instanceProfile(ctx context.Context) (*awsCredentials, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, imdsHost+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := s.send(request)
+	if err != nil {
+		return nil, fmt.Errorf("no credentials in the environment and no instance metadata: %w", err)
+	}
+	metadata := func(path string) ([]byte, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsHost+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return s.send(request)
+	}
+	role, err := metadata("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	body, err := metadata("/latest/meta-data/iam/security-credentials/" + strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]))
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("parsing instance credentials: %w", err)
+	}
+	return &awsCredentials{AccessKeyId: response.AccessKeyId, SecretAccessKey: response.SecretAccessKey, SessionToken: response.Token, Expiration: response.Expiration}, nil
+}
+
+func (s *awsCredentialSource) send(request *http.Request) ([]byte, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s: %s", request.Method, request.URL.Path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// signV4 signs the request and its payload for service in region with aws signature version 4
+func signV4(request *http.Request, payload []byte, credentials *awsCredentials, service, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	payloadHash := sha256Hex(payload)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+	request.Header.Set("Authorization", authorization(request, payloadHash, credentials, service, region, amzDate))
+}
+
+// authorization is the signature version 4 Authorization header of the
+// request as it stands at amzDate, the request's X-Amz-Date
+func authorization(request *http.Request, payloadHash string, credentials *awsCredentials, service, region, amzDate string) string {
+	date := amzDate[:8]
+
+	// every header set on the request is signed, along with the host
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyId, scope, signedHeaders, signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCredentials are the credentials of the aws signature version 4 test suite
+var testCredentials = &awsCredentials{
+	AccessKeyId:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// TestAuthorizationTestSuite signs requests of the aws signature version 4
+// test suite, all for service in us-east-1 at 20150830T123600Z
+func TestAuthorizationTestSuite(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		headers   map[string]string
+		body      string
+		signed    string
+		signature string
+	}{
+		{
+			name:      "get-vanilla",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/",
+			signed:    "host;x-amz-date",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "get-vanilla-empty-query-key",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param1=value1",
+			signed:    "host;x-amz-date",
+			signature: "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signed:    "host;x-amz-date",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:      "post-vanilla",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			signed:    "host;x-amz-date",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:      "post-header-key-sort",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			headers:   map[string]string{"My-Header1": "value1"},
+			signed:    "host;my-header1;x-amz-date",
+			signature: "c5410059b04c1ee005303aed430f6e6645f61f4dc9e1461ec8f8916fdf18852c",
+		},
+		{
+			name:      "post-x-www-form-urlencoded",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			headers:   map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:      "Param1=value1",
+			signed:    "content-type;host;x-amz-date",
+			signature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("X-Amz-Date", "20150830T123600Z")
+			for name, value := range tt.headers {
+				request.Header.Set(name, value)
+			}
+			got := authorization(request, sha256Hex([]byte(tt.body)), testCredentials, "service", "us-east-1", "20150830T123600Z")
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" +
+				tt.signed + ", Signature=" + tt.signature
+			if got != want {
+				t.Errorf("authorization\n got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "https://dynamodb.us-east-1.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := *testCredentials
+	credentials.SessionToken = "session-token"
+	payload := []byte(`{"TableName":"validator-guard"}`)
+	signV4(request, payload, &credentials, "dynamodb", "us-east-1", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	for name, want := range map[string]string{
+		"X-Amz-Date":           "20150830T123600Z",
+		"X-Amz-Content-Sha256": sha256Hex(payload),
+		"X-Amz-Security-Token": "session-token",
+	} {
+		if got := request.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	// the session token and payload hash are part of the signature
+	if got := request.Header.Get("Authorization"); !strings.Contains(got,
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %q does not sign the amz headers", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the guard config file, rendered by the holesky-validator program
+// or written by hand on a host running a validator outside the cluster
+type Config struct {
+	// Backend stores the lease: kubernetes, dynamodb, or file, a local
+	// stand-in for dynamodb that only guards validators on one host
+	Backend string `json:"backend"`
+	// Name is the lease, every validator running the same keys uses the same name
+	Name string `json:"name"`
+	// Holder identifies this validator in the lease, defaults to the hostname.
+	// A random suffix is added on every start, so a replacement pod with the
+	// same name never mistakes the lease of the pod it replaces for its own.
+	Holder string `json:"holder"`
+	// LeaseDurationSeconds is how long a lease that stopped being renewed
+	// blocks everyone else. RenewDeadlineSeconds is how long the holder keeps
+	// signing without a successful renewal, it must be well below the lease
+	// duration. RetryPeriodSeconds is how often the lease is renewed or tried.
+	LeaseDurationSeconds int `json:"leaseDurationSeconds"`
+	RenewDeadlineSeconds int `json:"renewDeadlineSeconds"`
+	RetryPeriodSeconds   int `json:"retryPeriodSeconds"`
+
+	Kubernetes *KubernetesBackend `json:"kubernetes"`
+	DynamoDb   *DynamoDbBackend   `json:"dynamodb"`
+	File       *FileBackend       `json:"file"`
+}
+
+// KubernetesBackend keeps the lease in a coordination.k8s.io Lease, created on first use
+type KubernetesBackend struct {
+	Namespace string `json:"namespace"`
+}
+
+// DynamoDbBackend keeps the lease as an item of a table with the string hash
+// key name. Endpoint points the guard at a local dynamodb for development.
+type DynamoDbBackend struct {
+	Table    string `json:"table"`
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+}
+
+// FileBackend keeps the lease in a file, locked while it is read and written
+type FileBackend struct {
+	Path string `json:"path"`
+}
+
+// Lease backends
+const (
+	BackendKubernetes = "kubernetes"
+	BackendDynamoDb   = "dynamodb"
+	BackendFile       = "file"
+)
+
+const (
+	defaultLeaseDurationSeconds = 60
+	defaultRenewDeadlineSeconds = 40
+	defaultRetryPeriodSeconds   = 5
+)
+
+// LoadConfig reads and validates the config file at path
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.Holder == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("holder is unset and the hostname unknown: %w", err)
+		}
+		c.Holder = hostname
+	}
+	if c.LeaseDurationSeconds == 0 {
+		c.LeaseDurationSeconds = defaultLeaseDurationSeconds
+	}
+	if c.RenewDeadlineSeconds == 0 {
+		c.RenewDeadlineSeconds = defaultRenewDeadlineSeconds
+	}
+	if c.RetryPeriodSeconds == 0 {
+		c.RetryPeriodSeconds = defaultRetryPeriodSeconds
+	}
+	if c.RetryPeriodSeconds <= 0 || c.RenewDeadlineSeconds <= c.RetryPeriodSeconds || c.LeaseDurationSeconds <= c.RenewDeadlineSeconds {
+		return fmt.Errorf("retryPeriodSeconds, renewDeadlineSeconds and leaseDurationSeconds must be positive and increasing")
+	}
+
+	switch c.Backend {
+	case BackendKubernetes:
+		if c.Kubernetes == nil || c.Kubernetes.Namespace == "" {
+			return fmt.Errorf("kubernetes.namespace is required for the kubernetes backend")
+		}
+	case BackendDynamoDb:
+		if c.DynamoDb == nil || c.DynamoDb.Table == "" || c.DynamoDb.Region == "" {
+			return fmt.Errorf("dynamodb.table and dynamodb.region are required for the dynamodb backend")
+		}
+	case BackendFile:
+		if c.File == nil || c.File.Path == "" {
+			return fmt.Errorf("file.path is required for the file backend")
+		}
+	default:
+		return fmt.Errorf("backend %q must be %s, %s or %s", c.Backend, BackendKubernetes, BackendDynamoDb, BackendFile)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dynamoLeaseStore keeps the lease as an item of a dynamodb table, a version
+// attribute rewritten on every write makes the writes conditional. Validators
+// outside the cluster, like an ec2 host, share the lease through it.
+type dynamoLeaseStore struct {
+	endpoint    string
+	region      string
+	table       string
+	name        string
+	credentials *awsCredentialSource
+	client      *http.Client
+}
+
+func newDynamoLeaseStore(cfg *Config) *dynamoLeaseStore {
+	backend := cfg.DynamoDb
+	endpoint := backend.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://dynamodb.%s.amazonaws.com", backend.Region)
+	}
+	return &dynamoLeaseStore{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      backend.Region,
+		table:       backend.Table,
+		name:        cfg.Name,
+		credentials: newAwsCredentialSource(backend.Region),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// attributeValue is a dynamodb attribute, only strings and numbers are used
+type attributeValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+func stringValue(value string) attributeValue { return attributeValue{S: &value} }
+
+func numberValue(value int) attributeValue {
+	n := strconv.Itoa(value)
+	return attributeValue{N: &n}
+}
+
+// dynamoError is an error response of dynamodb, its type ends in the exception name
+type dynamoError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *dynamoError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// call sends a dynamodb api action and decodes the response into out
+func (s *dynamoLeaseStore) call(ctx context.Context, action string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	credentials, err := s.credentials.get(ctx)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	request.Header.Set("X-Amz-Target", "DynamoDB_20120810."+action)
+	signV4(request, payload, credentials, "dynamodb", s.region, time.Now())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		var failure dynamoError
+		raw, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		if json.Unmarshal(raw, &failure) != nil || failure.Type == "" {
+			return fmt.Errorf("dynamodb %s: %s: %s", action, response.Status, strings.TrimSpace(string(raw)))
+		}
+		if strings.HasSuffix(failure.Type, "ConditionalCheckFailedException") {
+			return errConflict
+		}
+		return fmt.Errorf("dynamodb %s: %w", action, &failure)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func (s *dynamoLeaseStore) get(ctx context.Context) (*leaseRecord, error) {
+	var response struct {
+		Item map[string]attributeValue `json:"Item"`
+	}
+	err := s.call(ctx, "GetItem", map[string]any{
+		"TableName":      s.table,
+		"Key":            map[string]attributeValue{"name": stringValue(s.name)},
+		"ConsistentRead": true,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Item == nil {
+		return nil, nil
+	}
+	item := response.Item
+	str := func(key string) string {
+		if item[key].S == nil {
+			return ""
+		}
+		return *item[key].S
+	}
+	num := func(key string) int {
+		if item[key].N == nil {
+			return 0
+		}
+		n, _ := strconv.Atoi(*item[key].N)
+		return n
+	}
+	record := &leaseRecord{
+		Holder:               str("holder"),
+		LeaseDurationSeconds: num("leaseDurationSeconds"),
+		Transitions:          num("leaseTransitions"),
+		Version:              str("version"),
+	}
+	// the times are informational, the elector only trusts its own clock
+	record.AcquireTime, _ = time.Parse(time.RFC3339Nano, str("acquireTime"))
+	record.RenewTime, _ = time.Parse(time.RFC3339Nano, str("renewTime"))
+	return record, nil
+}
+
+func (s *dynamoLeaseStore) item(record *leaseRecord) (map[string]attributeValue, error) {
+	version := make([]byte, 16)
+	if _, err := rand.Read(version); err != nil {
+		return nil, err
+	}
+	return map[string]attributeValue{
+		"name":                 stringValue(s.name),
+		"holder":               stringValue(record.Holder),
+		"leaseDurationSeconds": numberValue(record.LeaseDurationSeconds),
+		"acquireTime":          stringValue(record.AcquireTime.UTC().Format(time.RFC3339Nano)),
+		"renewTime":            stringValue(record.RenewTime.UTC().Format(time.RFC3339Nano)),
+		"leaseTransitions":     numberValue(record.Transitions),
+		"version":              stringValue(hex.EncodeToString(version)),
+	}, nil
+}
+
+func (s *dynamoLeaseStore) create(ctx context.Context, record *leaseRecord) error {
+	item, err := s.item(record)
+	if err != nil {
+		return err
+	}
+	return s.call(ctx, "PutItem", map[string]any{
+		"TableName":                s.table,
+		"Item":                     item,
+		"ConditionExpression":      "attribute_not_exists(#name)",
+		"ExpressionAttributeNames": map[string]string{"#name": "name"},
+	}, nil)
+}
+
+func (s *dynamoLeaseStore) update(ctx context.Context, record *leaseRecord, version string) error {
+	item, err := s.item(record)
+	if err != nil {
+		return err
+	}
+	return s.call(ctx, "PutItem", map[string]any{
+		"TableName":                 s.table,
+		"Item":                      item,
+		"ConditionExpression":       "#version = :version",
+		"ExpressionAttributeNames":  map[string]string{"#version": "version"},
+		"ExpressionAttributeValues": map[string]attributeValue{":version": stringValue(version)},
+	}, nil)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileLeaseStore keeps the lease in a json file, every access holds a lock
+// on a file next to it. It stands in for dynamodb where all the validators
+// sharing the keys run on one host, like a development machine.
+type fileLeaseStore struct {
+	path string
+}
+
+func newFileLeaseStore(cfg *Config) (*fileLeaseStore, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.File.Path), 0o700); err != nil {
+		return nil, err
+	}
+	return &fileLeaseStore{path: cfg.File.Path}, nil
+}
+
+// locked runs fn holding the lock of the lease file
+func (s *fileLeaseStore) locked(fn func() error) error {
+	lock, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return fn()
+}
+
+// read returns the lease in the file, the caller holds the lock
+func (s *fileLeaseStore) read() (*leaseRecord, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record leaseRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// write replaces the file with the lease at a new version, the caller holds the lock
+func (s *fileLeaseStore) write(record *leaseRecord) error {
+	version := make([]byte, 16)
+	if _, err := rand.Read(version); err != nil {
+		return err
+	}
+	written := *record
+	written.Version = hex.EncodeToString(version)
+	raw, err := json.Marshal(&written)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path+".new", raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".new", s.path)
+}
+
+func (s *fileLeaseStore) get(_ context.Context) (*leaseRecord, error) {
+	var record *leaseRecord
+	err := s.locked(func() error {
+		var err error
+		record, err = s.read()
+		return err
+	})
+	return record, err
+}
+
+func (s *fileLeaseStore) create(_ context.Context, record *leaseRecord) error {
+	return s.locked(func() error {
+		current, err := s.read()
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			return errConflict
+		}
+		return s.write(record)
+	})
+}
+
+func (s *fileLeaseStore) update(_ context.Context, record *leaseRecord, version string) error {
+	return s.locked(func() error {
+		current, err := s.read()
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Version != version {
+			return errConflict
+		}
+		return s.write(record)
+	})
+}
@@ -0,0 +1,3 @@
+module validator-guard
+
+go 1.22.0
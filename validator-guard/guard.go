@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// guard runs the validator only while it holds the lease
+type guard struct {
+	elector       *elector
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+func newGuard(cfg *Config, store leaseStore, holder string) *guard {
+	return &guard{
+		elector:       newElector(cfg, store, holder),
+		renewDeadline: time.Duration(cfg.RenewDeadlineSeconds) * time.Second,
+		retryPeriod:   time.Duration(cfg.RetryPeriodSeconds) * time.Second,
+	}
+}
+
+// run waits for the lease, then runs command while renewing it, and returns
+// the exit code of the guard. The validator is killed as soon as the lease is
+// taken by someone else, or once it went unrenewed for the renew deadline.
+// The deadline is timed from before the last successful renewal was sent, so
+// the validator is gone before any other holder can see the lease expire.
+func (g *guard) run(command []string) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	acquired, err := g.acquire(ctx, signals)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	log.Printf("holding lease as %s, starting %s", g.elector.holder, command[0])
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// its own process group, so a lost lease kills anything the validator started too
+	child.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := child.Start(); err != nil {
+		log.Printf("starting %s: %v", command[0], err)
+		g.release()
+		return 1
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	renewed := make(chan time.Time)
+	lost := make(chan struct{})
+	go g.renew(ctx, renewed, lost)
+
+	deadline := time.NewTimer(time.Until(acquired.Add(g.renewDeadline)))
+	defer deadline.Stop()
+	for {
+		select {
+		case err := <-exited:
+			// the validator stopped signing, so the lease can go to the next one right away
+			cancel()
+			g.release()
+			return exitCode(err)
+		case sig := <-signals:
+			log.Printf("received %s, stopping %s", sig, command[0])
+			if err := child.Process.Signal(sig); err != nil {
+				log.Printf("signalling %s: %v", command[0], err)
+			}
+		case start := <-renewed:
+			if !deadline.Stop() {
+				select {
+				case <-deadline.C:
+				default:
+				}
+			}
+			deadline.Reset(time.Until(start.Add(g.renewDeadline)))
+		case <-lost:
+			log.Printf("lease was taken by another validator, killing %s", command[0])
+			return g.kill(child, exited)
+		case <-deadline.C:
+			log.Printf("lease not renewed for %s, killing %s", g.renewDeadline, command[0])
+			return g.kill(child, exited)
+		}
+	}
+}
+
+// acquire tries the lease every retry period until it holds it, returning
+// when the successful attempt started
+func (g *guard) acquire(ctx context.Context, signals <-chan os.Signal) (time.Time, error) {
+	var waitingFor string
+	for {
+		start := time.Now()
+		held, err := g.elector.tryAcquireOrRenew(ctx)
+		if err != nil {
+			log.Printf("acquiring lease: %v", err)
+		}
+		if held {
+			return start, nil
+		}
+		if observed := g.elector.observed; err == nil && observed != nil && observed.Holder != waitingFor {
+			waitingFor = observed.Holder
+			log.Printf("lease is held by %s, waiting for it to be released or expire", waitingFor)
+		}
+		select {
+		case sig := <-signals:
+			return time.Time{}, errors.New("received " + sig.String() + " while waiting for the lease")
+		case <-time.After(g.retryPeriod):
+		}
+	}
+}
+
+// renew renews the lease every retry period, sending when each successful
+// renewal started, until the lease is lost or ctx is done
+func (g *guard) renew(ctx context.Context, renewed chan<- time.Time, lost chan<- struct{}) {
+	ticker := time.NewTicker(g.retryPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		attempt, cancel := context.WithTimeout(ctx, g.retryPeriod)
+		held, err := g.elector.tryAcquireOrRenew(attempt)
+		cancel()
+		if err != nil {
+			// a failed renewal is retried until the deadline runs out
+			log.Printf("renewing lease: %v", err)
+			continue
+		}
+		if !held {
+			close(lost)
+			return
+		}
+		select {
+		case renewed <- start:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// kill stops the validator without a grace period, it must not sign once
+// the lease may be someone else's. The lease is not released, it is not ours.
+func (g *guard) kill(child *exec.Cmd, exited <-chan error) int {
+	if err := syscall.Kill(-child.Process.Pid, syscall.SIGKILL); err != nil {
+		log.Printf("killing validator: %v", err)
+	}
+	<-exited
+	return 1
+}
+
+// release gives the lease up, a failure only makes the next validator wait for it to expire
+func (g *guard) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := g.elector.release(ctx); err != nil {
+		log.Printf("releasing lease: %v", err)
+		return
+	}
+	log.Printf("released lease")
+}
+
+// exitCode is the exit code of the validator, or 1 when it did not exit normally
+func exitCode(err error) int {
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() >= 0 {
+		return exit.ExitCode()
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyStore fails every read of the lease after the first reads
+type flakyStore struct {
+	leaseStore
+	reads     atomic.Int32
+	failAfter int32
+}
+
+func (s *flakyStore) get(ctx context.Context) (*leaseRecord, error) {
+	if s.reads.Add(1) > s.failAfter {
+		return nil, errors.New("store unavailable")
+	}
+	return s.leaseStore.get(ctx)
+}
+
+// testGuard renews every 20ms and kills the validator after 200ms without a renewal
+func testGuard(store leaseStore) *guard {
+	return &guard{
+		elector:       newElector(&Config{LeaseDurationSeconds: 60}, store, "a"),
+		renewDeadline: 200 * time.Millisecond,
+		retryPeriod:   20 * time.Millisecond,
+	}
+}
+
+func TestGuardKillsOnMissedDeadline(t *testing.T) {
+	store := &flakyStore{leaseStore: &memoryStore{}, failAfter: 1}
+	g := testGuard(store)
+
+	start := time.Now()
+	code := g.run([]string{"sleep", "30"})
+	elapsed := time.Since(start)
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if elapsed < g.renewDeadline || elapsed > 5*time.Second {
+		t.Errorf("validator killed after %v, want right after the %v deadline", elapsed, g.renewDeadline)
+	}
+}
+
+func TestGuardKillsOnLostLease(t *testing.T) {
+	store := &memoryStore{}
+	g := testGuard(store)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 60})
+	}()
+
+	if code := g.run([]string{"sleep", "30"}); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	// the lease is someone else's, it must not be released
+	if holder := store.current().Holder; holder != "b" {
+		t.Errorf("holder = %q after the lease was lost, want b", holder)
+	}
+}
+
+func TestGuardRenewsPastDeadline(t *testing.T) {
+	store := &memoryStore{}
+	g := testGuard(store)
+
+	// the validator outlives several renew deadlines while renewals succeed,
+	// it would be killed with exit code 1 otherwise
+	if code := g.run([]string{"sh", "-c", "sleep 1; exit 3"}); code != 3 {
+		t.Errorf("exit code = %d, want the validator's 3", code)
+	}
+	// a validator that exits gives the lease up
+	if holder := store.current().Holder; holder != "" {
+		t.Errorf("lease still held by %q after the validator exited", holder)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeClient talks to the api server with the pod's service account
+type kubeClient struct {
+	host   string
+	client *http.Client
+}
+
+// kubeError is a response of the api server outside 2xx
+type kubeError struct {
+	status int
+	detail string
+}
+
+func (e *kubeError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.status, http.StatusText(e.status), e.detail)
+}
+
+func newInClusterClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are unset")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+	}
+	return &kubeClient{
+		host: "https://" + net.JoinHostPort(host, port),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// do sends body as json and decodes the response into out
+func (k *kubeClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, k.host+path, reader)
+	if err != nil {
+		return err
+	}
+	// the token is read on every request, projected tokens are rotated on disk
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := k.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s %s: %w", method, path, &kubeError{status: response.StatusCode, detail: strings.TrimSpace(string(detail))})
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// kubeStatus returns the status code of a kubeError, 0 for any other error
+func kubeStatus(err error) int {
+	var kerr *kubeError
+	if errors.As(err, &kerr) {
+		return kerr.status
+	}
+	return 0
+}
+
+// microTime is the format of the times of a lease
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+type lease struct {
+	ApiVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Metadata   leaseMeta `json:"metadata"`
+	Spec       leaseSpec `json:"spec"`
+}
+
+type leaseMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int    `json:"leaseTransitions,omitempty"`
+}
+
+// kubeLeaseStore keeps the lease in a coordination.k8s.io Lease, the
+// resource version makes every write conditional
+type kubeLeaseStore struct {
+	kube      *kubeClient
+	namespace string
+	name      string
+}
+
+func newKubeLeaseStore(cfg *Config) (*kubeLeaseStore, error) {
+	kube, err := newInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	return &kubeLeaseStore{kube: kube, namespace: cfg.Kubernetes.Namespace, name: cfg.Name}, nil
+}
+
+func (s *kubeLeaseStore) path(named bool) string {
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", url.PathEscape(s.namespace))
+	if named {
+		path += "/" + url.PathEscape(s.name)
+	}
+	return path
+}
+
+func (s *kubeLeaseStore) get(ctx context.Context) (*leaseRecord, error) {
+	var current lease
+	if err := s.kube.do(ctx, http.MethodGet, s.path(true), nil, &current); err != nil {
+		if kubeStatus(err) == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	record := &leaseRecord{Version: current.Metadata.ResourceVersion}
+	spec := current.Spec
+	if spec.HolderIdentity != nil {
+		record.Holder = *spec.HolderIdentity
+	}
+	if spec.LeaseDurationSeconds != nil {
+		record.LeaseDurationSeconds = *spec.LeaseDurationSeconds
+	}
+	if spec.LeaseTransitions != nil {
+		record.Transitions = *spec.LeaseTransitions
+	}
+	// the times are informational, the elector only trusts its own clock
+	if spec.AcquireTime != nil {
+		record.AcquireTime, _ = time.Parse(microTime, *spec.AcquireTime)
+	}
+	if spec.RenewTime != nil {
+		record.RenewTime, _ = time.Parse(microTime, *spec.RenewTime)
+	}
+	return record, nil
+}
+
+func (s *kubeLeaseStore) lease(record *leaseRecord, version string) *lease {
+	acquireTime := record.AcquireTime.UTC().Format(microTime)
+	renewTime := record.RenewTime.UTC().Format(microTime)
+	return &lease{
+		ApiVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata:   leaseMeta{Name: s.name, Namespace: s.namespace, ResourceVersion: version},
+		Spec: leaseSpec{
+			HolderIdentity:       &record.Holder,
+			LeaseDurationSeconds: &record.LeaseDurationSeconds,
+			AcquireTime:          &acquireTime,
+			RenewTime:            &renewTime,
+			LeaseTransitions:     &record.Transitions,
+		},
+	}
+}
+
+func (s *kubeLeaseStore) create(ctx context.Context, record *leaseRecord) error {
+	err := s.kube.do(ctx, http.MethodPost, s.path(false), s.lease(record, ""), nil)
+	if kubeStatus(err) == http.StatusConflict {
+		return errConflict
+	}
+	return err
+}
+
+func (s *kubeLeaseStore) update(ctx context.Context, record *leaseRecord, version string) error {
+	err := s.kube.do(ctx, http.MethodPut, s.path(true), s.lease(record, version), nil)
+	if kubeStatus(err) == http.StatusConflict {
+		return errConflict
+	}
+	return err
+}
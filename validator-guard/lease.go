@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errConflict is returned by a store when the lease changed since it was read
+var errConflict = errors.New("lease changed since it was read")
+
+// leaseRecord is the lease as kept by a store. Version changes on every write
+// and is opaque to everything but the store that wrote it.
+type leaseRecord struct {
+	Holder               string
+	LeaseDurationSeconds int
+	AcquireTime          time.Time
+	RenewTime            time.Time
+	Transitions          int
+	Version              string
+}
+
+// leaseStore reads and conditionally writes the lease
+type leaseStore interface {
+	// get returns the lease, nil when it was never created
+	get(ctx context.Context) (*leaseRecord, error)
+	// create writes the lease when it does not exist yet, errConflict otherwise
+	create(ctx context.Context, record *leaseRecord) error
+	// update replaces the lease when it is still at version, errConflict otherwise
+	update(ctx context.Context, record *leaseRecord, version string) error
+}
+
+// elector takes and renews the lease for holder. It never trusts the clock of
+// another holder: a lease held by someone else is only taken after it went
+// unchanged for the lease duration, timed by the local clock from when the
+// change was observed.
+type elector struct {
+	store         leaseStore
+	holder        string
+	leaseDuration time.Duration
+	now           func() time.Time
+
+	observed   *leaseRecord
+	observedAt time.Time
+}
+
+func newElector(cfg *Config, store leaseStore, holder string) *elector {
+	return &elector{
+		store:         store,
+		holder:        holder,
+		leaseDuration: time.Duration(cfg.LeaseDurationSeconds) * time.Second,
+		now:           time.Now,
+	}
+}
+
+// tryAcquireOrRenew takes the lease when it is free or expired, or renews it
+// when it is already held, returning whether it is held
+func (e *elector) tryAcquireOrRenew(ctx context.Context) (bool, error) {
+	now := e.now()
+	current, err := e.store.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	record := &leaseRecord{
+		Holder:               e.holder,
+		LeaseDurationSeconds: int(e.leaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+	if current == nil {
+		if err := e.store.create(ctx, record); err != nil {
+			return false, ignoreConflict(err)
+		}
+		return true, nil
+	}
+
+	if e.observed == nil || e.observed.Version != current.Version {
+		e.observed, e.observedAt = current, now
+	}
+	if current.Holder == e.holder {
+		record.AcquireTime = current.AcquireTime
+		record.Transitions = current.Transitions
+	} else {
+		// a released lease is free, a held one only once it has expired for
+		// the longer of its own duration and ours
+		duration := max(e.leaseDuration, time.Duration(current.LeaseDurationSeconds)*time.Second)
+		if current.Holder != "" && now.Before(e.observedAt.Add(duration)) {
+			return false, nil
+		}
+		record.Transitions = current.Transitions + 1
+	}
+	if err := e.store.update(ctx, record, current.Version); err != nil {
+		return false, ignoreConflict(err)
+	}
+	return true, nil
+}
+
+// release gives the lease up so another validator takes it without waiting
+// for it to expire. It is only called once this validator stopped signing.
+func (e *elector) release(ctx context.Context) error {
+	current, err := e.store.get(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Holder != e.holder {
+		return fmt.Errorf("lease is not held by %s", e.holder)
+	}
+	released := *current
+	released.Holder = ""
+	released.RenewTime = e.now()
+	return e.store.update(ctx, &released, current.Version)
+}
+
+// ignoreConflict turns a lost race for the lease into not holding it
+func ignoreConflict(err error) error {
+	if errors.Is(err, errConflict) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a leaseStore in memory. The errors fail the next calls, and
+// conflicts makes updates lose a race to another writer.
+type memoryStore struct {
+	mu        sync.Mutex
+	record    *leaseRecord
+	version   int
+	getErr    error
+	updateErr error
+	conflicts bool
+}
+
+func (s *memoryStore) get(ctx context.Context) (*leaseRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+	if s.record == nil {
+		return nil, nil
+	}
+	record := *s.record
+	return &record, nil
+}
+
+func (s *memoryStore) create(ctx context.Context, record *leaseRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record != nil || s.conflicts {
+		return errConflict
+	}
+	s.write(record)
+	return nil
+}
+
+func (s *memoryStore) update(ctx context.Context, record *leaseRecord, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	if s.record == nil || s.record.Version != version || s.conflicts {
+		return errConflict
+	}
+	s.write(record)
+	return nil
+}
+
+func (s *memoryStore) write(record *leaseRecord) {
+	s.version++
+	written := *record
+	written.Version = strconv.Itoa(s.version)
+	s.record = &written
+}
+
+// set replaces the lease as another writer would
+func (s *memoryStore) set(record leaseRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(&record)
+}
+
+func (s *memoryStore) current() leaseRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.record
+}
+
+// testElector returns an elector with a 60 second lease whose clock only
+// moves when advance is called
+func testElector(store leaseStore, holder string) (*elector, func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	e := newElector(&Config{LeaseDurationSeconds: 60}, store, holder)
+	e.now = func() time.Time { return now }
+	return e, func(d time.Duration) { now = now.Add(d) }
+}
+
+func mustAcquire(t *testing.T, e *elector, want bool) {
+	t.Helper()
+	held, err := e.tryAcquireOrRenew(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held != want {
+		t.Fatalf("held = %v, want %v", held, want)
+	}
+}
+
+func TestElectorAcquireAndRenew(t *testing.T) {
+	store := &memoryStore{}
+	e, advance := testElector(store, "a")
+
+	mustAcquire(t, e, true)
+	acquired := store.current()
+	if acquired.Holder != "a" || acquired.LeaseDurationSeconds != 60 || acquired.Transitions != 0 {
+		t.Fatalf("created lease %+v", acquired)
+	}
+
+	advance(10 * time.Second)
+	mustAcquire(t, e, true)
+	renewed := store.current()
+	if !renewed.AcquireTime.Equal(acquired.AcquireTime) {
+		t.Errorf("renewal moved the acquire time from %v to %v", acquired.AcquireTime, renewed.AcquireTime)
+	}
+	if got := renewed.RenewTime.Sub(acquired.RenewTime); got != 10*time.Second {
+		t.Errorf("renewal moved the renew time by %v, want 10s", got)
+	}
+	if renewed.Transitions != 0 {
+		t.Errorf("renewal counted a transition")
+	}
+}
+
+func TestElectorExpiry(t *testing.T) {
+	store := &memoryStore{}
+	store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 60})
+	e, advance := testElector(store, "a")
+
+	// the lease expires a lease duration after it was first seen, whatever
+	// the renew time the other holder wrote
+	mustAcquire(t, e, false)
+	advance(59 * time.Second)
+	mustAcquire(t, e, false)
+	advance(time.Second)
+	mustAcquire(t, e, true)
+
+	taken := store.current()
+	if taken.Holder != "a" || taken.Transitions != 1 {
+		t.Fatalf("taken lease %+v", taken)
+	}
+}
+
+func TestElectorRenewedByOtherHolder(t *testing.T) {
+	store := &memoryStore{}
+	store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 60})
+	e, advance := testElector(store, "a")
+
+	mustAcquire(t, e, false)
+	advance(50 * time.Second)
+	// a renewal by the holder restarts the wait from when it was seen
+	store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 60})
+	mustAcquire(t, e, false)
+	advance(50 * time.Second)
+	mustAcquire(t, e, false)
+	advance(10 * time.Second)
+	mustAcquire(t, e, true)
+}
+
+func TestElectorLongerLeaseOfOtherHolder(t *testing.T) {
+	store := &memoryStore{}
+	store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 120})
+	e, advance := testElector(store, "a")
+
+	mustAcquire(t, e, false)
+	advance(60 * time.Second)
+	mustAcquire(t, e, false)
+	advance(60 * time.Second)
+	mustAcquire(t, e, true)
+}
+
+func TestElectorReleasedLease(t *testing.T) {
+	store := &memoryStore{}
+	store.set(leaseRecord{Holder: "", LeaseDurationSeconds: 60, Transitions: 3})
+	e, _ := testElector(store, "a")
+
+	mustAcquire(t, e, true)
+	if taken := store.current(); taken.Holder != "a" || taken.Transitions != 4 {
+		t.Fatalf("taken lease %+v", taken)
+	}
+}
+
+func TestElectorConflict(t *testing.T) {
+	// losing the race to create the lease
+	store := &memoryStore{conflicts: true}
+	e, advance := testElector(store, "a")
+	mustAcquire(t, e, false)
+
+	// losing the race to take an expired lease
+	store.conflicts = false
+	store.set(leaseRecord{Holder: "b", LeaseDurationSeconds: 60})
+	mustAcquire(t, e, false)
+	advance(time.Minute)
+	store.conflicts = true
+	mustAcquire(t, e, false)
+	if holder := store.current().Holder; holder != "b" {
+		t.Fatalf("holder = %s after a conflict, want b", holder)
+	}
+
+	// losing the race to renew
+	store.conflicts = false
+	mustAcquire(t, e, true)
+	store.conflicts = true
+	mustAcquire(t, e, false)
+}
+
+func TestElectorStoreErrors(t *testing.T) {
+	failed := errors.New("store unavailable")
+	store := &memoryStore{}
+	e, _ := testElector(store, "a")
+	mustAcquire(t, e, true)
+
+	store.updateErr = failed
+	if _, err := e.tryAcquireOrRenew(context.Background()); !errors.Is(err, failed) {
+		t.Errorf("update error = %v, want %v", err, failed)
+	}
+	store.updateErr, store.getErr = nil, failed
+	if _, err := e.tryAcquireOrRenew(context.Background()); !errors.Is(err, failed) {
+		t.Errorf("get error = %v, want %v", err, failed)
+	}
+}
+
+func TestElectorRelease(t *testing.T) {
+	store := &memoryStore{}
+	e, advance := testElector(store, "a")
+	other, _ := testElector(store, "b")
+
+	if err := e.release(context.Background()); err == nil {
+		t.Fatal("released a lease that was never created")
+	}
+	mustAcquire(t, e, true)
+	if err := other.release(context.Background()); err == nil {
+		t.Fatal("released a lease held by someone else")
+	}
+	advance(time.Second)
+	if err := e.release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if released := store.current(); released.Holder != "" {
+		t.Fatalf("released lease still held by %s", released.Holder)
+	}
+	// anyone takes a released lease right away
+	mustAcquire(t, other, true)
+}
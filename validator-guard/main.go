@@ -0,0 +1,99 @@
+// validator-guard keeps a validator's keys signing in one place at a time.
+// It runs in front of the validator client and only starts it once it holds
+// a lease shared by every validator with the same keys, in a kubernetes Lease
+// or a dynamodb item, or a lock file standing in for dynamodb on one host.
+// While the validator runs the lease is renewed, and the validator is killed
+// when the lease is lost or goes unrenewed for the renew deadline, before
+// anyone else can take it over. A validator that exits releases the lease.
+//
+//	validator-guard -config /etc/validator-guard/guard.json -- lighthouse vc ...
+//
+// The validator client's image does not ship the guard, so the install
+// command copies the binary into a volume shared with it:
+//
+//	validator-guard install /guard/validator-guard
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	if len(os.Args) == 3 && os.Args[1] == "install" {
+		if err := install(os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	configPath := flag.String("config", "/etc/validator-guard/guard.json", "path to the guard config file")
+	flag.Parse()
+	command := flag.Args()
+	if len(command) == 0 {
+		log.Fatal("no validator command, run validator-guard -config <path> -- <command>")
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := newLeaseStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	holder, err := holderIdentity(cfg.Holder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("waiting for the %s lease %s as %s", cfg.Backend, cfg.Name, holder)
+	os.Exit(newGuard(cfg, store, holder).run(command))
+}
+
+func newLeaseStore(cfg *Config) (leaseStore, error) {
+	switch cfg.Backend {
+	case BackendKubernetes:
+		return newKubeLeaseStore(cfg)
+	case BackendDynamoDb:
+		return newDynamoLeaseStore(cfg), nil
+	default:
+		return newFileLeaseStore(cfg)
+	}
+}
+
+// holderIdentity makes the holder unique to this start of the guard
+func holderIdentity(holder string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", holder, hex.EncodeToString(suffix)), nil
+}
+
+// install copies the running binary to path
+func install(path string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	source, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}